@@ -0,0 +1,306 @@
+package binr
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// decompressor wraps a compressed stream in one which is decompressed.
+type decompressor func(io.Reader) (io.Reader, error)
+
+// decompressors by name.  Those which require additional dependencies are
+// registered by files behind build tags (see xz.go).
+var decompressors = map[string]decompressor{
+	"gzip":  func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	"bzip2": func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil },
+}
+
+// archive describes the format of a downloaded file.
+type archive struct {
+	compression string // name of the decompressor, if compressed
+	zip         bool   // is a zip archive (never additionally compressed)
+	name        string // name of the file compressed, if not a tarball
+}
+
+// archiveSuffixes maps URL suffixes to the archive they indicate.
+var archiveSuffixes = []struct {
+	suffix string
+	archive
+}{
+	{".tar.gz", archive{compression: "gzip"}},
+	{".tgz", archive{compression: "gzip"}},
+	{".tar.bz2", archive{compression: "bzip2"}},
+	{".tbz2", archive{compression: "bzip2"}},
+	{".tbz", archive{compression: "bzip2"}},
+	{".tar.xz", archive{compression: "xz"}},
+	{".txz", archive{compression: "xz"}},
+	{".tar", archive{}},
+	{".zip", archive{zip: true}},
+}
+
+// archiveMagic maps the leading bytes of a file to the compression they
+// indicate.  Uncompressed tarballs are detected by their header (see isTar).
+var archiveMagic = []struct {
+	magic []byte
+	archive
+}{
+	{[]byte{0x1f, 0x8b}, archive{compression: "gzip"}},
+	{[]byte("BZh"), archive{compression: "bzip2"}},
+	{[]byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, archive{compression: "xz"}},
+	{[]byte("PK\x03\x04"), archive{zip: true}},
+}
+
+// detectArchive returns the archive format of the file at path which was
+// downloaded from the given URL, first by the URL's suffix and then by the
+// file's magic bytes.  ok is false if the file is not an archive.
+func detectArchive(fsys Filesystem, sourceURL, filePath string) (a archive, ok bool, err error) {
+	if a, ok = archiveBySuffix(sourceURL); ok {
+		return
+	}
+
+	file, err := fsys.Open(filePath)
+	if err != nil {
+		return a, false, fmt.Errorf("binr unable to open download to detect its format. %w", err)
+	}
+	defer file.Close()
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return a, false, fmt.Errorf("binr unable to read download to detect its format. %w", err)
+	}
+	header = header[:n]
+	for _, m := range archiveMagic {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.archive, true, nil
+		}
+	}
+	return a, isTar(header), nil
+}
+
+// archiveBySuffix returns the archive format indicated by the suffix of the
+// given URL's path.  ok is false if the suffix is not that of an archive.
+func archiveBySuffix(sourceURL string) (a archive, ok bool) {
+	name := sourceURL
+	if u, err := url.Parse(sourceURL); err == nil {
+		name = u.Path
+	}
+	name = strings.ToLower(name)
+	for _, s := range archiveSuffixes {
+		if strings.HasSuffix(name, s.suffix) {
+			return s.archive, true
+		}
+	}
+	return
+}
+
+// decompressedName returns the name of the file compressed at the given
+// URL: the base of its path without the extension of its compression.
+func decompressedName(sourceURL string) string {
+	name := sourceURL
+	if u, err := url.Parse(sourceURL); err == nil {
+		name = u.Path
+	}
+	name = path.Base(name)
+	for _, ext := range []string{".gz", ".bz2", ".xz"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// isTar returns true if the given header is that of a tarball.
+func isTar(header []byte) bool {
+	return len(header) >= 262 && string(header[257:262]) == "ustar"
+}
+
+// extract the given command from the file at filePath if it is an archive,
+// writing it to outPath.  ok is false if the file is not an archive, in which
+// case the download is itself the command.
+//
+// The member extracted is the first regular file whose name is the command
+// (or the command with a .exe extension) in any directory of the archive.
+// If command is empty, the archive must contain exactly one regular file.
+// If the config has a member pattern (see WithArchiveMemberPattern), the
+// member extracted is instead the single regular file whose path matches it.
+//
+// Compressed files which are not tarballs (such as mytool.gz) are
+// decompressed to outPath.  Such a file is taken to be named as its URL
+// without the extension of its compression (mytool), which must match the
+// member pattern if any.
+func extract(cfg config, sourceURL, filePath, outPath, command string) (ok bool, err error) {
+	a, ok, err := detectArchive(cfg.fs, sourceURL, filePath)
+	if err != nil || !ok {
+		return
+	}
+	cfg.log.Debug().
+		Str("compression", a.compression).
+		Bool("zip", a.zip).
+		Str("path", filePath).
+		Msg("binr extracting command from archive")
+	a.name = decompressedName(sourceURL)
+
+	selected := func(name string) bool { return isMember(name, command) }
+	if cfg.memberPattern != nil {
+		selected = func(string) bool { return false } // listed first
+	}
+	members, found, err := extractMember(cfg, a, filePath, outPath, selected)
+	if err != nil || found {
+		return true, err
+	}
+
+	// Selecting a member by pattern, or the only member, requires a second
+	// pass.
+	var name string
+	if cfg.memberPattern != nil {
+		var matches []string
+		for _, m := range members {
+			if cfg.memberPattern.MatchString(m) {
+				matches = append(matches, m)
+			}
+		}
+		if len(matches) != 1 {
+			return true, fmt.Errorf("binr expected exactly one file in the archive matching %q, but found %v. It contains: %v", cfg.memberPattern, len(matches), strings.Join(members, ", "))
+		}
+		name = matches[0]
+	} else if command == "" && len(members) == 1 {
+		name = members[0]
+	} else {
+		return true, memberNotFoundError(command, members)
+	}
+	cfg.log.Debug().Str("member", name).Msg("binr selected archive member")
+	_, _, err = extractMember(cfg, a, filePath, outPath, func(m string) bool { return m == name })
+	return true, err
+}
+
+// extractMember writes the first regular file of the archive at filePath
+// which is selected to outPath, returning the names of the regular files
+// read and whether one was selected.
+func extractMember(cfg config, a archive, filePath, outPath string, selected func(name string) bool) (members []string, found bool, err error) {
+	if a.zip {
+		return extractZip(cfg.fs, filePath, outPath, selected)
+	}
+
+	file, err := cfg.fs.Open(filePath)
+	if err != nil {
+		return nil, false, fmt.Errorf("binr unable to open archive. %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if a.compression != "" {
+		decompress, ok := decompressors[a.compression]
+		if !ok {
+			return nil, false, fmt.Errorf("binr was built without support for %v archives. Rebuild with the build tag binr_%v", a.compression, a.compression)
+		}
+		if r, err = decompress(file); err != nil {
+			return nil, false, fmt.Errorf("binr unable to decompress %v archive. %w", a.compression, err)
+		}
+	}
+
+	// A compressed single file rather than a tarball
+	br := bufio.NewReaderSize(r, 512)
+	if header, _ := br.Peek(512); !isTar(header) {
+		if a.compression == "" {
+			return nil, false, errors.New("binr expected a tar archive but the download does not appear to be one")
+		}
+		if cfg.memberPattern != nil && !selected(a.name) {
+			return []string{a.name}, false, nil
+		}
+		return nil, true, writeMember(cfg.fs, br, outPath)
+	}
+
+	tr := tar.NewReader(br)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return members, false, fmt.Errorf("binr unable to read tar archive. %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		members = append(members, hdr.Name)
+		if selected(hdr.Name) {
+			return members, true, writeMember(cfg.fs, tr, outPath)
+		}
+	}
+	return members, false, nil
+}
+
+// extractZip extracts the first selected regular file of the zip at
+// filePath to outPath.  See extractMember.
+func extractZip(fsys Filesystem, filePath, outPath string, selected func(name string) bool) (members []string, found bool, err error) {
+	file, err := fsys.Open(filePath)
+	if err != nil {
+		return nil, false, fmt.Errorf("binr unable to open zip archive. %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, false, fmt.Errorf("binr unable to read zip archive. %w", err)
+	}
+	zr, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		return nil, false, fmt.Errorf("binr unable to read zip archive. %w", err)
+	}
+
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		members = append(members, f.Name)
+		if !selected(f.Name) {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return members, false, fmt.Errorf("binr unable to read %q from zip archive. %w", f.Name, err)
+		}
+		defer r.Close()
+		return members, true, writeMember(fsys, r, outPath)
+	}
+	return members, false, nil
+}
+
+// isMember returns true if the archive member with the given name is the
+// command.
+func isMember(name, command string) bool {
+	if command == "" {
+		return false
+	}
+	base := path.Base(name)
+	return base == command || base == command+".exe"
+}
+
+// writeMember writes the contents of r to a new executable file at outPath.
+func writeMember(fsys Filesystem, r io.Reader, outPath string) error {
+	file, err := fsys.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0755)
+	if err != nil {
+		return fmt.Errorf("binr unable to open local file for extraction. %w", err)
+	}
+	defer file.Close()
+	if _, err = io.Copy(file, r); err != nil {
+		return fmt.Errorf("binr encountered an error extracting the command from its archive. %w", err)
+	}
+	return nil
+}
+
+func memberNotFoundError(command string, members []string) error {
+	if command == "" {
+		return fmt.Errorf("binr expected an archive with exactly one file, but found %v", len(members))
+	}
+	return fmt.Errorf("binr found no file named %q in the archive. It contains: %v", command, strings.Join(members, ", "))
+}
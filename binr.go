@@ -9,22 +9,37 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"mime"
 	"net/http"
+	neturl "net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"runtime/debug"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/Masterminds/semver"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
 )
 
 // DefaultLogLevel for binr is logging disabled.
 // Use SetLogLevel to change.
 const DefaultLogLevel = LogDisabled
 
+// ErrNotFound is returned (wrapped) when the URL for a command or its
+// checksum responds with an HTTP 404.
+var ErrNotFound = errors.New("binr source not found")
+
 // Get the path to a binary.
 //
 // `namespace` is generally the name of the application or system
@@ -36,401 +51,2652 @@ const DefaultLogLevel = LogDisabled
 // default as ~/.config/binr/[namespace]/[command]
 // and also   ~/.config/binr/[namespace]/[command]-[version]
 //
-// Version is the specific version to get. TODO: currently expects an exact
-// (vX.Y.Z), but will soon support semver major and minor (vX and vX.Y).
+// Version is the specific version to get (vX.Y.Z), or a floating version
+// which is resolved to the newest release satisfying it: a major (vX), a
+// minor (vX.Y), or "latest".  Floating versions are resolved by a Lister
+// by which the releases are known (see WithLister), and are additionally
+// linked as ~/.config/binr/[namespace]/[command]-[vX or vX.Y], or for
+// "latest" as the unversioned link.  Once linked, a floating version is
+// served as linked without consulting the Lister.  See WithUpdate for
+// keeping them current.  Builds which are not released may be requested
+// by digest (sha256:<hex>) or git commit (sha:<gitsha>), which is provided
+// to the Source as-is and linked as [command]-[abbreviated digest] only.
 //
 // The provided Source is a function which returns a final location at
 // which the command and its checksum can be downloaded for a given os,
 // architecture and version.
 func Get(ctx context.Context, namespace, command, version string, source Source, options ...option) (path string, err error) {
+	res, err := GetResult(ctx, namespace, command, version, source, options...)
+	return res.Path, err
+}
+
+// Result of a call to GetResult.
+type Result struct {
+	// Path is the absolute path at which the command can be invoked.
+	Path string
+
+	// Checksum of the command, which is also the name of its object in the
+	// cache.
+	Checksum string
+
+	// OS and Arch for which the command was sourced.  This may differ from
+	// the current system's when an alternate arch was used (see
+	// WithArchFallback).  Both are empty if the command was already
+	// installed and the Source was therefore not consulted.
+	OS, Arch string
+
+	// Cached is true if the command was served from the local store
+	// without a download.
+	Cached bool
+
+	// Version installed, which is the release to which a floating version
+	// was resolved.
+	Version string
+
+	// DownloadDuration and DownloadBytes describe the command's download,
+	// and are zero if it was not downloaded.
+	DownloadDuration time.Duration
+	DownloadBytes    int64
+
+	// Warnings about conditions encountered which did not prevent the
+	// command being provided, such as falling back to an alternate arch or
+	// tolerating a failure to set its mode.  These are also logged, but are
+	// provided here such that callers may present them regardless of
+	// logging.
+	Warnings []string
+
+	// ResolvedURL is that which ultimately served the download, after any
+	// rewriting (see WithURLRewriter) and redirects, with any password
+	// redacted.  Attempts is the number of attempts made to download it,
+	// including the first (see WithRetries).  Both are zero if the command
+	// was not downloaded.
+	ResolvedURL string
+	Attempts    int
+
+	// HashBytes and HashDuration describe the hashing of files by the call,
+	// such as to verify a download, and are zero if none were hashed.
+	// HashBytes / HashDuration.Seconds() is the hashing throughput, which
+	// for sha256 reflects whether the CPU's SHA extensions are used.
+	HashBytes    int64
+	HashDuration time.Duration
+}
+
+// GetResult is Get, returning a Result with details about the command
+// provided rather than only its path.
+func GetResult(ctx context.Context, namespace, command, version string, source Source, options ...option) (res Result, err error) {
 	cfg := newConfig(options...)
+	traceID := cfg.trace(ctx)
+	defer func() { err = traceError(err, traceID) }()
+	cfg.warnings = &warnings{}
+	defer func() { res.Warnings = cfg.warnings.list() }()
+	cfg.hashed = &hashed{}
+	defer func() { res.HashBytes, res.HashDuration = cfg.hashed.total() }()
+	if cfg.workingDirFallback() {
+		cfg.warnings.add(workingDirWarning)
+	}
 
-	log.Debug().
+	cfg.log.Debug().
 		Str("namespace", namespace).
 		Str("command", command).
 		Str("version", version).
 		Bool("update", cfg.update).
 		Msg("binr ensuring command")
 
+	if version, source, err = applyPins(cfg, namespace, command, version, source); err != nil {
+		return
+	}
+
 	if namespace == "" {
-		return "", errors.New("binr Get requires namespace")
+		return res, errors.New("binr Get requires namespace")
 	} else if command == "" {
-		return "", errors.New("binr Get requires command")
+		return res, errors.New("binr Get requires command")
 	} else if version == "" {
-		return "", errors.New("binr Get requires a version")
-	} else if _, err := semver.NewVersion(version); err != nil {
-		return "", errors.New("binr Get requires version to be a valid semver (ex: v1.2.3)")
+		return res, errors.New("binr Get requires a version")
+	} else if kind, err := ClassifyVersion(version); err != nil || kind == Constraint || kind == Channel {
+		return res, errors.New("binr Get requires version to be a valid semver (ex: v1.2.3), a major or minor (ex: v1 or v1.2), \"latest\", or a digest (ex: sha:<gitsha>)")
 	} else if source == nil {
-		return "", errors.New("binr Get requires a Source to resolve missing dependencies")
-	} else if cfg.update {
-		return "", errors.New("binr Get WithUpdate is not yet implemented")
+		return res, errors.New("binr Get requires a Source to resolve missing dependencies")
 	}
 
-	if err = setup(); err != nil {
-		return
-	}
+	// Errors beyond validation are those of a specific command, and are
+	// wrapped to identify it, such that concurrent Gets can be told apart.
+	defer func(version string) {
+		if err != nil {
+			err = fmt.Errorf("binr unable to get %v %v in namespace %v. %w", command, version, namespace, err)
+		}
+	}(version)
 
-	if path, err = Path(namespace, command, version); err != nil {
-		return
+	// A command already installed is served as-is, without locking the
+	// cache (which may then be read-only).  A floating version's link is
+	// likewise served as installed, and is only resolved anew WithUpdate.
+	if !cfg.noCache && !cfg.update && !cfg.startupVerify {
+		var ok bool
+		if res, ok, err = served(ctx, cfg, namespace, command, version); err != nil || ok {
+			if err == nil && cfg.reconcileLatest {
+				err = reconcileLatest(cfg, namespace, cfg.linkName(command))
+			}
+			return
+		}
 	}
 
-	if got(path) {
-		log.Debug().Str("path", path).Msg("binr found command locally")
-		return
+	var floating string
+	if isFloating(version) {
+		floating = version
+		if version, err = resolveVersion(ctx, cfg, floating); err != nil {
+			return
+		}
+		cfg.log.Debug().Str("version", version).Msg("binr resolved floating version")
 	}
 
-	sourceURL, sumURL, err := source(version, runtime.GOOS, runtime.GOARCH)
-	if err != nil {
+	if cfg.noCache {
+		res, err = getUncached(ctx, cfg, command, version, source)
+		res.Version = version
 		return
 	}
 
-	sum, err := getChecksum(ctx, sumURL) // URL to checksum (optional)
-	if err != nil {
+	if err = setup(ctx, cfg); err != nil {
 		return
 	}
-
-	sum, cleanup, err := cache(ctx, sourceURL, sum) // returns actual sum if no sumURL provided
+	unlock, err := readLock(ctx, cfg)
 	if err != nil {
 		return
 	}
-	defer cleanup()
+	defer unlock()
 
-	if err = link(namespace, command, version, sum); err != nil {
+	if cfg.cleanOnError {
+		cfg.linked = &linkJournal{}
+		defer func() {
+			if err != nil {
+				cfg.linked.rollback(cfg)
+			}
+		}()
+	}
+	if res, err = install(ctx, cfg, namespace, command, version, source); err != nil {
 		return
 	}
-	log.Debug().Msg("binr completed without error")
+	if cfg.reconcileLatest {
+		if err = reconcileLatest(cfg, namespace, cfg.linkName(command)); err != nil {
+			return
+		}
+	}
+	if floating != "" {
+		if res.Path, err = linkFloating(cfg, namespace, cfg.linkName(command), floating, res.Checksum); err != nil {
+			return
+		}
+	}
+	if cfg.update {
+		err = update(ctx, cfg, namespace, command, source)
+	}
 	return
 }
 
-// Source is a function which, when provided a version, OS and architecture
-// will return the urls at which the binary and its checksum can be found.
-type Source func(version, os, arch string) (url, sum string, err error)
-
-// config is mutated by functional options for Get such as WithUpdate
-type config struct{ update bool }
-
-type option func(*config)
-
-func newConfig(options ...option) (cfg config) {
-	for _, option := range options {
-		option(&cfg)
+// install the exact version of the command in the namespace, fetching it
+// into the cache if it is not already installed, and linking it.
+func install(ctx context.Context, cfg config, namespace, command, version string, source Source) (res Result, err error) {
+	res.Version = version
+	name := cfg.linkName(command)
+	if res.Path, err = cfg.linkPath(namespace, name, version); err != nil {
+		return
 	}
-	return
-}
 
-// WithUpdate instructs the system to update extant binaries.
-// The default behavior is to never replace a binary once it has been provided.
-// Note that this option worls in concert with version and checksum URL.
-// TODO: implement and document:
-//
-//	Has no effect if either the version is explicit (vX.Y.Z), or there is
-//	no checksum URL returned from the source implementation.
-//	If the version is omitted (aka "latest"), but a checksum URL is provided
-//	from the source implementation, WithUpdate will result in a call to
-//	.Get checking if the checksum differs from the currently cached binary,
-//	and replacing with the latest via download.
-//	If the version is provided, but is not explicit (vX.Y or vX), the
-//	source implementation should provide the checksum URL and source URL to
-//	the latest implementation which adheres to the specified semver, and
-//	the WithUpdate option causes this to be utilized.
-func WithUpdate() func(*config) {
-	return func(c *config) { c.update = true }
-}
+	if got(cfg, res.Path) {
+		res, err = useInstalled(ctx, cfg, res.Path)
+		res.Version = version
+		return
+	}
 
-// setup ensures that the binr cache directory is available
-func setup() (err error) {
-	path := cachePath()
-	if _, err = os.Stat(path); os.IsNotExist(err) {
-		log.Debug().Str("path", path).Msg("creating local binr cache")
-		if err = os.MkdirAll(path, os.ModePerm); err != nil {
-			return fmt.Errorf("binr was unable to create cache directory. %w", err)
+	if cfg.noDowngrade {
+		if err = checkDowngrade(cfg, namespace, name, version); err != nil {
+			return
 		}
 	}
+
+	path := res.Path
+	res, cleanup, err := fetchForSystem(ctx, cfg, command, version, source)
+	res.Path, res.Version = path, version
 	if err != nil {
-		return fmt.Errorf("binr encountered an unexpected error accessing its cache. %w", err)
+		return
 	}
-	return
-}
-
-// cachePath returns the effective path to the binr cache.
-// In the event that there is neither a home directory nor an XDG_CONFIG_HOME
-// set, the relative path ".binr/bin" is used.
-func cachePath() (path string) {
-	path, _ = filepath.Abs(filepath.Join(dotfilesPath(), "binr", ".cache"))
-	return
-}
+	defer cleanup()
 
-// Path returns the absolute path at which the given command for
-// the given namespace is expected to exist.  It does not validate the
-// command's existence (see Get).
-//
-// Version is optional, and if not provided will point to a "floating"
-// link which is always updated to the current version.  If provided, it
-// must be a semver
-func Path(namespace, command, version string) (path string, err error) {
-	if namespace == "" {
-		return "", errors.New("binr Path requires namespace")
-	} else if command == "" {
-		return "", errors.New("binr Path requires command")
-	} else if version != "" {
-		if _, err := semver.NewVersion(version); err != nil {
-			return "", errors.New("binr Path requires version to be a valid semver (ex: v1.2.3)")
+	if cfg.metadataURL != nil {
+		if err = fetchMetadata(ctx, cfg, version, res.OS, res.Arch); err != nil {
+			return
 		}
-		command += "-" + version
 	}
-	return filepath.Abs(filepath.Join(dotfilesPath(), "binr", namespace, command))
-}
-
-// dotfilesPath returns ~/.config by default, XDG_CONFIG_HOME if set, or
-// In the event that there is neither a home directory nor an XDG_CONFIG_HOME
-// set, the relative path ".binr/bin" is used.
-func dotfilesPath() string {
-	var (
-		xdg           = os.Getenv("XDG_CONFIG_HOME")
-		home, homeErr = os.UserHomeDir()
-		dotfiles      = filepath.Join(home, ".config")
-	)
-	if homeErr != nil && xdg == "" {
-		log.Warn().Msg("binr found no home directory nor XDG_CONFIG_HOME environment variable.  The current working directory will be used.")
-		return "."
+	if cfg.assertVersion != nil {
+		if err = assertVersion(ctx, cfg, cfg.objectPath(res.Checksum), version); err != nil {
+			return
+		}
 	}
-	if xdg != "" {
-		dotfiles = xdg
+	if cfg.beforeLink != nil {
+		if err = beforeLink(cfg, res); err != nil {
+			return
+		}
 	}
-	return dotfiles
+	if err = link(cfg, namespace, name, version, res.Checksum); err != nil {
+		return
+	}
+	if res.Cached && cfg.onCacheHit != nil {
+		cfg.onCacheHit(res.Path)
+	}
+	cfg.log.Debug().Str("arch", res.Arch).Msg("binr completed without error")
+	return
 }
 
-// got the command already?
-func got(path string) bool {
-	if _, err := os.Stat(path); err != nil {
-		return false
+// served returns the Result of the version of the command if its link is
+// already installed, such that it is served as-is.  The Version of a
+// floating version's link is that of the newest exact version linked to
+// the same object, if any.  ok is false if the link is not installed.
+func served(ctx context.Context, cfg config, namespace, command, version string) (res Result, ok bool, err error) {
+	linked := version
+	if linked == latestVersion {
+		linked = "" // the unversioned link
 	}
-	// TODO: ensure it is a symlink
-	// TODO: ensure it points to a path in the store
-	// TODO: ensure target exists
-	// TODO: ensure target is executable
-	// TODO: ensure target filename is its hash
-	return true
+	path, err := cfg.linkPath(namespace, cfg.linkName(command), linked)
+	if err != nil || !got(cfg, path) {
+		return res, false, err
+	}
+	if res, err = useInstalled(ctx, cfg, path); err != nil {
+		return res, true, err
+	}
+	res.Version = version
+	if isFloating(version) {
+		res.Version = linkedVersion(cfg, namespace, command, path)
+	}
+	return res, true, nil
 }
 
-// getChecksum returns the checksum at the given URL if provided, empty string
-// otherwise.  If provided, any error turning the URL into a checksum is
-// bubbled.
-func getChecksum(ctx context.Context, url string) (string, error) {
-	if url == "" {
-		return "", nil
+// useInstalled returns the Result of the command installed at the link
+// path, verifying it WithVerifyCached.
+func useInstalled(ctx context.Context, cfg config, path string) (res Result, err error) {
+	cfg.log.Debug().Str("path", path).Msg("binr found command locally")
+	res.Path = path
+	target, linkErr := cfg.fs.Readlink(path)
+	if linkErr == nil {
+		res.Checksum, _ = objectChecksum(filepath.Base(target))
 	}
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return "", err
+	if cfg.verifyCached && res.Checksum != "" {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		if err = verifyObject(ctx, cfg, target, res.Checksum); err != nil {
+			return res, fmt.Errorf("binr installed command %v failed verification. %w", target, err)
+		}
 	}
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("binr was unable to fetch the command's checksum from url %q. %w", url, err)
+	res.Cached = true
+	if cfg.onCacheHit != nil {
+		cfg.onCacheHit(path)
 	}
-	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		return "", fmt.Errorf("binr received an HTTP %v from checksum URL %q", res.StatusCode, url)
+	return
+}
+
+// beforeLink invokes the config's before-link function for the fetched
+// command, removing the command from the cache if it is rejected and was
+// downloaded for this install (a command found in the cache may be shared).
+func beforeLink(cfg config, res Result) error {
+	path := cfg.objectPath(res.Checksum)
+	err := cfg.beforeLink(path, res.Checksum)
+	if err == nil {
+		return nil
 	}
-	bb, err := io.ReadAll(res.Body)
-	if err != nil {
-		return "", fmt.Errorf("binr received an error reading the checksum URL %q. %w", url, err)
+	if !res.Cached {
+		cfg.log.Debug().Str("path", path).Msg("binr removing command rejected before linking")
+		if rmErr := removeObject(cfg.fs, path); rmErr != nil {
+			cfg.warn(rmErr, path, "binr unable to remove rejected command")
+		}
 	}
-	return strings.TrimSpace(string(bb)), nil
-	// TODO: confirm the format of the body appears to be a checksum
+	return fmt.Errorf("binr command rejected before linking. %w", err)
 }
 
-// cache the binary at the given URL which should have the given checksum.
-// If a command already exists in the storw with the given checksum, it is
-// already cached and a fetch is not initiated.
-// The checksum is optional, used to check for cached copies and validate
-// download integrity if provided.
-// NOTE: future versions will consider the semver and staleness.
-func cache(ctx context.Context, url, checksum string) (sum string, done func(), err error) {
-	log.Debug().
-		Str("url", url).
-		Str("checksum", checksum).
-		Msg("binr sourcing command")
+// getUncached fetches the command into a new temporary directory rather
+// than the cache, returning its path without linking it.  See WithNoCache.
+func getUncached(ctx context.Context, cfg config, command, version string, source Source) (res Result, err error) {
+	if cfg.cacheDir, err = os.MkdirTemp("", "binr-"); err != nil {
+		return res, fmt.Errorf("binr unable to create temporary directory. %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(cfg.cacheDir)
+		}
+	}()
 
-	if cached(checksum) {
+	res, cleanup, err := fetchForSystem(ctx, cfg, command, version, source)
+	if err != nil {
 		return
 	}
+	defer cleanup()
 
-	t := time.Now()
-	tmpfile := filepath.Join(cachePath(), fmt.Sprint(t.Format("20060102150405.999"))+".partial")
-
-	done = func() {
-		log.Debug().Msg("binr cleaning up")
-		// TODO: in the event of a panic this deferred cleanup will not fire.
-		// This could be rearchitected by, for example, using a guid encoded
-		// in the partial filename and and PID.  Finalization then uses only the
-		// partial with the current GUID, and upon success removes all partials
-		// whose encoded pid is no longer a running process.  This cleanup could
-		// be run as an initial task in setup.
-		if _, err := os.Stat(tmpfile); os.IsNotExist(err) {
+	if cfg.metadataURL != nil {
+		if err = fetchMetadata(ctx, cfg, version, res.OS, res.Arch); err != nil {
 			return
 		}
-		if err := os.Remove(tmpfile); err != nil {
-			log.Warn().Err(err).Msg("binr unable to remove partial download.")
-		}
 	}
-
-	if err = download(ctx, url, tmpfile, "application/octet-stream"); err != nil {
-		return
-	}
-
-	if checksum == "" {
-		if checksum, err = calculateChecksum(tmpfile); err != nil {
+	if cfg.assertVersion != nil {
+		if err = assertVersion(ctx, cfg, cfg.objectPath(res.Checksum), version); err != nil {
 			return
 		}
-	} else {
-		if err = verify(tmpfile, checksum); err != nil {
+	}
+	if cfg.beforeLink != nil {
+		if err = beforeLink(cfg, res); err != nil {
 			return
 		}
 	}
-
-	newpath := filepath.Join(cachePath(), checksum)
-	log.Debug().
-		Str("from", tmpfile).
-		Str("to", newpath).
-		Msg("moving into place")
-
-	return checksum, done, os.Rename(tmpfile, newpath)
+	res.Path = filepath.Join(cfg.cacheDir, command)
+	if err = cfg.fs.Rename(cfg.objectPath(res.Checksum), res.Path); err != nil {
+		return res, fmt.Errorf("binr unable to name temporary command. %w", err)
+	}
+	cfg.log.Debug().Str("path", res.Path).Msg("binr downloaded uncached command")
+	return
 }
 
-// download the given url to the given output, (optionally) verifying the
-// content type
-func download(ctx context.Context, url, outPath, contentType string) error {
-	if _, err := os.Stat(outPath); err == nil {
-		return fmt.Errorf("binr encountered an existing download file. If you are sure it is from a failed earlier attempt, the file can be removed. %v", outPath)
+// fetchForSystem fetches the command for the current system into the cache,
+// trying any fallback architectures in order if the Source does not provide
+// it for the current architecture.
+func fetchForSystem(ctx context.Context, cfg config, command, version string, source Source) (res Result, done func(), err error) {
+	goos, goarch := cfg.platform()
+	res.OS = goos
+	arches := []string{goarch}
+	if strings.HasPrefix(goarch, "armv") {
+		arches = append(arches, "arm") // sources which do not publish variants
 	}
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return err
+	for _, arch := range append(arches, cfg.archFallback...) {
+		res.Arch = arch
+		var fetched Result
+		fetched, done, err = fetch(ctx, cfg, command, version, res.OS, arch, source)
+		res.Checksum, res.Cached = fetched.Checksum, fetched.Cached
+		res.DownloadDuration, res.DownloadBytes = fetched.DownloadDuration, fetched.DownloadBytes
+		res.ResolvedURL, res.Attempts = fetched.ResolvedURL, fetched.Attempts
+		if !errors.Is(err, ErrNotFound) {
+			break
+		}
+		cfg.log.Debug().Str("arch", arch).Err(err).Msg("binr found no command for arch")
 	}
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("binr received an http error fetching the command. %w", err)
+	if err == nil && res.Arch != goarch {
+		cfg.warn(nil, "", fmt.Sprintf("binr using the command for %v/%v, as none is published for %v/%v", res.OS, res.Arch, res.OS, goarch))
 	}
-	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		return fmt.Errorf("binr received an HTTP %v from source URL %q", res.StatusCode, url)
+	return
+}
+
+// GetReader returns a stream of the binary for the given version, verified
+// against its checksum, without installing it into a namespace.  It is
+// served from the cache if present, and is otherwise downloaded into the
+// cache first.  The caller is responsible for closing the reader.
+//
+// As there is no command name by which to select it, a binary published
+// within an archive must be its only file.
+func GetReader(ctx context.Context, version string, source Source, options ...option) (r io.ReadCloser, checksum string, err error) {
+	cfg := newConfig(options...)
+	traceID := cfg.trace(ctx)
+	defer func() { err = traceError(err, traceID) }()
+
+	if version == "" {
+		return nil, "", errors.New("binr GetReader requires a version")
+	} else if _, err := semver.NewVersion(version); err != nil {
+		return nil, "", errors.New("binr GetReader requires version to be a valid semver (ex: v1.2.3)")
+	} else if source == nil {
+		return nil, "", errors.New("binr GetReader requires a Source")
 	}
-	if res.Header.Get("Content-Type") != contentType {
-		return fmt.Errorf("binr unable to source command.  Source URL reported a content type of %q when an %q was expected", res.Header.Get("Content-Type"), contentType)
+
+	if err = setup(ctx, cfg); err != nil {
+		return
 	}
-	file, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	unlock, err := readLock(ctx, cfg)
 	if err != nil {
-		return fmt.Errorf("binr unable to open local file for writing. %w", err)
+		return
 	}
-	defer file.Close()
-	if _, err = io.Copy(file, res.Body); err != nil {
-		return fmt.Errorf("binr encoutered an error copying remote data. %w", err)
+	defer unlock()
+
+	res, cleanup, err := fetchForSystem(ctx, cfg, "", version, source)
+	if err != nil {
+		return
 	}
-	log.Debug().Str("path", outPath).Msg("binr download complete")
-	return nil
-}
+	defer cleanup()
 
-// cached returns whether or not the binary with the given checksum exists
-// in the cache.
-func cached(checksum string) bool {
-	if checksum == "" {
-		return false
+	file, err := cfg.fs.Open(cfg.objectPath(res.Checksum))
+	if err != nil {
+		return nil, "", fmt.Errorf("binr unable to open cached command. %w", err)
 	}
-	path := filepath.Join(cachePath(), checksum)
-	_, err := os.Stat(path)
-	return (err == nil)
+	return file, res.Checksum, nil
 }
 
-// verify the given path has the given checksum
-func verify(path, checksum string) (err error) {
-	fileChecksum, err := calculateChecksum(path)
+// UpdateAvailable reports whether the command installed in the namespace
+// for the given version differs from that currently published by the
+// Source, without downloading or relinking.  The Source must provide a
+// checksum, which is compared against that of the installed command.  A
+// command which is not installed is reported as having an update
+// available.  latest is the version which Get would install.
+//
+// Commands published within archives are not supported, as the checksum
+// published is that of the archive rather than the command.
+func UpdateAvailable(ctx context.Context, namespace, command, version string, source Source, options ...option) (available bool, latest string, err error) {
+	cfg := newConfig(options...)
+	traceID := cfg.trace(ctx)
+	defer func() { err = traceError(err, traceID) }()
+
+	if namespace == "" {
+		return false, "", errors.New("binr UpdateAvailable requires namespace")
+	} else if command == "" {
+		return false, "", errors.New("binr UpdateAvailable requires command")
+	} else if _, err := semver.NewVersion(version); err != nil {
+		return false, "", errors.New("binr UpdateAvailable requires version to be a valid semver (ex: v1.2.3)")
+	} else if source == nil {
+		return false, "", errors.New("binr UpdateAvailable requires a Source")
+	}
+
+	path, err := cfg.linkPath(namespace, command, version)
 	if err != nil {
 		return
 	}
-	if fileChecksum != checksum {
-		log.Debug().
-			Str("path", path).
-			Str("expected", checksum).
-			Str("calculated", fileChecksum).
-			Msg("checksum mismatch")
-		return errors.New("binr detected a checksum mismatch. Not sourcing command")
+	var current string
+	if target, err := cfg.fs.Readlink(path); err == nil {
+		current, _ = objectChecksum(filepath.Base(target))
 	}
-	return
-}
 
-// calculateChecksum of file at path.
-func calculateChecksum(filePath string) (string, error) {
-	file, err := os.Open(filePath)
+	goos, goarch := cfg.platform()
+	sourceURL, sumURL, err := resolve(cfg, source, version, goos, goarch)
 	if err != nil {
-		return "", fmt.Errorf("binr unable to calculate file's checksum. %w", err)
+		return
 	}
-	defer file.Close()
-
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", fmt.Errorf("binr unable to calculate file's checksum. %w", err)
+	if sumURL == "" {
+		return false, "", errors.New("binr UpdateAvailable requires a Source which provides a checksum")
+	} else if _, ok := archiveBySuffix(sourceURL); ok {
+		return false, "", fmt.Errorf("binr UpdateAvailable can not compare the installed command against an archive %q without downloading it", sourceURL)
 	}
-
-	// hashInBytes := hash.Sum(nil)[:32]
-	hashInBytes := hash.Sum(nil)
-	return hex.EncodeToString(hashInBytes), nil
-}
-
-// link a new command to the cached object with the given checksum
-func link(namespace, command, version, sum string) (err error) {
-	pathVersioned, err := Path(namespace, command, version)
+	sum, err := getChecksum(ctx, cfg, sumURL, sourceURL)
 	if err != nil {
 		return
 	}
-	target := filepath.Join("..", ".cache", sum)
-	log.Debug().
-		Str("target", target).
-		Str("path", pathVersioned).
-		Msg("linking versioned")
 
-	if err = os.Mkdir(filepath.Dir(pathVersioned), os.ModePerm); err != nil {
-		return
+	cfg.log.Debug().
+		Str("installed", current).
+		Str("published", sum).
+		Msg("binr checked for update")
+	if current != "" && (!isChecksum(sum) || !isChecksum(current)) { // published or cached in another algorithm
+		return verify(ctx, cfg, path, sum) != nil, version, nil
 	}
-	if err = os.Symlink(target, pathVersioned); err != nil {
+	return sum != current, version, nil
+}
+
+// fetch the command for the given version, os and arch from the source into
+// the cache, returning its checksum, whether it was already cached, and the
+// details of its download if not.
+func fetch(ctx context.Context, cfg config, command, version, os, arch string, source Source) (res Result, done func(), err error) {
+	sourceURL, sumURL, err := resolve(cfg, source, version, os, arch)
+	if err != nil {
 		return
 	}
 
-	if ok, err := isNewer(namespace, command, version); !ok || err != nil {
-		log.Debug().Msg("version linked is not newest. leaving unversioned link unchanged.")
-		return err
+	if _, inline := decodeChecksum(cfg.checksumEncoding, sumURL); cfg.parallelChecksum && sumURL != "" && !inline {
+		return fetchConcurrently(ctx, cfg, command, version, os, arch, sourceURL, sumURL)
 	}
 
-	pathUnversioned, err := Path(namespace, command, "")
+	sum, err := getChecksum(ctx, cfg, sumURL, sourceURL) // URL to checksum (optional)
 	if err != nil {
 		return
 	}
 
-	log.Debug().
-		Str("target", target).
-		Str("path", pathUnversioned).
-		Msg("updating unversioned link")
+	if cached(cfg, sum) {
+		cfg.log.Debug().Str("checksum", sum).Msg("binr found command in cache")
+		// The cache may be shared with systems which do not preserve its
+		// mode, such as another OS mounting it over the network.
+		if err = seal(cfg, cfg.objectPath(sum)); err != nil {
+			return
+		}
+		if cfg.verifyCached {
+			if err = verifyObject(ctx, cfg, cfg.objectPath(sum), sum); err != nil {
+				return res, nil, fmt.Errorf("binr cached command %v failed verification. %w", cfg.objectPath(sum), err)
+			}
+		}
+		return Result{Checksum: sum, Cached: true}, func() {}, nil
+	}
 
-	return os.Symlink(target, pathUnversioned)
+	var xfer transfer
+	res.Checksum, xfer, done, err = cache(ctx, cfg, command, version, os, arch, sourceURL, sum, nil) // returns actual sum if no sumURL provided
+	res.DownloadDuration, res.DownloadBytes = xfer.duration, xfer.bytes
+	res.ResolvedURL, res.Attempts = xfer.url, xfer.attempts
+	return
 }
 
-// isNewer returns true if the given version would become the latest
-// installed version of the command in the given namespace.
-func isNewer(namespace, command, versionStr string) (bool, error) {
-	dir := filepath.Join(dotfilesPath(), "binr", namespace)
+// fetchConcurrently is fetch, downloading the command while its checksum is
+// fetched rather than after.  The download is cancelled if the checksum can
+// not be fetched, and is verified once both are complete.
+func fetchConcurrently(ctx context.Context, cfg config, command, version, os, arch, sourceURL, sumURL string) (res Result, done func(), err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	version, err := semver.NewVersion(versionStr)
-	if err != nil {
-		return false, fmt.Errorf("binr can not determine if the given command is the latest because an invalid semver was received: %q", versionStr)
+	type fetched struct {
+		sum string
+		err error
 	}
-
-	files, err := os.ReadDir(dir)
-	if err != nil {
+	ch := make(chan fetched, 1)
+	go func() {
+		sum, err := getChecksum(ctx, cfg, sumURL, sourceURL)
+		if err != nil {
+			cancel() // the download
+		}
+		ch <- fetched{sum, err}
+	}()
+	var (
+		once     sync.Once
+		checksum fetched
+	)
+	awaitChecksum := func() (string, error) {
+		once.Do(func() { checksum = <-ch })
+		return checksum.sum, checksum.err
+	}
+
+	var xfer transfer
+	res.Checksum, xfer, done, err = cache(ctx, cfg, command, version, os, arch, sourceURL, "", awaitChecksum)
+	if err != nil && ctx.Err() != nil {
+		if _, sumErr := awaitChecksum(); sumErr != nil {
+			err = sumErr // which cancelled the download
+		}
+	}
+	res.DownloadDuration, res.DownloadBytes = xfer.duration, xfer.bytes
+	res.ResolvedURL, res.Attempts = xfer.url, xfer.attempts
+	return
+}
+
+// transfer describes a completed download.
+type transfer struct {
+	duration time.Duration
+	bytes    int64
+	url      string // which served the download
+	attempts int
+}
+
+// Source is a function which, when provided a version, OS and architecture
+// will return the urls at which the binary and its checksum can be found.
+//
+// The returned sum may also be the checksum itself (a hex-encoded sha256,
+// or another digest whose algorithm is inferred from its length) rather
+// than a URL, in which case it is used directly without a fetch.
+// This is useful when the checksum is already known, such as when pinned
+// in configuration.  See InlineSource.
+type Source func(version, os, arch string) (url, sum string, err error)
+
+// SourceProvider is a Source implemented as an interface, such that it may
+// hold state such as an authenticated client or a cache of a releases API's
+// responses.  Use ProviderSource to provide one wherever a Source is
+// accepted.  A Source is itself a SourceProvider.
+type SourceProvider interface {
+	Resolve(version, os, arch string) (url, sum string, err error)
+}
+
+// Resolve the URLs of the binary and its checksum, implementing
+// SourceProvider.
+func (s Source) Resolve(version, os, arch string) (url, sum string, err error) {
+	return s(version, os, arch)
+}
+
+// ErrInvalidURL is returned (wrapped) when a Source returns a URL which is
+// not absolute or is of a scheme other than http or https.
+var ErrInvalidURL = errors.New("binr Source returned an invalid URL")
+
+// resolve the URLs of the binary and its checksum using the source,
+// returning an error if either is invalid.  An inline checksum is not a URL
+// and is therefore not validated as one.
+func resolve(cfg config, source Source, version, os, arch string) (url, sum string, err error) {
+	if url, sum, err = source(version, os, arch); err != nil {
+		return
+	}
+	if err = checkSourceURL(url); err != nil {
+		return
+	}
+	if _, inline := decodeChecksum(cfg.checksumEncoding, sum); sum != "" && !inline {
+		if err = hexDigestError(sum); err != nil && (cfg.checksumEncoding == "" || cfg.checksumEncoding == "hex") {
+			return
+		}
+		err = checkSourceURL(sum)
+	}
+	return
+}
+
+// checkSourceURL returns an error if the URL returned by a Source is not
+// absolute with a scheme of http or https.
+func checkSourceURL(rawURL string) error {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w %q. %v", ErrInvalidURL, rawURL, err)
+	}
+	if scheme := strings.ToLower(u.Scheme); (scheme != "http" && scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w %q. Expected an absolute http or https URL", ErrInvalidURL, rawURL)
+	}
+	return nil
+}
+
+// ProviderSource returns a Source which resolves using the given provider.
+func ProviderSource(p SourceProvider) Source {
+	return p.Resolve
+}
+
+// InlineSource returns a Source which resolves the binary's URL using the
+// given source, but always reports the given checksum rather than a
+// checksum URL.
+func InlineSource(checksum string, source Source) Source {
+	return func(version, os, arch string) (url, sum string, err error) {
+		url, _, err = source(version, os, arch)
+		return url, checksum, err
+	}
+}
+
+// DirectorySource returns a Source for mirrors which publish releases
+// already extracted, rather than as archives.  The given source resolves
+// the URL of the directory of a release, and the command is downloaded
+// from the member path within it (such as "bin/mytool").  The checksum, if
+// any, is that reported by the given source, and should be that of the
+// member.
+func DirectorySource(source Source, member string) Source {
+	return func(version, os, arch string) (string, string, error) {
+		dir, sum, err := source(version, os, arch)
+		if err != nil {
+			return "", "", err
+		}
+		u, err := neturl.Parse(dir)
+		if err != nil {
+			return "", "", fmt.Errorf("binr unable to parse directory URL %q. %w", dir, err)
+		}
+		return u.JoinPath(member).String(), sum, nil
+	}
+}
+
+// Verifier is a function which verifies a download, such as against a
+// signature published alongside it.  It is provided the path of the file
+// downloaded (which, for an archive, is the archive as published) and the
+// version, OS and architecture for which it was sourced.  The file should
+// be read via VerifierFilesystem, and any resource such as a signature
+// requested via VerifierRequest, such that the Verifier is held to the
+// options of the Get which invokes it.  See WithVerifier.
+type Verifier func(ctx context.Context, path, version, os, arch string) error
+
+// verifierKey is the key of the config in the context given a Verifier.
+type verifierKey struct{}
+
+// VerifierRequest requests the given URL with the request policy of the Get
+// which provided ctx to a Verifier, such as its allowed hosts (see
+// WithAllowedHosts).  Outside a Verifier the default policy applies.
+func VerifierRequest(ctx context.Context, method, url string) (*http.Response, error) {
+	return request(ctx, verifierConfig(ctx), method, url, nil)
+}
+
+// VerifierFilesystem returns the Filesystem of the Get which provided ctx
+// to a Verifier (see WithFilesystem), from which the download it is given
+// is read.  Outside a Verifier it is the filesystem of the operating system.
+func VerifierFilesystem(ctx context.Context) Filesystem {
+	return verifierConfig(ctx).fs
+}
+
+// verifierConfig returns the config of the Get which provided ctx to a
+// Verifier, or the default config.
+func verifierConfig(ctx context.Context) config {
+	if cfg, ok := ctx.Value(verifierKey{}).(config); ok {
+		return cfg
+	}
+	return newConfig()
+}
+
+// config is mutated by functional options for Get such as WithUpdate
+type config struct {
+	update              bool
+	archFallback        []string
+	validateExecutable  bool
+	resumeVerify        bool
+	assertVersion       func(output string) (string, error)
+	verifyCached        bool
+	verifyWindow        time.Duration
+	hasher              Hasher
+	swapOnUpdate        bool
+	persistPartials     bool
+	onCacheHit          func(path string)
+	onDownload          func(url string)
+	leaseTimeout        time.Duration
+	cleanOnError        bool
+	linked              *linkJournal
+	preflight           bool
+	tempNamer           func() string
+	platformHeaderCheck bool
+	keepArchive         string
+	memberPattern       *regexp.Regexp
+	includePrereleases  bool
+	noDowngrade         bool
+	parallelChecksum    bool
+	reconcileLatest     bool
+	allowedHosts        []string
+	cacheDir            string
+	configRoot          string
+	traceExtractor      func(context.Context) string
+	fs                  Filesystem
+	beforeLink          func(path, checksum string) error
+	onNewObject         func(checksum, path string) error
+	limiters            []hostLimiter
+	verifyTimeout       time.Duration
+	pinsFile            string
+	strictPins          bool
+	maxBandwidth        int64
+	minFreeDisk         int64
+	bodyWrapper         func(io.Reader) (io.Reader, error)
+	responseValidator   func(*http.Response) error
+	verifiers           []Verifier
+	downloadBufferSize  int
+	goos, goarch        string
+	retries             int
+	noCache             bool
+	checksumFormat      ChecksumFormat
+	checksumEncoding    string
+	writableCache       bool
+	archVariant         string
+	fsync               bool
+	httpClient          *http.Client
+	userAgent           string
+	certificatePin      string
+	allowInsecureHTTP   bool
+	credentialProvider  func(ctx context.Context, url string) (header, value string, err error)
+	urlRewriter         func(string) string
+	metadataURL         func(version, os, arch string) string
+	metadataDest        func(meta []byte) error
+	metadataRequired    bool
+	mirrors             []string
+	adaptiveMirrors     bool
+	startupVerify       bool
+	multihashNaming     bool
+	clock               func() time.Time
+	tolerateChmodErrors bool
+	lister              Lister
+	log                 zerolog.Logger
+	warnings            *warnings
+	hashed              *hashed
+}
+
+type option func(*config)
+
+func newConfig(options ...option) (cfg config) {
+	cfg.leaseTimeout = DefaultLeaseTimeout
+	cfg.log = log.Logger
+	cfg.tempNamer = timestampNamer
+	cfg.fs = osFilesystem{}
+	cfg.hasher = sha256Hasher{}
+	cfg.clock = time.Now
+	for _, option := range options {
+		option(&cfg)
+	}
+	return
+}
+
+// WithUpdate instructs Get to also update each of the command's floating
+// links in the namespace to the newest release satisfying it, installing
+// that release if necessary.  The unversioned link is updated to the
+// newest release ("latest"), a major link (vX) to the newest vX.Y.Z, and a
+// minor link (vX.Y) to the newest vX.Y.Z.  Links to exact versions are
+// never changed.  The default behavior is to never replace a binary once
+// it has been provided.  Requires a Lister (see WithLister).
+func WithUpdate() func(*config) {
+	return func(c *config) { c.update = true }
+}
+
+// WithSwapOnUpdate instructs WithUpdate to fetch and verify every release
+// to which a floating link is to be updated before any link is changed.
+// Releases already installed are verified against their checksum in the
+// cache, and new releases are also passed to the before-link function (see
+// WithBeforeLink) and validated if requested (see WithValidateExecutable).
+// Only once each has succeeded are the links swapped, such that a failed
+// update leaves the currently installed commands untouched.
+func WithSwapOnUpdate() func(*config) {
+	return func(c *config) { c.update, c.swapOnUpdate = true, true }
+}
+
+// WithLister provides the Lister of the command's available releases, by
+// which floating versions (vX, vX.Y and "latest") are resolved.  See Get
+// and WithUpdate.
+func WithLister(l Lister) func(*config) {
+	return func(c *config) { c.lister = l }
+}
+
+// WithArchFallback provides alternate architectures to try, in order, when
+// the Source has no command for the current system's architecture (its URL
+// responds 404).  For example, an arm64 Mac may fall back to an amd64 build
+// which runs under emulation:
+//
+//	WithArchFallback([]string{"amd64"})
+//
+// The architecture ultimately used is recorded in the Result.
+func WithArchFallback(arches []string) func(*config) {
+	return func(c *config) { c.archFallback = arches }
+}
+
+// WithValidateExecutable instructs the system to confirm that a downloaded
+// command can actually be executed on this system before it is installed.
+// The command is run with a --help flag and a short timeout, and any failure
+// of the system to execute it (for example a binary built for a different
+// architecture, or a missing dynamic loader) fails the install.  Commands
+// which run but exit non-zero, or which do not exit before the timeout, are
+// considered executable.
+func WithValidateExecutable() func(*config) {
+	return func(c *config) { c.validateExecutable = true }
+}
+
+// WithAssertVersionMatch instructs Get to confirm that a command reports
+// the version requested before it is linked, catching mislabeled releases.
+// The command is run with a --version flag, and its output provided to the
+// extract function, which returns the version reported.  The install fails
+// unless the version extracted is semantically equal to that requested
+// (such that a "v" prefix is not significant), or if the command does not
+// exit successfully.  As with WithValidateExecutable, the command must be
+// executable by the operating system.
+func WithAssertVersionMatch(extract func(output string) (string, error)) func(*config) {
+	return func(c *config) { c.assertVersion = extract }
+}
+
+// WithIncludePrereleases determines whether a pre-release (such as
+// v2.0.0-rc.1) is eligible to become the latest version of a command, to
+// which its unversioned link points.  By default it is not: pre-releases
+// are linked only by their version, and the unversioned link remains that of
+// the newest release installed.  A pre-release becomes the latest only if
+// no release is installed.
+func WithIncludePrereleases(include bool) func(*config) {
+	return func(c *config) { c.includePrereleases = include }
+}
+
+// WithNoDowngrade refuses to install a version of a command lower than one
+// already installed in the namespace, failing with ErrDowngrade, for those
+// who must not downgrade.  Versions already installed continue to be
+// provided.
+func WithNoDowngrade() func(*config) {
+	return func(c *config) { c.noDowngrade = true }
+}
+
+// WithReconcileLatest ensures on every Get that the unversioned link of the
+// command targets the newest version installed, repairing it should it have
+// drifted, such as by being modified manually.  Otherwise it is updated
+// only when the version installed is the newest.
+func WithReconcileLatest() func(*config) {
+	return func(c *config) { c.reconcileLatest = true }
+}
+
+// WithVerifyCached instructs Get, Link and Checksum to verify the cached
+// object against its checksum, rather than trusting the cache, such that a
+// command corrupted on disk is detected.  See WithVerifyWindow to limit how
+// often a large command is hashed.
+func WithVerifyCached() func(*config) {
+	return func(c *config) { c.verifyCached = true }
+}
+
+// WithHasher calculates the checksums by which commands are addressed in the
+// cache with the given Hasher rather than sha256, such as a faster
+// non-cryptographic hash to reduce the time spent hashing large commands.
+// A command is addressed by the hasher's checksum when its Source does not
+// publish a sha256 (including when it is extracted from an archive, or
+// published in another algorithm).  Commands with a published sha256 are
+// verified against it and addressed by it, as are commands already cached.
+//
+// Security: the checksum of a command addressed by the hasher only
+// identifies it in the cache; it does not protect it.  With a
+// non-cryptographic hash, a command in the cache can be replaced by another
+// with the same checksum, and so go undetected by verification (see
+// WithVerifyCached, VerifyAll and Doctor).  Published checksums continue to
+// be verified in their own algorithm regardless.  Provide the same hasher
+// to functions which verify the cache, which otherwise can not verify
+// commands addressed by it.
+func WithHasher(h Hasher) func(*config) {
+	return func(c *config) { c.hasher = h }
+}
+
+// WithVerifyWindow skips the verification of a cached object (see
+// WithVerifyCached) if it was verified within the given window and has not
+// been modified since, such that verification is affordable for commands
+// which are large or frequently invoked.  Successful verifications are
+// recorded in a ".verified" file alongside the object.
+func WithVerifyWindow(window time.Duration) func(*config) {
+	return func(c *config) { c.verifyWindow = window }
+}
+
+// WithOnCacheHit registers a function to be invoked with the command's path
+// when it is provided from the local store without a download.  This
+// includes commands already installed in the namespace and those linked
+// from an object cached by another namespace.
+func WithOnCacheHit(f func(path string)) func(*config) {
+	return func(c *config) { c.onCacheHit = f }
+}
+
+// WithOnDownload registers a function to be invoked with the source URL
+// immediately before a command is downloaded.
+func WithOnDownload(f func(url string)) func(*config) {
+	return func(c *config) { c.onDownload = f }
+}
+
+// WithLeaseTimeout sets how long a download lease may go unrenewed before it
+// is considered abandoned.  When the checksum of a command is known in
+// advance (the Source provides one), only one process at a time downloads
+// it, with others waiting for that download to complete and then using its
+// result.  See DefaultLeaseTimeout, which is used if d is not positive.
+func WithLeaseTimeout(d time.Duration) func(*config) {
+	return func(c *config) {
+		if c.leaseTimeout = d; d <= 0 {
+			c.leaseTimeout = DefaultLeaseTimeout
+		}
+	}
+}
+
+// WithCleanOnError instructs the system to roll back an install which fails
+// once linking has begun, removing any links it created and restoring any
+// it replaced to their previous targets.  This includes failures after the
+// command is linked, such as updating its floating links (see WithUpdate).
+// Without this option, a failure (for example to create the unversioned
+// link) may leave the versioned link in place.  Cached objects are never
+// removed, as they may be shared.
+func WithCleanOnError() func(*config) {
+	return func(c *config) { c.cleanOnError = true }
+}
+
+// WithPreflight instructs the system to confirm the command exists at its
+// source URL with an HTTP HEAD request before beginning the download.  This
+// fails fast with a clear error when, for example, a Source's URL template
+// is incorrect.  Servers which do not support HEAD are not preflighted.
+func WithPreflight() func(*config) {
+	return func(c *config) { c.preflight = true }
+}
+
+// WithResumeVerify instructs Get to retain a partial download which fails,
+// such as when the process is interrupted, and to resume it with an HTTP
+// Range request on the next attempt only if it is consistent with its
+// source, rather than learning otherwise only when the completed download
+// fails verification.  The ETag and Last-Modified of the response from
+// which a partial is written are recorded alongside it, and a partial is
+// resumed only if they are unchanged and the size of the source reported
+// by the server agrees with the partial.  Otherwise the partial is
+// discarded and the download restarted.  Partials are named by the
+// command's checksum, and so are only retained for Sources which provide
+// one.  Partials retained by WithPersistPartials without this option are
+// not resumed, as their consistency can not be established.
+func WithResumeVerify() func(*config) {
+	return func(c *config) { c.resumeVerify = true }
+}
+
+// WithTempNamer provides the function used to name partial downloads in the
+// cache (to which a .partial extension is added).  Names must be unique
+// among concurrent downloads.  The default is a timestamp.  This is
+// primarily useful for tests which assert on intermediate files.
+func WithTempNamer(f func() string) func(*config) {
+	return func(c *config) { c.tempNamer = f }
+}
+
+// timestampNamer is the default temp namer, naming partial downloads by
+// the current time.
+func timestampNamer() string {
+	return time.Now().Format("20060102150405.999")
+}
+
+// WithPlatformHeaderCheck instructs the system to confirm that a downloaded
+// command was built for the requested os and architecture by inspecting its
+// executable header (ELF, Mach-O or PE).  A command for another platform is
+// not installed, and the error names the platform detected.
+func WithPlatformHeaderCheck() func(*config) {
+	return func(c *config) { c.platformHeaderCheck = true }
+}
+
+// WithKeepArchive instructs the system to retain a copy of any verified
+// archive from which a command is extracted in the given directory, named
+// as it was published (the filename of its source URL).  The directory is
+// created if necessary.  This is useful for retaining files distributed
+// alongside a command such as licenses.
+func WithKeepArchive(dir string) func(*config) {
+	return func(c *config) { c.keepArchive = dir }
+}
+
+// WithArchiveMemberPattern selects the command extracted from an archive as
+// the single regular file whose path within the archive matches the given
+// pattern, rather than by the command's name.  This accommodates archives
+// which nest the command beneath a directory which varies by release, such
+// as "mytool-1.2.3/bin/mytool".  Extraction fails if no file, or more than
+// one, matches.  A single compressed file (such as mytool-linux.gz) must
+// match by its name without the extension of its compression.
+func WithArchiveMemberPattern(pattern *regexp.Regexp) func(*config) {
+	return func(c *config) { c.memberPattern = pattern }
+}
+
+// WithAllowedHosts restricts the hosts from which commands and checksums
+// may be downloaded.  Hosts are either exact (example.com) or a wildcard
+// matching any subdomain (*.example.com).  The URLs provided by the Source
+// are checked before any request is made, such that a misconfigured or
+// compromised Source can not direct binr elsewhere.
+func WithAllowedHosts(hosts []string) func(*config) {
+	return func(c *config) { c.allowedHosts = hosts }
+}
+
+// WithVerifier adds a Verifier which must accept each download before it
+// is installed.  Verifiers run after the download's checksum is verified,
+// in the order provided.  Commands already in the cache are not downloaded
+// and so are not re-verified.
+func WithVerifier(v Verifier) func(*config) {
+	return func(c *config) { c.verifiers = append(c.verifiers, v) }
+}
+
+// WithVerifyTimeout limits the time spent calculating the checksums of a
+// download, such that a hung disk does not block indefinitely.  It is
+// separate from the time allowed for the download itself (which is
+// limited by the context).  A download whose verification times out is
+// removed and not installed.
+func WithVerifyTimeout(d time.Duration) func(*config) {
+	return func(c *config) { c.verifyTimeout = d }
+}
+
+// WithPinsFile provides a file which pins commands to a version, and
+// optionally a checksum, regardless of the version requested.  This ensures,
+// for example, that everyone sharing a repository uses the same version of
+// a tool.  Each line of the file pins one command:
+//
+//	<namespace>/<command> <version> [checksum]
+//
+// Blank lines and those beginning with # are ignored, and a file which does
+// not exist pins nothing.  A pinned checksum is used in place of that of
+// the Source.  See WithStrictPins.
+func WithPinsFile(path string) func(*config) {
+	return func(c *config) { c.pinsFile = path }
+}
+
+// WithStrictPins causes requests for a version other than that pinned (see
+// WithPinsFile) to fail rather than use the pinned version.
+func WithStrictPins() func(*config) {
+	return func(c *config) { c.strictPins = true }
+}
+
+// WithURLRewriter rewrites the URL of each request made, including those
+// of checksums, immediately before it is made.  This routes downloads
+// through a caching proxy or mirror without modifying each Source, for
+// example:
+//
+//	WithURLRewriter(func(u string) string {
+//		return "https://cache.internal/proxy?url=" + url.QueryEscape(u)
+//	})
+//
+// The request policy, such as allowed hosts and rate limits, applies to the
+// rewritten URL.
+func WithURLRewriter(f func(url string) string) func(*config) {
+	return func(c *config) { c.urlRewriter = f }
+}
+
+// WithHTTPClient sets the client by which all requests are made, such as
+// one with a proxy, custom TLS configuration or timeouts.  The default is
+// http.DefaultClient.
+func WithHTTPClient(client *http.Client) func(*config) {
+	return func(c *config) { c.httpClient = client }
+}
+
+// WithUserAgent sets the User-Agent header of all requests, such that a
+// server can identify the tool provisioning its commands.
+func WithUserAgent(userAgent string) func(*config) {
+	return func(c *config) { c.userAgent = userAgent }
+}
+
+// WithAllowInsecureHTTP permits requests of plain http URLs.  By default
+// only https URLs are requested, with the exception of those of the
+// loopback interface (such as localhost or 127.0.0.1) for testing, and
+// redirects to http URLs are not followed.
+func WithAllowInsecureHTTP() func(*config) {
+	return func(c *config) { c.allowInsecureHTTP = true }
+}
+
+// WithCertificatePin pins the certificate of the hosts from which commands
+// and checksums are requested, defending against the compromise of a
+// certificate authority.  The pin is the hex-encoded sha256 of either a
+// certificate presented by the host or its public key (SubjectPublicKeyInfo),
+// and a connection presenting no certificate which matches is rejected even
+// if its chain is trusted.  Requests must therefore use https, including
+// those redirected, and all hosts requested must present the pinned
+// certificate.  See also WithHTTPClient, whose transport must be an
+// *http.Transport.
+func WithCertificatePin(sha256 string) func(*config) {
+	return func(c *config) { c.certificatePin = sha256 }
+}
+
+// WithCredentialProvider registers a function which is invoked immediately
+// before each request with the URL requested, returning a header (such as
+// "Authorization") and its value to be sent with it.  Credentials are thus
+// obtained per request, such that short-lived tokens may be minted as they
+// expire.  An empty header adds nothing to the request, and an error aborts
+// it.  The function is provided every URL requested, including those of
+// checksums, and so should only return credentials for hosts which require
+// them.  The header is not sent on a redirect to another host.
+func WithCredentialProvider(f func(ctx context.Context, url string) (header, value string, err error)) func(*config) {
+	return func(c *config) { c.credentialProvider = f }
+}
+
+// WithRateLimiter applies the given rate limiter to all requests to the
+// host, which is either exact (example.com) or a wildcard matching any
+// subdomain (*.example.com).  Each request, including those for checksums,
+// waits for the limiter before it is made.  A limiter may be shared by
+// calls to Get (and across hosts) to limit their combined rate.  When
+// several hosts match, the limiter provided first is used.
+func WithRateLimiter(host string, limiter *rate.Limiter) func(*config) {
+	return func(c *config) {
+		c.limiters = append(c.limiters, hostLimiter{host: host, limiter: limiter})
+	}
+}
+
+// WithMaxBandwidth limits the rate at which commands are downloaded to the
+// given number of bytes per second, such that binr does not starve other
+// users of a constrained network.  Checksums are not limited.
+func WithMaxBandwidth(bytesPerSec int64) func(*config) {
+	return func(c *config) { c.maxBandwidth = bytesPerSec }
+}
+
+// WithMinFreeDisk refuses to begin a download which would leave less than
+// the given number of bytes free on the filesystem of the cache, failing
+// with ErrInsufficientDisk rather than filling the disk part way through.
+// The check requires the server report the download's Content-Length, and
+// is skipped where it does not, or where the free space can not be
+// determined (such as with a Filesystem other than the OS's).
+func WithMinFreeDisk(bytes int64) func(*config) {
+	return func(c *config) { c.minFreeDisk = bytes }
+}
+
+// WithResponseValidator registers a function which is invoked with the
+// response of each download once its headers are received, before its
+// body is read, such as to reject the error responses of an API which
+// reports errors with a successful status.  An error returned fails the
+// download.  The function should not read the response's body.
+func WithResponseValidator(f func(*http.Response) error) func(*config) {
+	return func(c *config) { c.responseValidator = f }
+}
+
+// WithBodyWrapper registers a function which wraps the body of each
+// download, such as to decrypt the commands of an encrypted mirror or to
+// decompress a custom format.  The content read from the wrapper is that
+// written to disk, and so is that verified against the checksum.  An error
+// returned by the function, or read from the wrapper, fails the download.
+// As a wrapper's output can not be resumed part way, partial downloads are
+// not retained (see WithPersistPartials).
+func WithBodyWrapper(f func(io.Reader) (io.Reader, error)) func(*config) {
+	return func(c *config) { c.bodyWrapper = f }
+}
+
+// WithDownloadBufferSize sets the size of the buffer with which downloads
+// are written to disk.  Larger buffers reduce syscall overhead on very fast
+// networks.  The default is that of io.Copy (32KB).
+func WithDownloadBufferSize(n int) func(*config) {
+	return func(c *config) { c.downloadBufferSize = n }
+}
+
+// WithPlatform provisions the command for the given OS and architecture
+// rather than those of the current system, such as when preparing a bundle
+// for another system.  The Source is asked for the given platform, and the
+// command's links are qualified by it such that installs for several
+// platforms do not overwrite one another:
+//
+//	~/.config/binr/[namespace]/[command]-[os]-[arch]-[version]
+//	~/.config/binr/[namespace]/[command]-[os]-[arch]
+//
+// The latter is the newest version installed for that platform.  Commands
+// installed without WithPlatform are not qualified.
+func WithPlatform(os, arch string) func(*config) {
+	return func(c *config) { c.goos, c.goarch = os, arch }
+}
+
+// WithArchVariant sets the variant of the 32-bit ARM architecture (such as
+// "v6" or "v7") for which commands are provisioned.  The Source is asked
+// for the combined architecture (armv7) rather than "arm", falling back to
+// "arm" if it provides no command for the variant.  The default is the
+// variant for which the current program was built (GOARM).  Has no effect
+// for other architectures.
+func WithArchVariant(variant string) func(*config) {
+	return func(c *config) { c.archVariant = variant }
+}
+
+// platform returns the OS and architecture for which commands are
+// provisioned, which is the current system's unless WithPlatform.  The
+// 32-bit ARM architecture is qualified by its variant (see WithArchVariant).
+func (c config) platform() (goos, goarch string) {
+	goos, goarch = c.goos, c.goarch
+	variant := c.archVariant
+	if c.goos == "" && c.goarch == "" {
+		goos, goarch = runtime.GOOS, runtime.GOARCH
+		if variant == "" {
+			variant = buildArmVariant()
+		}
+	}
+	if goarch == "arm" && variant != "" {
+		goarch = "armv" + strings.TrimPrefix(variant, "v")
+	}
+	return
+}
+
+// buildArmVariant returns the ARM variant (GOARM) for which the current
+// program was built, if known.
+func buildArmVariant() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "GOARM" {
+			variant, _, _ := strings.Cut(setting.Value, ",") // such as 7,softfloat
+			return variant
+		}
+	}
+	return ""
+}
+
+// linkName returns the name by which the command is linked, which is
+// qualified by the platform if provided by WithPlatform.
+func (c config) linkName(command string) string {
+	if c.goos == "" && c.goarch == "" {
+		return command
+	}
+	return command + "-" + c.goos + "-" + c.goarch
+}
+
+// WithParallelChecksum fetches a command's checksum from its checksum URL
+// while downloading the command, rather than before, verifying the command
+// once both are complete.  The latency of a download is then roughly the
+// greater of the two rather than their sum, which is significant for a
+// slow checksum endpoint.  The download is cancelled if the checksum can
+// not be fetched.  As the checksum is not known beforehand, the command is
+// downloaded even if it is already cached by another namespace.
+func WithParallelChecksum() func(*config) {
+	return func(c *config) { c.parallelChecksum = true }
+}
+
+// WithMirrors adds mirrors from which a command is downloaded should its
+// download from the source's URL fail.  Each is a base URL (such as
+// https://mirror.example.com/releases) which serves the same paths as the
+// source's host, and they are tried in the order given after the source's
+// URL.  Checksums are fetched only from the source.  See also
+// WithAdaptiveMirrors.
+func WithMirrors(mirrors ...string) func(*config) {
+	return func(c *config) { c.mirrors = append(c.mirrors, mirrors...) }
+}
+
+// WithAdaptiveMirrors orders the source's URL and its mirrors (see
+// WithMirrors) by their measured latency rather than as declared.  The
+// latency of each download from a mirror (the time to its response
+// headers, such that it is independent of the size of the command), or
+// its failure, is remembered for the life of the process as an
+// exponentially-weighted mean, and mirrors not yet measured are tried
+// first, such that the order is as declared until each has been measured.
+func WithAdaptiveMirrors() func(*config) {
+	return func(c *config) { c.adaptiveMirrors = true }
+}
+
+// WithRetries sets the number of times a failed download is retried, with
+// a short delay which increases with each attempt.  Each attempt downloads
+// to a fresh partial file, and those of failed attempts are removed.
+// Downloads which were not found (HTTP 404), whose host is not allowed, or
+// whose context is done are not retried.
+func WithRetries(n int) func(*config) {
+	return func(c *config) { c.retries = n }
+}
+
+// WithPersistPartials instructs Get to retain a partial download which
+// fails, such as when the process is interrupted, and to resume it with an
+// HTTP Range request on the next attempt, including retries (see
+// WithRetries).  This suits large commands on unreliable connections.
+// Partials are named by the command's checksum, and so are only retained
+// for Sources which provide one.  A partial is removed once the download
+// is complete and the command verified, or if it fails verification.
+// Servers which do not support ranges are downloaded from the start.  See
+// WithResumeVerify to establish that a partial is consistent with its
+// source before resuming it.
+func WithPersistPartials() func(*config) {
+	return func(c *config) { c.persistPartials = true }
+}
+
+// WithNoCache instructs Get to download the command to a new temporary
+// directory, returning its path there, rather than caching and linking it.
+// Nothing is persisted in the binr directory.  The caller owns the
+// temporary directory, and should remove it (the directory of the path
+// returned) when the command is no longer needed.  This suits one-shot
+// tools such as those of short-lived CI steps.
+func WithNoCache() func(*config) {
+	return func(c *config) { c.noCache = true }
+}
+
+// WithChecksumEncoding sets the encoding in which the Source's checksums
+// are published:
+//
+//	hex     a hex-encoded sha256 (the default)
+//	base64  a base64-encoded sha256
+//	sri     the Subresource Integrity format <algorithm>-<base64>, where
+//	        the algorithm is one of sha256, sha384 or sha512
+//
+// The published checksum is decoded and compared against the digest of the
+// download in the published algorithm.  Commands are always cached by
+// their sha256 regardless.  Hex-encoded sha256 checksums, such as those of
+// InlineSource and pins, are accepted with any encoding.
+func WithChecksumEncoding(encoding string) func(*config) {
+	return func(c *config) { c.checksumEncoding = encoding }
+}
+
+// WithChecksumFormat provides the parser of the lines of the Source's
+// checksum files, for formats which are not understood by default.  The
+// entry whose filename is that of the command's URL (or is the URL itself)
+// is used.  By default, the formats of both GNUChecksumFormat and
+// BSDChecksumFormat are understood.
+func WithChecksumFormat(format ChecksumFormat) func(*config) {
+	return func(c *config) { c.checksumFormat = format }
+}
+
+// WithWritableCache leaves objects in the cache writable.  By default each
+// object is made read-only once installed (see objectMode), such that
+// accidental writes are prevented and Doctor can report those which were
+// made writable.  This option is for filesystems which do not support
+// file permissions.
+func WithWritableCache() func(*config) {
+	return func(c *config) { c.writableCache = true }
+}
+
+// WithFsync instructs the system to flush each command to stable storage
+// before it is moved into the cache, and the cache directory after, such
+// that a crash or power loss can not leave a cached object which exists but
+// is incomplete.  This is off by default as it slows installs.
+func WithFsync() func(*config) {
+	return func(c *config) { c.fsync = true }
+}
+
+// WithStartupVerify instructs the system to verify every object in the
+// cache against its checksum before providing a command.  Objects which
+// fail, such as those truncated by a crash, are renamed with the suffix
+// .corrupt and a warning logged, such that they are downloaded again
+// rather than provided.  This reads the entire cache, so is best suited to
+// small caches or infrequent use such as on boot.
+func WithStartupVerify() func(*config) {
+	return func(c *config) { c.startupVerify = true }
+}
+
+// WithMultihashNaming names objects added to the cache by the hex-encoded
+// multihash of their checksum (1220<sha256>) rather than the bare checksum,
+// such that the algorithm is discoverable from the name, as is common in
+// content-addressed systems.  Objects named either way are recognized
+// regardless, and checksums reported (such as Result.Checksum) are bare.
+func WithMultihashNaming() func(*config) {
+	return func(c *config) { c.multihashNaming = true }
+}
+
+// WithClock sets the source of the current time by which the age of files
+// such as download leases is judged, such that time-based behavior can be
+// tested deterministically.  The default is time.Now.
+func WithClock(now func() time.Time) func(*config) {
+	return func(c *config) { c.clock = now }
+}
+
+// WithTolerateChmodErrors instructs the system to continue if the mode of a
+// cached object can not be set, provided it is already executable.  This
+// is for filesystems such as some network mounts on which chmod fails.
+// Objects which are not executable are not provided regardless.
+func WithTolerateChmodErrors() func(*config) {
+	return func(c *config) { c.tolerateChmodErrors = true }
+}
+
+// WithCacheDir sets the directory in which commands are cached.  The
+// default is a .cache directory within the binr directory
+// (~/.config/binr/.cache).  See MigrateCache for moving an existing cache.
+func WithCacheDir(dir string) func(*config) {
+	return func(c *config) { c.cacheDir = dir }
+}
+
+// WithConfigRoot sets the directory in which namespaces are linked and, by
+// default, commands are cached (within its .cache directory), in place of
+// the binr directory derived from the home directory or XDG_CONFIG_HOME
+// (~/.config/binr).  This gives services which run as a system user without
+// a home directory a deterministic location, rather than one within the
+// current working directory.  The same option must be provided to every
+// function which reads the store, such as Path and List.
+func WithConfigRoot(dir string) func(*config) {
+	return func(c *config) { c.configRoot = dir }
+}
+
+// WithFilesystem sets the Filesystem in which commands are cached and
+// linked, such as an in-memory filesystem for tests or an alternative
+// storage backend.  The default is the filesystem of the operating system.
+// Note that WithValidateExecutable runs the command, and so requires that
+// it be available to the operating system.
+func WithFilesystem(fs Filesystem) func(*config) {
+	return func(c *config) { c.fs = fs }
+}
+
+// WithTraceExtractor provides a function which extracts a trace ID from the
+// context of a request, such as one propagated by a distributed tracing
+// system.  The ID is included in every log line (as trace_id) and in any
+// error returned, allowing binr's work to be correlated with the rest of
+// the request.
+func WithTraceExtractor(f func(context.Context) string) func(*config) {
+	return func(c *config) { c.traceExtractor = f }
+}
+
+// WithMetadataFetch fetches the release metadata of a command (such as a
+// RELEASE.json published with each version) from the URL returned by urlFn
+// for the version and system of the command fetched, and provides it to
+// dest before the command is linked.  This is an opportunity to display
+// release notes, or to apply a policy, as an error returned by dest aborts
+// the install.  The metadata is fetched whenever the command is, including
+// from the cache, but not when the command is already installed.  A failure
+// to fetch it is a warning unless WithMetadataRequired is also provided.
+// An empty URL fetches nothing.
+func WithMetadataFetch(urlFn func(version, os, arch string) string, dest func(meta []byte) error) func(*config) {
+	return func(c *config) { c.metadataURL, c.metadataDest = urlFn, dest }
+}
+
+// WithMetadataRequired fails an install for which the release metadata can
+// not be fetched.  See WithMetadataFetch.
+func WithMetadataRequired() func(*config) {
+	return func(c *config) { c.metadataRequired = true }
+}
+
+// WithBeforeLink registers a function to be invoked with the path and
+// checksum of a verified command in the cache immediately before it is
+// linked into the namespace.  This is an opportunity to apply a policy such
+// as a malware scan or an allowlist of checksums.  An error returned aborts
+// the install, and a command which was downloaded for the install is
+// removed from the cache.  Commands already installed in the namespace are
+// not linked and so are not passed to the function.
+func WithBeforeLink(f func(path, checksum string) error) func(*config) {
+	return func(c *config) { c.beforeLink = f }
+}
+
+// WithOnNewObject registers a function to be invoked with the checksum and
+// path of each command added to the cache by a download, such as to scan it
+// once regardless of the number of namespaces which link it.  Commands
+// already in the cache, whether found before or after a download, are not
+// passed to the function.  An error returned aborts the install and removes
+// the command from the cache.
+func WithOnNewObject(f func(checksum, path string) error) func(*config) {
+	return func(c *config) { c.onNewObject = f }
+}
+
+// setup ensures that the binr cache directory is available
+func setup(ctx context.Context, cfg config) (err error) {
+	path := cfg.cachePath()
+	if _, err = cfg.fs.Stat(path); errors.Is(err, os.ErrNotExist) {
+		cfg.log.Debug().Str("path", path).Msg("creating local binr cache")
+		if err = ensureDir(cfg.fs, path); err != nil {
+			return fmt.Errorf("binr was unable to create cache directory. %w", err)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("binr encountered an unexpected error accessing its cache. %w", err)
+	}
+	if cfg.startupVerify {
+		return quarantineCorrupt(ctx, cfg)
+	}
+	return
+}
+
+// quarantineCorrupt renames each object in the cache whose content does not
+// match its checksum with the suffix .corrupt, such that it is downloaded
+// again rather than provided.  See WithStartupVerify.
+func quarantineCorrupt(ctx context.Context, cfg config) error {
+	entries, err := cfg.fs.ReadDir(cfg.cachePath())
+	if err != nil {
+		return fmt.Errorf("binr unable to read cache to verify. %w", err)
+	}
+	for _, entry := range entries {
+		expected, ok := objectChecksum(entry.Name())
+		if !entry.Type().IsRegular() || !ok {
+			continue
+		}
+		path := filepath.Join(cfg.cachePath(), entry.Name())
+		sum, err := checksumOf(ctx, cfg, path, expected)
+		if err != nil {
+			return err
+		}
+		if sum == expected {
+			continue
+		}
+		cfg.warn(fmt.Errorf("calculated checksum %v", sum), path, "binr quarantining cached object which fails its checksum")
+		if err = cfg.fs.Rename(path, path+".corrupt"); err != nil {
+			return fmt.Errorf("binr unable to quarantine corrupt object %v. %w", path, err)
+		}
+	}
+	return nil
+}
+
+// rootPath returns the absolute path of the directory of the namespaces,
+// which is binr within the dotfiles path (see dotfilesPath) unless
+// WithConfigRoot was provided.
+func (c config) rootPath() string {
+	if c.configRoot != "" {
+		path, _ := filepath.Abs(c.configRoot)
+		return path
+	}
+	path, _ := filepath.Abs(filepath.Join(dotfilesPath(), "binr"))
+	return path
+}
+
+// cachePath returns the effective path to the binr cache for the config,
+// which is .cache within the root path unless WithCacheDir was provided.
+func (c config) cachePath() string {
+	if c.cacheDir == "" {
+		return filepath.Join(c.rootPath(), ".cache")
+	}
+	path, _ := filepath.Abs(c.cacheDir)
+	return path
+}
+
+// Path returns the absolute path at which the given command for
+// the given namespace is expected to exist.  It does not validate the
+// command's existence (see Get).
+//
+// Version is optional, and if not provided will point to a "floating"
+// link which is always updated to the current version.  If provided, it
+// must be a semver or a Digest version (see ClassifyVersion), the latter
+// being linked by its abbreviated digest.
+//
+// Of the options, only WithConfigRoot affects the path.
+func Path(namespace, command, version string, options ...option) (path string, err error) {
+	return newConfig(options...).linkPath(namespace, command, version)
+}
+
+// linkPath returns the absolute path of the link of the command in the
+// namespace for the config.  See Path.
+func (c config) linkPath(namespace, command, version string) (string, error) {
+	if namespace == "" {
+		return "", errors.New("binr Path requires namespace")
+	} else if command == "" {
+		return "", errors.New("binr Path requires command")
+	} else if digestVersion.MatchString(version) {
+		command += "-" + shortDigest(version)
+	} else if version != "" {
+		if _, err := semver.NewVersion(version); err != nil {
+			return "", errors.New("binr Path requires version to be a valid semver (ex: v1.2.3)")
+		}
+		command += "-" + version
+	}
+	return filepath.Join(c.rootPath(), namespace, command), nil
+}
+
+// dotfilesPath returns ~/.config by default, XDG_CONFIG_HOME if set, or
+// In the event that there is neither a home directory nor an XDG_CONFIG_HOME
+// set, the relative path ".binr/bin" is used.
+func dotfilesPath() string {
+	var (
+		xdg      = os.Getenv("XDG_CONFIG_HOME")
+		home, _  = os.UserHomeDir()
+		dotfiles = filepath.Join(home, ".config")
+	)
+	if workingDirFallback() {
+		log.Warn().Msg(workingDirWarning)
+		return "."
+	}
+	if xdg != "" {
+		dotfiles = xdg
+	}
+	return dotfiles
+}
+
+const workingDirWarning = "binr found no home directory nor XDG_CONFIG_HOME environment variable.  The current working directory will be used."
+
+// workingDirFallback returns true if there is neither a home directory nor
+// an XDG_CONFIG_HOME, such that the current working directory is used.
+func workingDirFallback() bool {
+	_, homeErr := os.UserHomeDir()
+	return homeErr != nil && os.Getenv("XDG_CONFIG_HOME") == ""
+}
+
+// workingDirFallback returns true if the config's root path is within the
+// current working directory for want of a home directory.  See
+// WithConfigRoot.
+func (c config) workingDirFallback() bool {
+	return c.configRoot == "" && workingDirFallback()
+}
+
+// got the command already?  A link which forms a loop is not, and is
+// replaced when the command is linked.
+func got(cfg config, path string) bool {
+	if _, err := resolveLink(cfg.fs, path); err != nil {
+		cfg.warn(err, path, "binr replacing link")
+		return false
+	}
+	if _, err := cfg.fs.Stat(path); err != nil {
+		return false
+	}
+	// TODO: ensure it is a symlink
+	// TODO: ensure it points to a path in the store
+	// TODO: ensure target exists
+	// TODO: ensure target is executable
+	// TODO: ensure target filename is its hash
+	return true
+}
+
+// getChecksum returns the checksum at the given URL if provided, empty string
+// otherwise.  If provided, any error turning the URL into a checksum is
+// bubbled.  If the "URL" is itself a checksum, it is returned as-is.
+// The checksum URL may contain either the lone checksum, or a list of
+// checksums from which that of the given sourceURL's file is selected
+// (see parseChecksums).
+func getChecksum(ctx context.Context, cfg config, url, sourceURL string) (string, error) {
+	if url == "" {
+		return "", nil
+	}
+	if !validChecksumEncoding(cfg.checksumEncoding) {
+		return "", fmt.Errorf("binr does not support checksum encoding %q. Expected one of hex, base64 or sri", cfg.checksumEncoding)
+	}
+	if sum, ok := decodeChecksum(cfg.checksumEncoding, url); ok {
+		cfg.log.Debug().Str("checksum", sum).Msg("binr using inline checksum")
+		return sum, nil
+	}
+	res, err := request(ctx, cfg, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("binr was unable to fetch the command's checksum from url %q. %w", url, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("binr received an HTTP 404 from checksum URL %q. %w", url, ErrNotFound)
+	} else if res.StatusCode != 200 {
+		return "", fmt.Errorf("binr received an HTTP %v from checksum URL %q", res.StatusCode, url)
+	}
+	bb, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("binr received an error reading the checksum URL %q. %w", url, err)
+	}
+	return parseChecksums(string(bb), url, sourceURL, cfg.checksumEncoding, cfg.checksumFormat)
+}
+
+// isChecksum returns true if the given value is a hex-encoded sha256.
+func isChecksum(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// cache the binary at the given URL which should have the given checksum.
+// If a command already exists in the storw with the given checksum, it is
+// already cached and a fetch is not initiated.
+// The checksum is optional, used to check for cached copies and validate
+// download integrity if provided.  If the checksum is instead being fetched
+// concurrently, awaitChecksum returns it once the download is complete, and
+// it is used only to validate the download.
+//
+// If the download is an archive, the checksum is that of the archive as
+// published, and the named command is extracted from it and cached by its
+// own checksum (which is returned).  Archives are therefore always
+// downloaded, as their checksum does not name an object in the cache.
+// NOTE: future versions will consider the semver and staleness.
+func cache(ctx context.Context, cfg config, command, version, goos, goarch, url, checksum string, awaitChecksum func() (string, error)) (sum string, xfer transfer, done func(), err error) {
+	cfg.log.Debug().
+		Str("url", url).
+		Str("checksum", checksum).
+		Msg("binr sourcing command")
+
+	if cached(cfg, checksum) {
+		return checksum, xfer, func() {}, nil
+	}
+
+	if checksum != "" {
+		release, err := acquireLease(ctx, cfg, checksum)
+		if err != nil {
+			return "", xfer, nil, err
+		}
+		defer release()
+		if cached(cfg, checksum) { // downloaded by another process while waiting
+			return checksum, xfer, func() {}, nil
+		}
+	}
+
+	var (
+		name      = cfg.tempNamer()
+		tmpfile   = filepath.Join(cfg.cachePath(), name+".partial")
+		extracted = filepath.Join(cfg.cachePath(), name+".extracted.partial")
+		persist   = (cfg.persistPartials || cfg.resumeVerify) && checksum != "" && cfg.bodyWrapper == nil // retained until downloaded
+	)
+	if persist {
+		// Named by checksum, such that a later run finds and resumes it.
+		// The lease held ensures no other process is writing it.
+		tmpfile = filepath.Join(cfg.cachePath(), cfg.objectName(checksum)+".partial")
+	}
+
+	done = func() {
+		cfg.log.Debug().Msg("binr cleaning up")
+		// TODO: in the event of a panic this deferred cleanup will not fire.
+		// This could be rearchitected by, for example, using a guid encoded
+		// in the partial filename and and PID.  Finalization then uses only the
+		// partial with the current GUID, and upon success removes all partials
+		// whose encoded pid is no longer a running process.  This cleanup could
+		// be run as an initial task in setup.
+		if !persist {
+			removePartial(cfg, tmpfile)
+		}
+		removePartial(cfg, extracted)
+	}
+	defer func() {
+		if err != nil {
+			done() // callers do not clean up after an error
+		}
+	}()
+
+	if cfg.preflight {
+		if err = preflight(ctx, cfg, url, "application/octet-stream"); err != nil {
+			return
+		}
+	}
+	if cfg.onDownload != nil {
+		cfg.onDownload(url)
+	}
+	var got received
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		got, err = downloadMirrored(ctx, cfg, url, tmpfile, persist)
+		xfer.bytes, xfer.url, xfer.attempts = got.bytes, got.url, attempt
+		if errors.Is(err, errPartialExists) {
+			tmpfile = "" // not this download's to clean up
+		}
+		if err == nil || attempt > cfg.retries || !retryable(ctx, err) {
+			break
+		}
+		// Each attempt is made to a fresh partial, unless resuming.
+		if !persist {
+			removePartial(cfg, tmpfile)
+			tmpfile = filepath.Join(cfg.cachePath(), cfg.tempNamer()+".partial")
+		}
+		cfg.log.Debug().Err(err).Int("attempt", attempt).Msg("binr retrying download")
+		select {
+		case <-ctx.Done():
+			return "", xfer, done, fmt.Errorf("binr stopped retrying download. %w", ctx.Err())
+		case <-time.After(retryInterval * time.Duration(attempt)):
+		}
+	}
+	if err != nil {
+		return
+	}
+	xfer.duration = time.Since(start)
+	persist = false // complete, and so removed if it fails verification
+
+	if awaitChecksum != nil { // fetched concurrently
+		if checksum, err = awaitChecksum(); err != nil {
+			return "", xfer, done, err
+		}
+	}
+
+	hashCtx, cancel := verifyContext(ctx, cfg)
+	defer cancel()
+
+	if checksum != "" {
+		if err = verify(hashCtx, cfg, tmpfile, checksum); err != nil {
+			return "", xfer, done, fmt.Errorf("binr download from %q failed verification, having %v. %w", url, got, err)
+		}
+	}
+	for _, verifier := range cfg.verifiers {
+		if err = verifier(context.WithValue(ctx, verifierKey{}, cfg), tmpfile, version, goos, goarch); err != nil {
+			return "", xfer, done, fmt.Errorf("binr verification of the download from %q failed. %w", url, err)
+		}
+	}
+
+	binary := tmpfile
+	isArchive, err := extract(cfg, url, tmpfile, extracted, command)
+	if err != nil {
+		return
+	}
+	if isArchive {
+		binary = extracted
+		if cfg.keepArchive != "" {
+			if err = keepArchive(cfg, tmpfile, url, cfg.keepArchive); err != nil {
+				return
+			}
+		}
+	}
+
+	// Cached by a published sha256, which was verified, or otherwise by the
+	// checksum of the command calculated with the hasher.
+	if checksum == "" || isArchive || !isChecksum(checksum) {
+		if checksum, err = calculateChecksum(hashCtx, cfg, binary); err != nil {
+			return
+		}
+	}
+
+	if cfg.platformHeaderCheck {
+		if err = checkPlatform(cfg, binary, goos, goarch); err != nil {
+			return
+		}
+	}
+	if cfg.validateExecutable {
+		if err = validateExecutable(ctx, cfg, binary); err != nil {
+			return
+		}
+	}
+
+	newpath := cfg.objectPath(checksum)
+	cfg.log.Debug().
+		Str("from", binary).
+		Str("to", newpath).
+		Msg("moving into place")
+
+	if cfg.fsync {
+		if err = syncFile(cfg.fs, binary); err != nil {
+			return
+		}
+	}
+	existed := cached(cfg, checksum) // such as by another download
+	if err = cfg.fs.Rename(binary, newpath); err != nil {
+		return
+	}
+	if cfg.fsync {
+		if err = syncDir(cfg.fs, cfg.cachePath()); err != nil {
+			return
+		}
+	}
+	if err = seal(cfg, newpath); err != nil {
+		return
+	}
+	if cfg.onNewObject != nil && !existed {
+		if err = cfg.onNewObject(checksum, newpath); err != nil {
+			if rmErr := removeObject(cfg.fs, newpath); rmErr != nil {
+				cfg.warn(rmErr, newpath, "binr unable to remove rejected command")
+			}
+			return "", xfer, done, fmt.Errorf("binr new command rejected. %w", err)
+		}
+	}
+	return checksum, xfer, done, nil
+}
+
+// syncFile at path, flushing its content to stable storage.
+func syncFile(fsys Filesystem, path string) error {
+	file, err := fsys.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("binr unable to open download to sync. %w", err)
+	}
+	defer file.Close()
+	if err = file.Sync(); err != nil {
+		return fmt.Errorf("binr unable to sync download. %w", err)
+	}
+	return nil
+}
+
+// syncDir flushes the directory entries of dir, such as those of a file
+// just renamed into it, to stable storage.  Windows does not support
+// syncing directories, and renames there are durable once complete.
+func syncDir(fsys Filesystem, dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	file, err := fsys.Open(dir)
+	if err != nil {
+		return fmt.Errorf("binr unable to open cache to sync. %w", err)
+	}
+	defer file.Close()
+	if err = file.Sync(); err != nil {
+		return fmt.Errorf("binr unable to sync cache. %w", err)
+	}
+	return nil
+}
+
+// objectMode is the mode of objects in the cache, which are executable but
+// not writable.  See WithWritableCache.
+const objectMode os.FileMode = 0555
+
+// seal the cached object at path by removing its write permission.  The
+// object's mode is not changed if it is already that of an object.
+func seal(cfg config, path string) error {
+	if cfg.writableCache {
+		return nil
+	}
+	info, err := cfg.fs.Stat(path)
+	if err != nil {
+		return fmt.Errorf("binr unable to read cached object. %w", err)
+	}
+	if info.Mode().Perm() == objectMode {
+		return nil
+	}
+	err = cfg.fs.Chmod(path, objectMode)
+	if err != nil && cfg.tolerateChmodErrors && info.Mode().Perm()&0111 != 0 {
+		cfg.warn(err, path, "binr unable to set the mode of an executable cached object. continuing")
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("binr unable to make cached object read-only. Is WithWritableCache or WithTolerateChmodErrors required for this filesystem? %w", err)
+	}
+	return nil
+}
+
+// removeObject at path from the cache, first restoring its write
+// permission, which some systems require in order to remove a file.
+func removeObject(fsys Filesystem, path string) error {
+	if err := fsys.Chmod(path, 0755); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return fsys.Remove(path)
+}
+
+// removePartial download at path if it exists, and the validators
+// recorded for it (see WithResumeVerify).
+func removePartial(cfg config, path string) {
+	if path == "" {
+		return
+	}
+	for _, path := range []string{path, path + validatorsSuffix} {
+		if _, err := cfg.fs.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := cfg.fs.Remove(path); err != nil {
+			cfg.warn(err, path, "binr unable to remove partial download")
+		}
+	}
+}
+
+// retryInterval is the delay before retrying a failed download, which is
+// multiplied by the number of attempts made.  See WithRetries.
+var retryInterval = 100 * time.Millisecond
+
+// errPartialExists is returned (wrapped) by download when its partial
+// download file already exists.
+var errPartialExists = errors.New("partial download exists")
+
+// retryable returns true if the download which failed with the given error
+// may succeed if retried.
+func retryable(ctx context.Context, err error) bool {
+	return ctx.Err() == nil &&
+		!errors.Is(err, errPartialExists) &&
+		!errors.Is(err, ErrNotFound) &&
+		!errors.Is(err, ErrHostNotAllowed) &&
+		!errors.Is(err, ErrInsecureURL) &&
+		!errors.Is(err, ErrInsufficientDisk)
+}
+
+// diagnosticPrefixSize is the number of leading bytes of a download which
+// are included in the errors of downloads which are not a command, such
+// that, for example, an HTML error page served in its place is evident.
+const diagnosticPrefixSize = 64
+
+// received describes the content received by a download.
+type received struct {
+	// bytes received, and their sha256, which for a resumed download are
+	// those received after the offset.
+	bytes  int64
+	sha256 string
+	offset int64
+
+	// url which served the response, following any redirects.
+	url string
+
+	// latency of the response: the time from the request to its headers.
+	latency time.Duration
+
+	// prefix is up to the first diagnosticPrefixSize bytes received.
+	prefix prefixBuffer
+}
+
+// String describes the content received, for inclusion in errors.
+func (r received) String() string {
+	s := fmt.Sprintf("received %v bytes (sha256 %v) beginning %q", r.bytes, r.sha256, r.prefix)
+	if r.offset > 0 {
+		s += fmt.Sprintf(" resuming from offset %v", r.offset)
+	}
+	return s
+}
+
+// prefixBuffer is a writer retaining only the first diagnosticPrefixSize
+// bytes written to it.
+type prefixBuffer []byte
+
+func (b *prefixBuffer) Write(p []byte) (int, error) {
+	if n := diagnosticPrefixSize - len(*b); n > len(p) {
+		*b = append(*b, p...)
+	} else if n > 0 {
+		*b = append(*b, p[:n]...)
+	}
+	return len(p), nil
+}
+
+// download the given url to the given output, (optionally) verifying the
+// content type.  If resume, an existing output is taken to be a partial
+// download to be resumed, and only the remainder is requested.  With
+// WithResumeVerify, a partial is resumed only if it is consistent with its
+// source.  The content is hashed and its prefix retained as it is written,
+// such that the errors of content which is not that expected can describe
+// what was received.
+func download(ctx context.Context, cfg config, url, outPath, contentType string, resume bool) (r received, err error) {
+	var (
+		header   http.Header
+		recorded validators
+	)
+	if info, err := cfg.fs.Stat(outPath); err == nil && !resume {
+		return r, fmt.Errorf("binr encountered an existing download file. If you are sure it is from a failed earlier attempt, the file can be removed. %v. %w", outPath, errPartialExists)
+	} else if err == nil && info.Size() > 0 {
+		if cfg.resumeVerify {
+			var ok bool
+			if recorded, ok = recordedValidators(cfg, outPath); !ok {
+				cfg.log.Debug().Str("path", outPath).Msg("binr discarding partial download without validators")
+				return restartDownload(ctx, cfg, url, outPath, contentType)
+			}
+		}
+		r.offset = info.Size()
+		header = http.Header{"Range": {fmt.Sprintf("bytes=%d-", r.offset)}}
+		if v := recorded.ifRange(); v != "" {
+			header.Set("If-Range", v) // the server sends all if changed
+		}
+	}
+	start := time.Now()
+	res, err := request(ctx, cfg, http.MethodGet, url, header)
+	if err != nil {
+		return r, fmt.Errorf("binr received an http error fetching the command. %w", err)
+	}
+	defer res.Body.Close()
+	r.latency = time.Since(start)
+	r.url = redact(res.Request.URL.String()) // as rewritten and redirected
+	if r.offset > 0 && cfg.resumeVerify && (res.StatusCode == http.StatusPartialContent || res.StatusCode == http.StatusRequestedRangeNotSatisfiable) {
+		if err = checkResume(res, r.offset, recorded); err != nil {
+			cfg.log.Debug().Err(err).Str("path", outPath).Msg("binr discarding partial download")
+			res.Body.Close()
+			return restartDownload(ctx, cfg, url, outPath, contentType)
+		}
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return r, fmt.Errorf("binr received an HTTP 404 from source URL %q. %w", url, ErrNotFound)
+	case r.offset > 0 && res.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// The partial is already complete, which verification confirms.
+		cfg.log.Debug().Str("path", outPath).Int64("bytes", r.offset).Msg("binr partial download already complete")
+		return r, nil
+	case r.offset > 0 && res.StatusCode == http.StatusPartialContent:
+		if !strings.HasPrefix(res.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", r.offset)) {
+			return r, fmt.Errorf("binr received an unexpected content range %q resuming from source URL %q", res.Header.Get("Content-Range"), url)
+		}
+		cfg.log.Debug().Str("path", outPath).Int64("offset", r.offset).Msg("binr resuming partial download")
+		flag = os.O_WRONLY | os.O_APPEND
+	case res.StatusCode != 200:
+		return r, fmt.Errorf("binr received an HTTP %v from source URL %q", res.StatusCode, url)
+	default:
+		r.offset = 0 // the server ignored the range
+	}
+	if cfg.responseValidator != nil {
+		if err = cfg.responseValidator(res); err != nil {
+			return r, fmt.Errorf("binr rejected the response from source URL %q. %w", url, err)
+		}
+	}
+	if mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mediaType == "application/json" && contentType != mediaType {
+		_, _ = io.Copy(&r.prefix, io.LimitReader(res.Body, diagnosticPrefixSize))
+		return r, fmt.Errorf("binr received JSON from source URL %q rather than the command, which is likely an error reported by the server. The response began %q", url, r.prefix)
+	}
+	if res.Header.Get("Content-Type") != contentType {
+		_, _ = io.Copy(&r.prefix, io.LimitReader(res.Body, diagnosticPrefixSize))
+		return r, fmt.Errorf("binr unable to source command.  Source URL reported a content type of %q when an %q was expected. The response began %q", res.Header.Get("Content-Type"), contentType, r.prefix)
+	}
+	if cfg.minFreeDisk > 0 {
+		if err = checkFreeDisk(cfg, filepath.Dir(outPath), res.ContentLength); err != nil {
+			return r, err
+		}
+	}
+	var body io.Reader = contextReader{ctx, res.Body} // stop promptly if cancelled
+	if cfg.maxBandwidth > 0 {
+		body = newThrottledReader(ctx, body, cfg.maxBandwidth)
+	}
+	if cfg.bodyWrapper != nil {
+		if body, err = cfg.bodyWrapper(body); err != nil {
+			return r, fmt.Errorf("binr body wrapper failed for source URL %q. %w", url, err)
+		}
+	}
+	if resume && cfg.resumeVerify && r.offset == 0 {
+		if err = recordValidators(cfg, outPath, responseValidators(res)); err != nil {
+			return r, err
+		}
+	}
+	file, err := cfg.fs.OpenFile(outPath, flag, 0755)
+	if err != nil {
+		return r, fmt.Errorf("binr unable to open local file for writing. %w", err)
+	}
+	defer file.Close()
+	var (
+		hash = sha256.New()
+		w    = io.MultiWriter(file, hash, &r.prefix)
+		buf  []byte
+	)
+	if cfg.downloadBufferSize > 0 {
+		buf = make([]byte, cfg.downloadBufferSize)
+	}
+	r.bytes, err = io.CopyBuffer(w, body, buf)
+	r.sha256 = hex.EncodeToString(hash.Sum(nil))
+	if err != nil {
+		return r, fmt.Errorf("binr encoutered an error copying remote data. %w", err)
+	}
+	cfg.log.Debug().Str("path", outPath).Int64("bytes", r.bytes).Msg("binr download complete")
+	return r, nil
+}
+
+// preflight confirms with a HEAD request that the given url exists and has
+// the expected content type, without downloading it.  Servers which do not
+// support HEAD (responding 405 Method Not Allowed) are assumed to be fine.
+func preflight(ctx context.Context, cfg config, url, contentType string) error {
+	res, err := request(ctx, cfg, http.MethodHead, url, nil)
+	if err != nil {
+		return fmt.Errorf("binr received an http error checking the command exists. %w", err)
+	}
+	res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusMethodNotAllowed:
+		cfg.log.Debug().Str("url", url).Msg("binr preflight not supported by server. skipping")
+		return nil
+	case res.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("binr preflight received an HTTP 404 from source URL %q. Is the Source's URL correct? %w", url, ErrNotFound)
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("binr preflight received an HTTP %v from source URL %q", res.StatusCode, url)
+	case res.Header.Get("Content-Type") != contentType:
+		return fmt.Errorf("binr preflight found source URL %q reports a content type of %q when %q was expected", url, res.Header.Get("Content-Type"), contentType)
+	}
+	cfg.log.Debug().
+		Str("url", url).
+		Int64("length", res.ContentLength).
+		Msg("binr preflight succeeded")
+	return nil
+}
+
+// keepArchive copies the archive at path, downloaded from url, into dir.
+func keepArchive(cfg config, path, url, dir string) error {
+	if err := ensureDir(cfg.fs, dir); err != nil {
+		return fmt.Errorf("binr unable to create directory for keeping archives. %w", err)
+	}
+	dest := filepath.Join(dir, sourceFilename(url))
+	cfg.log.Debug().Str("path", dest).Msg("binr keeping archive")
+	return copyFile(cfg.fs, path, dest, 0644, "")
+}
+
+// copyFile at src to dst within fsys with the given mode, replacing dst if
+// it exists.
+// The copy is written alongside dst and then moved into place, such that
+// dst is never partially written.  If checksum is provided, the content
+// copied must match it or dst is left unchanged.
+func copyFile(fsys Filesystem, src, dst string, mode os.FileMode, checksum string) (err error) {
+	in, err := fsys.Open(src)
+	if err != nil {
+		return fmt.Errorf("binr unable to open %v for copying. %w", src, err)
+	}
+	defer in.Close()
+
+	tmp := dst + ".partial"
+	out, err := fsys.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("binr unable to open %v for writing. %w", tmp, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = fsys.Remove(tmp)
+		}
+	}()
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(out, hash), in); err != nil {
+		out.Close()
+		return fmt.Errorf("binr unable to copy %v to %v. %w", src, dst, err)
+	}
+	if err = out.Close(); err != nil {
+		return fmt.Errorf("binr unable to write %v. %w", tmp, err)
+	}
+	if checksum != "" && hex.EncodeToString(hash.Sum(nil)) != checksum {
+		return fmt.Errorf("binr detected a checksum mismatch copying %v. Expected %v", src, checksum)
+	}
+	return fsys.Rename(tmp, dst)
+}
+
+// cached returns whether or not the binary with the given checksum exists
+// in the cache.
+func cached(cfg config, checksum string) bool {
+	if checksum == "" {
+		return false
+	}
+	_, err := cfg.fs.Stat(cfg.objectPath(checksum))
+	return (err == nil)
+}
+
+// cachedByPrefix returns the full checksum of the single object in the cache
+// whose checksum begins with the given (hex) prefix.  ok is false if there is
+// no such object, and an error is returned if the prefix is ambiguous.
+func cachedByPrefix(cfg config, prefix string) (checksum string, ok bool, err error) {
+	prefix = strings.ToLower(prefix)
+	if prefix == "" {
+		return "", false, nil
+	}
+	if isChecksum(prefix) {
+		return prefix, cached(cfg, prefix), nil
+	}
+	entries, err := cfg.fs.ReadDir(cfg.cachePath())
+	if err != nil {
+		return "", false, fmt.Errorf("binr unable to read cache. %w", err)
+	}
+	var matches []string
+	for _, entry := range entries {
+		sum, ok := objectChecksum(entry.Name())
+		if ok && entry.Name() == cfg.objectName(sum) && strings.HasPrefix(sum, prefix) {
+			matches = append(matches, sum)
+		}
+	}
+	if len(matches) > 1 {
+		return "", false, fmt.Errorf("binr found %v objects in the cache with checksum prefix %q. Please provide more of the checksum", len(matches), prefix)
+	} else if len(matches) == 0 {
+		return "", false, nil
+	}
+	return matches[0], true, nil
+}
+
+// verify the given path has the given checksum
+func verify(ctx context.Context, cfg config, path, checksum string) (err error) {
+	fileChecksum, err := checksumOf(ctx, cfg, path, checksum)
+	if err != nil {
+		return
+	}
+	if fileChecksum != checksum {
+		cfg.log.Debug().
+			Str("path", path).
+			Str("expected", checksum).
+			Str("calculated", fileChecksum).
+			Msg("checksum mismatch")
+		return errors.New("binr detected a checksum mismatch. Not sourcing command")
+	}
+	return
+}
+
+// validateTimeout is how long a command run by validateExecutable is allowed
+// before it is stopped.
+const validateTimeout = 5 * time.Second
+
+// validateExecutable runs the command at path with a help flag, returning
+// an error if the system is unable to execute it.
+func validateExecutable(ctx context.Context, cfg config, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, validateTimeout)
+	defer cancel()
+
+	err := exec.CommandContext(ctx, path, "--help").Run()
+	var exitErr *exec.ExitError
+	if err == nil || errors.As(err, &exitErr) {
+		cfg.log.Debug().Str("path", path).Msg("binr validated command is executable")
+		return nil // it ran
+	}
+	if errors.Is(err, syscall.ENOEXEC) {
+		return fmt.Errorf("binr downloaded a command which can not be executed on this system (%v/%v). Is the Source providing a binary for the correct os and architecture? %w", runtime.GOOS, runtime.GOARCH, err)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("binr downloaded a command which can not be executed on this system. It may require a dynamic loader or interpreter which is not installed. %w", err)
+	}
+	return fmt.Errorf("binr was unable to execute the downloaded command. %w", err)
+}
+
+// assertVersion runs the command at path with a --version flag, returning
+// an error unless the version extracted from its output (see
+// WithAssertVersionMatch) is semantically that requested.  Digests are not
+// versions, and so are not asserted.
+func assertVersion(ctx context.Context, cfg config, path, version string) error {
+	if digestVersion.MatchString(version) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, validateTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("binr unable to run command to assert its version. %w", err)
+	}
+	reported, err := cfg.assertVersion(string(output))
+	if err != nil {
+		return fmt.Errorf("binr unable to extract the version reported by the command. %w", err)
+	}
+	requested, err := semver.NewVersion(version)
+	if err != nil {
+		return fmt.Errorf("binr unable to parse version %q. %w", version, err)
+	}
+	actual, err := semver.NewVersion(strings.TrimSpace(reported))
+	if err != nil {
+		return fmt.Errorf("binr found the command reports an invalid version %q. %w", reported, err)
+	}
+	if !actual.Equal(requested) {
+		return fmt.Errorf("binr found the command reports version %v when %v was requested. Is the release mislabeled?", reported, version)
+	}
+	cfg.log.Debug().Str("path", path).Str("version", reported).Msg("binr asserted command version")
+	return nil
+}
+
+// calculateChecksum of file at path with the config's hasher, by which it
+// is addressed in the cache.  Hashing stops if the context is cancelled.
+func calculateChecksum(ctx context.Context, cfg config, filePath string) (string, error) {
+	algorithm := cfg.hasher.Algorithm()
+	digest, err := calculateDigest(ctx, cfg, filePath, cfg.hasher.New)
+	if err != nil || algorithm == "sha256" {
+		return digest, err
+	}
+	return algorithm + "-" + digest, nil
+}
+
+// checksumOf the file at path, calculated with the algorithm of the given
+// checksum, in the same form.  The algorithm is either one in which
+// checksums are published, or that of the config's hasher.
+func checksumOf(ctx context.Context, cfg config, path, checksum string) (string, error) {
+	algorithm, _ := splitChecksum(checksum)
+	newHash, err := cfg.newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	digest, err := calculateDigest(ctx, cfg, path, newHash)
+	if err != nil || algorithm == "sha256" {
+		return digest, err
+	}
+	return algorithm + "-" + digest, nil
+}
+
+// newHash returns the constructor of the hash of the algorithm, which is
+// either one in which checksums are published, or that of the config's
+// hasher.
+func (c config) newHash(algorithm string) (func() hash.Hash, error) {
+	if algorithm == c.hasher.Algorithm() {
+		return c.hasher.New, nil
+	}
+	if newHash, ok := checksumAlgorithms[algorithm]; ok {
+		return newHash, nil
+	}
+	if newHash, ok := inferredAlgorithms[algorithm]; ok {
+		return newHash, nil
+	}
+	return nil, fmt.Errorf("binr does not support checksum algorithm %q. Was the command cached with another Hasher (see WithHasher)?", algorithm)
+}
+
+// calculateDigest of the file at path within the config's filesystem using
+// the given hash, hex-encoded.  The bytes hashed, and the time taken, are
+// tallied in the config's hashed.
+//
+// The hash is calculated serially, as a sha256 (unlike a tree hash) can not
+// be calculated in parallel chunks, and the standard library already uses
+// the CPU's SHA extensions where available.  Throughput is then bound by the
+// CPU rather than by reads, such that a buffer larger than that of io.Copy
+// was measured to make no difference (see BenchmarkCalculateDigest).
+func calculateDigest(ctx context.Context, cfg config, filePath string, newHash func() hash.Hash) (string, error) {
+	file, err := cfg.fs.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("binr unable to calculate file's checksum. %w", err)
+	}
+	defer file.Close()
+
+	hash, start := newHash(), time.Now()
+	n, err := io.Copy(hash, contextReader{ctx, file})
+	cfg.hashed.add(n, time.Since(start))
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "", fmt.Errorf("binr timed out calculating the checksum of %v. %w", filePath, err)
+	} else if err != nil {
+		return "", fmt.Errorf("binr unable to calculate file's checksum. %w", err)
+	}
+
+	// hashInBytes := hash.Sum(nil)[:32]
+	hashInBytes := hash.Sum(nil)
+	return hex.EncodeToString(hashInBytes), nil
+}
+
+// verifyContext returns the context for calculating checksums, which is
+// limited by the config's verify timeout if set.
+func verifyContext(ctx context.Context, cfg config) (context.Context, context.CancelFunc) {
+	if cfg.verifyTimeout > 0 {
+		return context.WithTimeout(ctx, cfg.verifyTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// contextReader is a reader which stops with the context's error once it
+// is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// Relink recreates the links for the given version of a command to an
+// object already in the cache, without consulting a Source or downloading.
+// This rebuilds a namespace whose links were removed while the cache
+// remained intact.  The versioned link is replaced if it exists, and the
+// unversioned link is created if the version is the newest installed.  An
+// error is returned if the cache does not contain an object with the given
+// checksum.  The checksum may be abbreviated to any unambiguous prefix.
+func Relink(namespace, command, version, checksum string, options ...option) error {
+	cfg := newConfig(options...)
+
+	if namespace == "" {
+		return errors.New("binr Relink requires namespace")
+	} else if command == "" {
+		return errors.New("binr Relink requires command")
+	} else if _, err := semver.NewVersion(version); err != nil {
+		return errors.New("binr Relink requires version to be a valid semver (ex: v1.2.3)")
+	}
+
+	sum, ok, err := cachedByPrefix(cfg, checksum)
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("binr Relink found no object in the cache with checksum %q", checksum)
+	}
+
+	path, err := cfg.linkPath(namespace, command, version)
+	if err != nil {
+		return err
+	}
+	if err = cfg.fs.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("binr unable to remove existing link. %w", err)
+	}
+	return link(cfg, namespace, command, version, sum)
+}
+
+// Link installs the given version of a command in the namespace by linking
+// it to the object with the given checksum, which must already be in the
+// cache, such as one fetched earlier by GetReader.  This is the final step
+// of a fetch-then-link workflow, and neither consults a Source nor makes a
+// request.  The object is trusted to match its checksum unless
+// WithVerifyCached is provided.  An error is returned if the object is not
+// cached.  As with Get, the links are qualified by the platform if provided
+// (see WithPlatform), and the path of the versioned link is returned.
+func Link(namespace, command, version, checksum string, options ...option) (path string, err error) {
+	cfg := newConfig(options...)
+
+	if namespace == "" {
+		return "", errors.New("binr Link requires namespace")
+	} else if command == "" {
+		return "", errors.New("binr Link requires command")
+	} else if _, err := semver.NewVersion(version); err != nil {
+		return "", errors.New("binr Link requires version to be a valid semver (ex: v1.2.3)")
+	} else if checksum == "" {
+		return "", errors.New("binr Link requires checksum")
+	}
+	if !cached(cfg, checksum) {
+		return "", fmt.Errorf("binr Link found no object in the cache with checksum %q", checksum)
+	}
+
+	ctx := context.Background()
+	unlock, err := readLock(ctx, cfg)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	if !cached(cfg, checksum) { // pruned while waiting
+		return "", fmt.Errorf("binr Link found no object in the cache with checksum %q", checksum)
+	}
+	if cfg.verifyCached {
+		if err = verifyObject(ctx, cfg, cfg.objectPath(checksum), checksum); err != nil {
+			return
+		}
+	}
+
+	name := cfg.linkName(command)
+	if path, err = cfg.linkPath(namespace, name, version); err != nil {
+		return
+	}
+	return path, link(cfg, namespace, name, version, checksum)
+}
+
+// link a new command to the cached object with the given checksum,
+// recording the links changed in the config's journal.  If
+// cfg.cleanOnError and the caller keeps no journal, the links changed are
+// rolled back should a later step fail, such that a failed link leaves no
+// partial state in the namespace.  The cached object itself is left in
+// place, as it may be shared.
+func link(cfg config, namespace, command, version, sum string) (err error) {
+	if cfg.cleanOnError && cfg.linked == nil {
+		cfg.linked = &linkJournal{}
+		defer func() {
+			if err != nil {
+				cfg.linked.rollback(cfg)
+			}
+		}()
+	}
+
+	pathVersioned, err := cfg.linkPath(namespace, command, version)
+	if err != nil {
+		return
+	}
+	target := linkTarget(cfg.cachePath(), pathVersioned, cfg.objectName(sum))
+	cfg.log.Debug().
+		Str("target", target).
+		Str("path", pathVersioned).
+		Msg("linking versioned")
+
+	if err = ensureDir(cfg.fs, filepath.Dir(pathVersioned)); err != nil {
+		return
+	}
+	if err = cfg.linked.replace(cfg, target, pathVersioned); err != nil {
+		return
+	}
+
+	if digestVersion.MatchString(version) {
+		cfg.log.Debug().Msg("version linked is a digest. leaving unversioned link unchanged.")
+		return
+	}
+	if ok, err := isNewer(cfg, namespace, command, version); !ok || err != nil {
+		cfg.log.Debug().Msg("version linked is not newest. leaving unversioned link unchanged.")
+		return err
+	}
+
+	pathUnversioned, err := cfg.linkPath(namespace, command, "")
+	if err != nil {
+		return
+	}
+
+	cfg.log.Debug().
+		Str("target", target).
+		Str("path", pathUnversioned).
+		Msg("updating unversioned link")
+
+	return cfg.linked.replace(cfg, target, pathUnversioned)
+}
+
+// linkJournal records the links changed by an install, and their previous
+// targets, such that they can be restored should it fail.  A nil journal
+// records nothing.  See WithCleanOnError.
+type linkJournal struct {
+	changes []linkChange
+}
+
+// linkChange is a link replaced, and its previous target, which is empty if
+// the link was created.
+type linkChange struct {
+	path, previous string
+}
+
+// replace any link at path with a link to target (see replaceSymlink),
+// recording the change.
+func (j *linkJournal) replace(cfg config, target, path string) error {
+	previous, _ := cfg.fs.Readlink(path)
+	if err := replaceSymlink(cfg.fs, target, path); err != nil {
+		return err
+	}
+	if j != nil {
+		j.changes = append(j.changes, linkChange{path, previous})
+	}
+	return nil
+}
+
+// rollback the changes recorded, most recent first, removing the links
+// created and restoring those replaced to their previous targets.
+func (j *linkJournal) rollback(cfg config) {
+	if j == nil {
+		return
+	}
+	for i := len(j.changes) - 1; i >= 0; i-- {
+		c := j.changes[i]
+		if c.previous != "" {
+			cfg.log.Debug().Str("path", c.path).Str("target", c.previous).Msg("binr restoring link after error")
+			if err := replaceSymlink(cfg.fs, c.previous, c.path); err != nil {
+				cfg.warn(err, c.path, "binr unable to restore link after error")
+			}
+			continue
+		}
+		cfg.log.Debug().Str("path", c.path).Msg("binr removing link after error")
+		if err := cfg.fs.Remove(c.path); err != nil {
+			cfg.warn(err, c.path, "binr unable to remove link after error")
+		}
+	}
+	j.changes = nil
+}
+
+// linkTarget returns the target for a link at path to the object with the
+// given name in the cache at cacheDir.  Targets are relative where
+// possible, such that the binr directory can be moved as a whole.
+func linkTarget(cacheDir, path, name string) string {
+	object := filepath.Join(cacheDir, name)
+	if rel, err := filepath.Rel(filepath.Dir(path), object); err == nil {
+		return rel
+	}
+	return object
+}
+
+// replacements is a count of the links replaced by this process, by which
+// their temporary links are uniquely named.
+var replacements atomic.Int64
+
+// replaceSymlink atomically replaces any link at path within fsys with a
+// link to target by creating the link alongside and renaming it into place.
+// Concurrent replacements of the same link each succeed, the last to be
+// renamed into place prevailing.
+func replaceSymlink(fsys Filesystem, target, path string) error {
+	if _, err := fsys.Readlink(path); errors.Is(err, os.ErrNotExist) {
+		// Nothing to replace, unless created concurrently
+		if err = fsys.Symlink(target, path); !errors.Is(err, os.ErrExist) {
+			return err
+		}
+	}
+	tmp := fmt.Sprintf("%v.%d-%d.tmp", path, os.Getpid(), replacements.Add(1))
+	if err := fsys.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := fsys.Rename(tmp, path); err != nil {
+		_ = fsys.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// isNewer returns true if the given version would become the latest
+// installed version of the command in the given namespace.
+//
+// Ties are won by the given version: a version is newer if no installed
+// version has a higher precedence, so reinstalling the latest version (or
+// installing one which differs from it only in build metadata, which semver
+// does not consider in precedence) points the unversioned link to the
+// version most recently installed.
+//
+// Unless the config includes pre-releases, they are not considered newer
+// than any installed release, nor are releases compared against them.
+func isNewer(cfg config, namespace, command, versionStr string) (bool, error) {
+	version, err := semver.NewVersion(versionStr)
+	if err != nil {
+		return false, fmt.Errorf("binr can not determine if the given command is the latest because an invalid semver was received: %q", versionStr)
+	}
+
+	highest, highestRelease, err := highestInstalled(cfg, namespace, command)
+	if err != nil {
 		return false, fmt.Errorf("binr unable to check for latest version. %w", err)
 	}
 
-	var highest *semver.Version
+	if !cfg.includePrereleases && highestRelease != nil {
+		if version.Prerelease() != "" {
+			return false, nil
+		}
+		highest = highestRelease
+	}
+	return highest == nil || !highest.GreaterThan(version), nil
+}
 
+// highestInstalled returns the highest exact version of the command
+// installed in the namespace, and the highest which is not a pre-release.
+// Either is nil if there is no such version.
+func highestInstalled(cfg config, namespace, command string) (highest, highestRelease *semver.Version, err error) {
+	files, err := cfg.fs.ReadDir(filepath.Join(cfg.rootPath(), namespace))
+	if err != nil {
+		return
+	}
 	for _, file := range files {
 		if file.IsDir() {
 			continue
@@ -440,15 +2706,79 @@ func isNewer(namespace, command, versionStr string) (bool, error) {
 			continue // other command or the unversioned (latest) link of this one
 		}
 		suffix := strings.TrimPrefix(file.Name(), prefix)
+		if !exactVersion.MatchString(suffix) {
+			continue // floating links (vX, vX.Y)
+		}
 
 		v, err := semver.NewVersion(suffix)
 		if err != nil {
-			return false, fmt.Errorf("binr found a file which is not of the expected form [command]-[version]: %q. the version does not appear to be a Semver (v1.2.3)", file.Name())
+			continue // links of another command or platform (see WithPlatform)
 		}
 		if highest == nil || v.GreaterThan(highest) {
 			highest = v
 		}
+		if v.Prerelease() == "" && (highestRelease == nil || v.GreaterThan(highestRelease)) {
+			highestRelease = v
+		}
+	}
+	return
+}
+
+// reconcileLatest points the unversioned link of the command in the
+// namespace to the target of the newest version installed, if it does not
+// already.  See isNewer for which is newest.
+func reconcileLatest(cfg config, namespace, command string) error {
+	highest, highestRelease, err := highestInstalled(cfg, namespace, command)
+	if err != nil {
+		return fmt.Errorf("binr unable to determine the latest version. %w", err)
+	}
+	if !cfg.includePrereleases && highestRelease != nil {
+		highest = highestRelease
+	}
+	if highest == nil {
+		return nil
+	}
+	newest, err := cfg.linkPath(namespace, command, highest.Original())
+	if err != nil {
+		return err
+	}
+	unversioned, err := cfg.linkPath(namespace, command, "")
+	if err != nil {
+		return err
+	}
+	target, err := cfg.fs.Readlink(newest)
+	if err != nil {
+		return fmt.Errorf("binr unable to read link of the latest version. %w", err)
+	}
+	if current, err := cfg.fs.Readlink(unversioned); err == nil && current == target {
+		return nil
 	}
+	cfg.log.Debug().
+		Str("path", unversioned).
+		Str("version", highest.Original()).
+		Msg("binr reconciling unversioned link with the latest version")
+	return cfg.linked.replace(cfg, target, unversioned)
+}
+
+// ErrDowngrade is returned (wrapped) when installing a version lower than
+// one already installed.  See WithNoDowngrade.
+var ErrDowngrade = errors.New("binr refusing to downgrade")
 
-	return !highest.GreaterThan(version), nil
+// checkDowngrade returns an error if a version of the command higher than
+// the given version is installed in the namespace.
+func checkDowngrade(cfg config, namespace, command, versionStr string) error {
+	version, err := semver.NewVersion(versionStr)
+	if err != nil {
+		return nil // digests are not ordered
+	}
+	highest, _, err := highestInstalled(cfg, namespace, command)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("binr unable to check for installed versions. %w", err)
+	}
+	if highest != nil && highest.GreaterThan(version) {
+		return fmt.Errorf("binr refusing to install %v %v as %v is installed. %w", command, versionStr, highest.Original(), ErrDowngrade)
+	}
+	return nil
 }
@@ -0,0 +1,69 @@
+package binr
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestIsNewer ensures the tie-breaking behavior of isNewer, including for
+// versions which differ only in build metadata.
+func TestIsNewer(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed []string
+		version   string
+		newer     bool
+	}{
+		{"none installed", nil, "v1.0.0", true},
+		{"newer", []string{"v1.0.0"}, "v1.1.0", true},
+		{"older", []string{"v1.0.0", "v1.1.0"}, "v1.0.0", false},
+		{"equal", []string{"v1.0.0"}, "v1.0.0", true},
+		{"build metadata", []string{"v1.0.0+a"}, "v1.0.0+b", true},
+		{"build metadata older", []string{"v1.0.0+a", "v1.0.1"}, "v1.0.0+b", false},
+		{"prerelease of installed", []string{"v1.0.0"}, "v1.0.0-rc.1", false},
+		{"release of prerelease", []string{"v1.0.0-rc.1"}, "v1.0.0", true},
+		{"other platforms", []string{"darwin-arm64-v2.0.0", "darwin-arm64"}, "v1.0.0", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			dir := filepath.Join(dotfilesPath(), "binr", "myapp")
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range test.installed {
+				if err := os.Symlink("target", filepath.Join(dir, "mycmd-"+v)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			newer, err := isNewer(newConfig(), "myapp", "mycmd", test.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if newer != test.newer {
+				t.Fatalf("expected isNewer %v for %v with %v installed, got %v",
+					test.newer, test.version, test.installed, newer)
+			}
+		})
+	}
+}
+
+// BenchmarkCalculateDigest measures the throughput of hashing a large file,
+// as when verifying a multi-GB command.
+func BenchmarkCalculateDigest(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "large")
+	if err := os.WriteFile(path, make([]byte, 64<<20), 0644); err != nil {
+		b.Fatal(err)
+	}
+	cfg := newConfig()
+	b.SetBytes(64 << 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := calculateDigest(context.Background(), cfg, path, sha256.New); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
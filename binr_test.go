@@ -2,21 +2,43 @@ package binr_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/fnv"
 	"io"
-	"log"
+	"io/fs"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/lkingland/binr"
+	"github.com/lkingland/binr/binrtest"
+	"github.com/rs/zerolog"
+	zlog "github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
 )
 
 // TestGet ensures the base case of downloading a binary on demand.
@@ -61,6 +83,4496 @@ func TestGet(t *testing.T) {
 	}
 }
 
+// TestGet_InlineChecksum ensures that a Source may return the checksum
+// itself rather than a URL to it, and that it is used for verification.
+func TestGet_InlineChecksum(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+
+	sum := testbinChecksum(t)
+	url := func(vers, os, arch string) string {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch)
+	}
+
+	// An incorrect inline checksum should fail verification
+	_, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0",
+		func(vers, os, arch string) (string, string, error) {
+			return url(vers, os, arch), strings.Repeat("0", len(sum)), nil
+		})
+	if err == nil {
+		t.Fatal("expected a checksum mismatch error")
+	}
+
+	// The correct checksum should succeed, both when downloading and when
+	// later found in the cache by another namespace.
+	for _, namespace := range []string{"myapp", "otherapp"} {
+		path, err := binr.Get(ctx, namespace, "testbin", "v1.0.0",
+			binr.InlineSource(sum, func(vers, os, arch string) (string, string, error) {
+				return url(vers, os, arch), "", nil
+			}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(target) != sum {
+			t.Fatalf("expected link to cached object %q, got %q", sum, target)
+		}
+	}
+}
+
+// TestGet_ArchFallback ensures that when the Source has no command for the
+// current architecture, the fallback architectures are tried in order and
+// the one used is reported in the Result.
+func TestGet_ArchFallback(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+
+	// The source only has the test binary available under the "emulated"
+	// architecture (which serves the test binary of the current arch).
+	source := func(vers, os, arch string) (string, string, error) {
+		if arch == "emulated" {
+			arch = runtime.GOARCH
+		} else {
+			arch = "missing-" + arch
+		}
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+
+	// Without a fallback the 404 is reported
+	_, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source)
+	if !errors.Is(err, binr.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	res, err := binr.GetResult(ctx, "myapp", "testbin", "v1.0.0", source,
+		binr.WithArchFallback([]string{"other", "emulated"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Arch != "emulated" {
+		t.Fatalf("expected fallback arch 'emulated', got %q", res.Arch)
+	}
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "/emulated") {
+		t.Fatalf("expected a warning of the fallback arch, got %q", res.Warnings)
+	}
+	if _, err := os.Stat(res.Path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGet_ValidateExecutable ensures that WithValidateExecutable rejects a
+// downloaded file which can not be executed, and accepts one which can.
+func TestGet_ValidateExecutable(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithValidateExecutable()); err != nil {
+		t.Fatal(err)
+	}
+
+	if runtime.GOOS == "windows" {
+		return // the following relies on the kernel rejecting a text file
+	}
+	garbageAddress := serveContent(t, []byte("this is not a binary\n"))
+	garbage := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/garbage", garbageAddress), "", nil
+	}
+	if _, err := binr.Get(ctx, "otherapp", "garbage", "v1.0.0", garbage, binr.WithValidateExecutable()); err == nil {
+		t.Fatal("expected an error installing a command which is not executable")
+	}
+}
+
+// TestGet_Callbacks ensures that the OnDownload and OnCacheHit callbacks are
+// invoked when a command is downloaded or provided from the local store,
+// respectively.
+func TestGet_Callbacks(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := binr.InlineSource(testbinChecksum(t), func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	})
+
+	var downloads, hits int
+	onDownload := binr.WithOnDownload(func(string) { downloads++ })
+	onCacheHit := binr.WithOnCacheHit(func(string) { hits++ })
+
+	tests := []struct {
+		namespace       string
+		downloads, hits int
+	}{
+		{"myapp", 1, 0},    // initial download
+		{"myapp", 1, 1},    // already installed
+		{"otherapp", 1, 2}, // linked from the cache
+	}
+	for _, test := range tests {
+		if _, err := binr.Get(ctx, test.namespace, "testbin", "v1.0.0", source, onDownload, onCacheHit); err != nil {
+			t.Fatal(err)
+		}
+		if downloads != test.downloads || hits != test.hits {
+			t.Fatalf("%v: expected %v downloads and %v hits, got %v and %v",
+				test.namespace, test.downloads, test.hits, downloads, hits)
+		}
+	}
+}
+
+// TestGet_Archive ensures that a command is extracted from an archive,
+// detected either by the URL's suffix or by the content of the download.
+func TestGet_Archive(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		path    string // URL path at which the fixture is served
+	}{
+		{"tar.gz", "mytool.tar.gz", "/mytool.tar.gz"},
+		{"tar.bz2", "mytool.tar.bz2", "/mytool.tar.bz2"},
+		{"zip", "mytool.zip", "/mytool.zip"},
+		{"gzip magic", "mytool.tar.gz", "/download"},
+		{"bzip2 magic", "mytool.tar.bz2", "/download"},
+		{"zip magic", "mytool.zip", "/download"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testGetArchive(t, test.fixture, test.path)
+		})
+	}
+}
+
+// testGetArchive installs the command "mytool" from the given archive
+// fixture served at the given path, and checks it was extracted.
+func testGetArchive(t *testing.T, fixture, path string) {
+	t.Helper()
+	setupTestGet(t)
+	content, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := serveContent(t, content)
+
+	path, err = binr.Get(context.Background(), "myapp", "mytool", "v1.0.0",
+		func(vers, os, arch string) (string, string, error) {
+			return "http://" + address + path, fmt.Sprintf("%x", sha256.Sum256(content)), nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	extracted, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(extracted) != "mytool\n" {
+		t.Fatalf("unexpected command content %q", extracted)
+	}
+}
+
+// TestGet_ArchiveMemberPattern ensures the command is extracted from the
+// archive member matching a pattern, and that a pattern matching no member,
+// or several, is an error.
+func TestGet_ArchiveMemberPattern(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	content, err := os.ReadFile(filepath.Join("testdata", "nested.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := serveContent(t, content)
+	source := func(vers, os, arch string) (string, string, error) {
+		return "http://" + address + "/nested.tar.gz", "", nil
+	}
+
+	path, err := binr.Get(ctx, "myapp", "tool", "v1.0.0", source,
+		binr.WithArchiveMemberPattern(regexp.MustCompile(`^[^/]+/bin/mytool$`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extracted, err := os.ReadFile(path); err != nil || string(extracted) != "mytool\n" {
+		t.Fatalf("expected the nested command extracted, got %q (%v)", extracted, err)
+	}
+
+	for _, pattern := range []string{`mytool`, `missing`} {
+		_, err = binr.Get(ctx, "otherapp", "tool", "v1.0.0", source,
+			binr.WithArchiveMemberPattern(regexp.MustCompile(pattern)))
+		if err == nil || !strings.Contains(err.Error(), "exactly one file") {
+			t.Fatalf("%v: expected an error selecting exactly one file, got %v", pattern, err)
+		}
+	}
+
+	// A single compressed file matches by its decompressed name
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, _ = zw.Write([]byte("mytool\n"))
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	address = serveContent(t, compressed.Bytes())
+	source = func(vers, os, arch string) (string, string, error) {
+		return "http://" + address + "/mytool-linux.gz", "", nil
+	}
+	if _, err = binr.Get(ctx, "single", "tool", "v1.0.0", source,
+		binr.WithArchiveMemberPattern(regexp.MustCompile(`^mytool-linux$`))); err != nil {
+		t.Fatal(err)
+	}
+	_, err = binr.Get(ctx, "single", "tool", "v1.1.0", source,
+		binr.WithArchiveMemberPattern(regexp.MustCompile(`^other$`)))
+	if err == nil || !strings.Contains(err.Error(), "exactly one file") {
+		t.Fatalf("expected an error for a compressed file not matching, got %v", err)
+	}
+}
+
+// TestGet_ChecksumFile ensures that the checksum for a command is selected
+// from a checksum URL listing several files, and that a list without the
+// command's file results in an actionable error.
+func TestGet_ChecksumFile(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	sum := testbinChecksum(t)
+	sums := serveFiles(t, map[string][]byte{
+		"/SHA256SUMS": []byte(strings.Repeat("a", 64) + "  other\n" + sum + " *testbin\n"),
+		"/MISSING":    []byte(strings.Repeat("a", 64) + "  other\n" + sum + "  testbin.exe\n"),
+		"/BYURL": []byte(fmt.Sprintf("http://%v/v1.0.0/%v/other/testbin  %v\nhttp://%v/v1.0.0/%v/%v/testbin  %v\n",
+			serverAddress, runtime.GOOS, strings.Repeat("a", 64),
+			serverAddress, runtime.GOOS, runtime.GOARCH, sum)),
+		"/BYPATH": []byte(fmt.Sprintf("%v  v1.0.0/%v/%v/testbin\n", sum, runtime.GOOS, runtime.GOARCH)),
+	})
+	source := func(sums string) binr.Source {
+		return func(vers, os, arch string) (string, string, error) {
+			return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch),
+				"http://" + sums, nil
+		}
+	}
+
+	_, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source(sums+"/MISSING"))
+	if !errors.Is(err, binr.ErrChecksumFormat) {
+		t.Fatalf("expected ErrChecksumFormat, got %v", err)
+	}
+	for _, expected := range []string{`"testbin"`, "2 line(s)", sums + "/MISSING"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected error to include %v. got %q", expected, err)
+		}
+	}
+
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source(sums+"/SHA256SUMS")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries keyed by the full source URL, or its path
+	for i, file := range []string{"/BYURL", "/BYPATH"} {
+		namespace := fmt.Sprintf("otherapp%v", i)
+		if _, err := binr.Get(ctx, namespace, "testbin", "v1.0.0", source(sums+file)); err != nil {
+			t.Fatalf("%v: %v", file, err)
+		}
+	}
+}
+
+// TestGetReader ensures that a binary can be streamed without installing it
+// into a namespace, both when downloaded and when already cached.
+func TestGetReader(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	expected := testbinChecksum(t)
+
+	for i := 0; i < 2; i++ {
+		r, sum, err := binr.GetReader(ctx, "v1.0.0", source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bb, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum != expected || fmt.Sprintf("%x", sha256.Sum256(bb)) != expected {
+			t.Fatalf("expected checksum %v, got %v", expected, sum)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != ".cache" {
+		t.Fatal("GetReader should not have installed into a namespace")
+	}
+}
+
+// TestGet_Lease ensures that concurrent requests for the same command result
+// in only one download, with the others waiting for and using its result.
+func TestGet_Lease(t *testing.T) {
+	setupTestGet(t)
+	content, err := os.ReadFile(filepath.Join("testbins", "v1.0.0", runtime.GOOS, runtime.GOARCH, "testbin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(300 * time.Millisecond) // a slow download
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+
+	source := binr.InlineSource(testbinChecksum(t), func(vers, os, arch string) (string, string, error) {
+		return server.URL + "/testbin", "", nil
+	})
+
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, 3)
+	)
+	for _, namespace := range []string{"app1", "app2", "app3"} {
+		wg.Add(1)
+		go func(namespace string) {
+			defer wg.Done()
+			_, err := binr.Get(context.Background(), namespace, "testbin", "v1.0.0", source)
+			errs <- err
+		}(namespace)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("expected 1 download, got %v", requests)
+	}
+}
+
+// TestGet_LeaseTimeout ensures a lease timeout which is not positive, or
+// is shorter than a lease can be renewed within, is tolerated.
+func TestGet_LeaseTimeout(t *testing.T) {
+	for _, timeout := range []time.Duration{0, -time.Second, 1} {
+		t.Run(timeout.String(), func(t *testing.T) {
+			serverAddress := setupTestGet(t)
+			source := binr.InlineSource(testbinChecksum(t), func(vers, os, arch string) (string, string, error) {
+				return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+			})
+			if _, err := binr.Get(context.Background(), "myapp", "testbin", "v1.0.0", source, binr.WithLeaseTimeout(timeout)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestGet_Prereleases ensures a pre-release becomes the latest version only
+// if no release is installed, unless pre-releases are included.
+func TestGet_Prereleases(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	versions := []string{"v1.9.0", "v1.9.1", "v2.0.0-rc.1", "v2.0.0-rc.2", "v2.0.0"}
+	files := map[string][]byte{}
+	for _, v := range versions {
+		files["/"+v+"/mybin"] = []byte(v)
+	}
+	address := serveFiles(t, files)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mybin", address, vers), "", nil
+	}
+
+	tests := []struct {
+		namespace string
+		include   bool
+		installs  []string
+		latest    []string // after each install
+	}{
+		{"excluded", false,
+			[]string{"v2.0.0-rc.1", "v1.9.0", "v2.0.0-rc.2", "v2.0.0"},
+			[]string{"v2.0.0-rc.1", "v1.9.0", "v1.9.0", "v2.0.0"}},
+		{"included", true,
+			[]string{"v1.9.0", "v2.0.0-rc.1", "v1.9.1", "v2.0.0"},
+			[]string{"v1.9.0", "v2.0.0-rc.1", "v2.0.0-rc.1", "v2.0.0"}},
+	}
+	for _, test := range tests {
+		unversioned, err := binr.Path(test.namespace, "mybin", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, version := range test.installs {
+			if _, err = binr.Get(ctx, test.namespace, "mybin", version, source, binr.WithIncludePrereleases(test.include)); err != nil {
+				t.Fatal(err)
+			}
+			if latest, err := os.ReadFile(unversioned); err != nil || string(latest) != test.latest[i] {
+				t.Fatalf("%v: expected %v latest after installing %v, got %q (%v)", test.namespace, test.latest[i], version, latest, err)
+			}
+		}
+	}
+}
+
+// TestGet_NoDowngrade ensures a version lower than that installed is refused
+// only WithNoDowngrade, and that those installed continue to be provided.
+func TestGet_NoDowngrade(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	files := map[string][]byte{}
+	for _, v := range []string{"v1.0.0", "v1.1.0", "v2.0.0"} {
+		files["/"+v+"/mybin"] = []byte(v)
+	}
+	address := serveFiles(t, files)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mybin", address, vers), "", nil
+	}
+
+	for _, version := range []string{"v1.1.0", "v2.0.0"} {
+		if _, err := binr.Get(ctx, "myapp", "mybin", version, source, binr.WithNoDowngrade()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithNoDowngrade()); !errors.Is(err, binr.ErrDowngrade) {
+		t.Fatalf("expected ErrDowngrade, got %v", err)
+	}
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.1.0", source, binr.WithNoDowngrade()); err != nil {
+		t.Fatalf("expected an installed version provided, got %v", err)
+	}
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source); err != nil {
+		t.Fatalf("expected a downgrade by default, got %v", err)
+	}
+}
+
+// TestGet_ReconcileLatest ensures the unversioned link is repaired to
+// target the newest version installed WithReconcileLatest.
+func TestGet_ReconcileLatest(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	address := serveFiles(t, map[string][]byte{
+		"/v1.0.0/mybin": []byte("v1.0.0"),
+		"/v2.0.0/mybin": []byte("v2.0.0"),
+	})
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mybin", address, vers), "", nil
+	}
+	for _, version := range []string{"v1.0.0", "v2.0.0"} {
+		if _, err := binr.Get(ctx, "myapp", "mybin", version, source); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Drift the unversioned link to the older version
+	older, _ := binr.Path("myapp", "mybin", "v1.0.0")
+	unversioned, _ := binr.Path("myapp", "mybin", "")
+	target, err := os.Readlink(older)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Remove(unversioned); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(target, unversioned); err != nil {
+		t.Fatal(err)
+	}
+
+	latest := func() string {
+		t.Helper()
+		content, err := os.ReadFile(unversioned)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+	if _, err = binr.Get(ctx, "myapp", "mybin", "v1.0.0", source); err != nil {
+		t.Fatal(err)
+	}
+	if latest() != "v1.0.0" {
+		t.Fatal("expected the drift to remain by default")
+	}
+	if _, err = binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithReconcileLatest()); err != nil {
+		t.Fatal(err)
+	}
+	if latest() != "v2.0.0" {
+		t.Fatalf("expected the unversioned link repaired to v2.0.0, got %v", latest())
+	}
+}
+
+// TestGet_CleanOnError ensures that a failure part way through linking
+// leaves no links behind when WithCleanOnError is provided, and restores
+// those which it replaced.
+func TestGet_CleanOnError(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+
+	// Block creation of the unversioned link with a directory in its place,
+	// such that the versioned link succeeds and the unversioned fails.
+	for _, namespace := range []string{"dirty", "clean", "restored"} {
+		unversioned, err := binr.Path(namespace, "testbin", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(unversioned, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Default: the versioned link remains
+	if _, err := binr.Get(ctx, "dirty", "testbin", "v1.0.0", source); err == nil {
+		t.Fatal("expected an error creating the unversioned link")
+	}
+	versioned, _ := binr.Path("dirty", "testbin", "v1.0.0")
+	if _, err := os.Lstat(versioned); err != nil {
+		t.Fatalf("expected the versioned link to remain without WithCleanOnError. %v", err)
+	}
+
+	// WithCleanOnError: the versioned link is removed
+	if _, err := binr.Get(ctx, "clean", "testbin", "v1.0.0", source, binr.WithCleanOnError()); err == nil {
+		t.Fatal("expected an error creating the unversioned link")
+	}
+	versioned, _ = binr.Path("clean", "testbin", "v1.0.0")
+	if _, err := os.Lstat(versioned); !os.IsNotExist(err) {
+		t.Fatalf("expected the versioned link to be removed. %v", err)
+	}
+
+	// WithCleanOnError: a versioned link replaced is restored
+	versioned, _ = binr.Path("restored", "testbin", "v1.0.0")
+	if err := os.Symlink("previous", versioned); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := binr.Get(ctx, "restored", "testbin", "v1.0.0", source, binr.WithCleanOnError()); err == nil {
+		t.Fatal("expected an error creating the unversioned link")
+	}
+	if target, err := os.Readlink(versioned); err != nil || target != "previous" {
+		t.Fatalf("expected the versioned link restored to its previous target, got %q (%v)", target, err)
+	}
+
+	// WithCleanOnError: links are rolled back when a step after linking
+	// fails, here updating the unversioned link to a release not served.
+	lister := staticLister{"v1.1.0", "v1.0.0"}
+	if _, err := binr.Get(ctx, "updated", "testbin", "v1.0.0", source, binr.WithCleanOnError(),
+		binr.WithLister(&lister), binr.WithUpdate()); err == nil {
+		t.Fatal("expected an error updating to a release not served")
+	}
+	for _, version := range []string{"v1.0.0", ""} {
+		path, _ := binr.Path("updated", "testbin", version)
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected link %v to be removed after the update failed. %v", path, err)
+		}
+	}
+}
+
+// TestList_SymlinkLoop ensures a link which resolves to itself is reported
+// as a loop, and is replaced by Get.
+func TestList_SymlinkLoop(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	path, err := binr.Path("myapp", "testbin", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(filepath.Base(path), path); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = binr.List("myapp"); !errors.Is(err, binr.ErrSymlinkLoop) {
+		t.Fatalf("expected ErrSymlinkLoop, got %v", err)
+	}
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = binr.List("myapp"); err != nil {
+		t.Fatalf("expected the loop to be replaced, got %v", err)
+	}
+}
+
+// TestRelink ensures that a namespace's links can be rebuilt from the cache
+// after they were removed.
+func TestRelink(t *testing.T) {
+	serverAddress := setupTestGet(t)
+	path, err := binr.Get(context.Background(), "myapp", "testbin", "v1.0.0",
+		func(vers, os, arch string) (string, string, error) {
+			return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.RemoveAll(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = binr.Relink("myapp", "testbin", "v1.0.0", strings.Repeat("0", 64)); err == nil {
+		t.Fatal("expected an error relinking to an object not in the cache")
+	}
+	// An abbreviated checksum is accepted if unambiguous
+	sum := testbinChecksum(t)
+	decoy := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", sum[:8]+strings.Repeat("0", 56))
+	if err = os.WriteFile(decoy, []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = binr.Relink("myapp", "testbin", "v1.0.0", sum[:8]); err == nil {
+		t.Fatal("expected an error relinking with an ambiguous checksum prefix")
+	}
+	if err = binr.Relink("myapp", "testbin", "v1.0.0", sum[:12]); err != nil {
+		t.Fatal(err)
+	}
+	unversioned, _ := binr.Path("myapp", "testbin", "")
+	for _, p := range []string{path, unversioned} {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("expected %v to be relinked. %v", p, err)
+		}
+	}
+}
+
+// TestLink ensures a command fetched into the cache can be linked without
+// consulting a Source, and that its integrity is optionally verified.
+func TestLink(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	r, sum, err := binr.GetReader(ctx, "v1.0.0", func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	if _, err = binr.Link("myapp", "testbin", "v1.0.0", strings.Repeat("0", 64)); err == nil {
+		t.Fatal("expected an error linking an object not in the cache")
+	}
+	path, err := binr.Link("myapp", "testbin", "v1.0.0", sum, binr.WithVerifyCached())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(path).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "OK\n" {
+		t.Fatalf("expected the linked command to run, got %q", out)
+	}
+
+	// A corrupted object is linked only if not verified
+	object := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", sum)
+	if err = os.Chmod(object, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(object, []byte("corrupt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = binr.Link("otherapp", "testbin", "v1.0.0", sum, binr.WithVerifyCached()); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	if _, err = binr.Link("otherapp", "testbin", "v1.0.0", sum); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGet_Preflight ensures that WithPreflight fails before downloading when
+// the command does not exist, and is skipped by servers which do not support
+// HEAD requests.
+func TestGet_Preflight(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	var downloads int
+	onDownload := binr.WithOnDownload(func(string) { downloads++ })
+
+	address := serveFiles(t, map[string][]byte{"/mytool": []byte("mytool")})
+	_, err := binr.Get(ctx, "myapp", "mytool", "v1.0.0",
+		func(vers, os, arch string) (string, string, error) {
+			return "http://" + address + "/missing", "", nil
+		}, binr.WithPreflight(), onDownload)
+	if !errors.Is(err, binr.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if downloads != 0 {
+		t.Fatal("expected preflight to fail before downloading")
+	}
+
+	// The test binaries server does not support HEAD
+	serverAddress, err := serveBinaries(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0",
+		func(vers, os, arch string) (string, string, error) {
+			return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+		}, binr.WithPreflight(), onDownload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if downloads != 1 {
+		t.Fatal("expected the download to proceed when HEAD is not supported")
+	}
+}
+
+// TestGet_TempNamer ensures that partial downloads are named using the
+// provided namer, and are not left behind after a successful install.
+func TestGet_TempNamer(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	cacheDir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache")
+	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	// A partial of the given name blocks the download
+	blocking := filepath.Join(cacheDir, "blocked.partial")
+	if err := os.WriteFile(blocking, []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source,
+		binr.WithTempNamer(func() string { return "blocked" }))
+	if err == nil || !strings.Contains(err.Error(), blocking) {
+		t.Fatalf("expected an error naming the existing partial %v, got %v", blocking, err)
+	}
+	if _, err = os.Stat(blocking); err != nil {
+		t.Fatalf("expected the existing partial to be left in place. %v", err)
+	}
+
+	// A successful install leaves no partial behind
+	_, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source,
+		binr.WithTempNamer(func() string { return "deterministic" }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "deterministic.partial")); !os.IsNotExist(err) {
+		t.Fatalf("expected the partial to be moved into place. %v", err)
+	}
+}
+
+// TestGet_PlatformHeaderCheck ensures that WithPlatformHeaderCheck installs
+// a command built for the requested platform, and refuses one which is not.
+func TestGet_PlatformHeaderCheck(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+
+	// A source which serves the current platform's test binary regardless of
+	// the arch requested, except for the current arch, for which it has none.
+	source := func(vers, os, arch string) (string, string, error) {
+		if arch == runtime.GOARCH {
+			return fmt.Sprintf("http://%v/missing", serverAddress), "", nil
+		}
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, runtime.GOARCH), "", nil
+	}
+	other := "amd64"
+	if runtime.GOARCH == other {
+		other = "arm64"
+	}
+
+	// The binary served for the fallback arch is for the current arch.
+	_, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source,
+		binr.WithArchFallback([]string{other}), binr.WithPlatformHeaderCheck())
+	if err == nil || !strings.Contains(err.Error(), "another platform") {
+		t.Fatalf("expected a platform mismatch error, got %v", err)
+	}
+
+	// The binary served for the current arch is accepted.
+	_, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0",
+		func(vers, os, arch string) (string, string, error) {
+			return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+		}, binr.WithPlatformHeaderCheck())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGet_KeepArchive ensures that the archive from which a command is
+// extracted is retained in the requested directory.
+func TestGet_KeepArchive(t *testing.T) {
+	setupTestGet(t)
+	content, err := os.ReadFile(filepath.Join("testdata", "mytool.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := serveContent(t, content)
+	dir := filepath.Join(t.TempDir(), "archives")
+
+	_, err = binr.Get(context.Background(), "myapp", "mytool", "v1.0.0",
+		func(vers, os, arch string) (string, string, error) {
+			return "http://" + address + "/releases/mytool.tar.gz?download=1", "", nil
+		}, binr.WithKeepArchive(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := os.ReadFile(filepath.Join(dir, "mytool.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(kept, content) {
+		t.Fatal("kept archive differs from that downloaded")
+	}
+}
+
+// TestGet_AllowedHosts ensures that commands are only downloaded from
+// allowed hosts.
+func TestGet_AllowedHosts(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+
+	_, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source,
+		binr.WithAllowedHosts([]string{"*.example.com", "example.com"}))
+	if !errors.Is(err, binr.ErrHostNotAllowed) {
+		t.Fatalf("expected ErrHostNotAllowed, got %v", err)
+	}
+
+	_, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source,
+		binr.WithAllowedHosts([]string{"*.example.com", "127.0.0.1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGet_InsecureHTTP ensures plain http URLs, and redirects to them, are
+// refused other than to the loopback interface unless explicitly allowed.
+func TestGet_InsecureHTTP(t *testing.T) {
+	ctx := context.Background()
+	insecure := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://example.invalid/%v/%v/%v/testbin", vers, os, arch), "", nil
+	}
+	_, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", insecure)
+	if !errors.Is(err, binr.ErrInsecureURL) {
+		t.Fatalf("expected ErrInsecureURL, got %v", err)
+	}
+	_, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", insecure, binr.WithAllowInsecureHTTP())
+	if err == nil || errors.Is(err, binr.ErrInsecureURL) {
+		t.Fatalf("expected the request be attempted, got %v", err)
+	}
+
+	// Redirects from loopback to elsewhere
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://example.invalid"+r.URL.Path, http.StatusFound)
+	}))
+	t.Cleanup(server.Close)
+	redirected := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("%v/%v/%v/%v/testbin", server.URL, vers, os, arch), "", nil
+	}
+	_, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", redirected)
+	if !errors.Is(err, binr.ErrInsecureURL) {
+		t.Fatalf("expected ErrInsecureURL on redirect, got %v", err)
+	}
+}
+
+// TestMigrateCache ensures that the cache can be moved without leaving
+// dangling links, and that the new location is then used via WithCacheDir.
+func TestMigrateCache(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := binr.InlineSource(testbinChecksum(t), func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	})
+	oldDir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache")
+	newDir := filepath.Join(t.TempDir(), "cache")
+
+	path, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Migrating twice is the same as once
+	for i := 0; i < 2; i++ {
+		if err = binr.MigrateCache(ctx, oldDir, newDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = os.Stat(oldDir); !os.IsNotExist(err) {
+		t.Fatalf("expected old cache to be removed. %v", err)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, _ := filepath.EvalSymlinks(newDir); filepath.Dir(resolved) != expected {
+		t.Fatalf("expected link to resolve into %v, got %v", expected, resolved)
+	}
+
+	// The migrated cache is used when provided
+	var downloads int
+	_, err = binr.Get(ctx, "otherapp", "testbin", "v1.0.0", source,
+		binr.WithCacheDir(newDir), binr.WithOnDownload(func(string) { downloads++ }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if downloads != 0 {
+		t.Fatal("expected the command to be found in the migrated cache")
+	}
+}
+
+// TestMigrateCache_Options ensures the caller's options apply to the
+// migration, such that objects addressed by a custom Hasher are verified
+// with it, and that the migration waits on the lock of the old cache.
+func TestMigrateCache_Options(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	hasher := binr.WithHasher(fnvHasher{})
+	oldDir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache")
+	newDir := filepath.Join(t.TempDir(), "cache")
+
+	path, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, hasher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A held lock is waited on until the context is done
+	lock := filepath.Join(oldDir, "gc.lock")
+	if err = os.WriteFile(lock, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err = binr.MigrateCache(canceled, oldDir, newDir, hasher); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the migration to wait on the cache lock, got %v", err)
+	}
+	if err = os.Remove(lock); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = binr.MigrateCache(ctx, oldDir, newDir, hasher); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, _ := filepath.EvalSymlinks(newDir); filepath.Dir(resolved) != expected {
+		t.Fatalf("expected link to resolve into %v, got %v", expected, resolved)
+	}
+}
+
+// TestGet_TraceExtractor ensures that a trace ID extracted from the context
+// is included in log lines and in returned errors.
+func TestGet_TraceExtractor(t *testing.T) {
+	serverAddress := setupTestGet(t)
+
+	var buf bytes.Buffer
+	defaultLogger := zlog.Logger
+	zlog.Logger = zerolog.New(&buf)
+	defaultLevel := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	t.Cleanup(func() {
+		zlog.Logger = defaultLogger
+		zerolog.SetGlobalLevel(defaultLevel)
+	})
+
+	type traceKey struct{}
+	ctx := context.WithValue(context.Background(), traceKey{}, "abc123")
+	extractor := binr.WithTraceExtractor(func(ctx context.Context) string {
+		id, _ := ctx.Value(traceKey{}).(string)
+		return id
+	})
+
+	_, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0",
+		func(vers, os, arch string) (string, string, error) {
+			return fmt.Sprintf("http://%v/missing", serverAddress), "", nil
+		}, extractor)
+	if err == nil || !strings.Contains(err.Error(), "abc123") {
+		t.Fatalf("expected an error including the trace ID, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) == 0 {
+		t.Fatal("expected log output")
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, `"trace_id":"abc123"`) {
+			t.Fatalf("expected log line to include the trace ID: %v", line)
+		}
+	}
+}
+
+// TestHTMLDirLister ensures versions are read from a directory listing,
+// ignoring duplicates and links which are not versions, newest first.
+func TestHTMLDirLister(t *testing.T) {
+	index := []byte(`<html><body><pre>
+<a href="../">../</a>
+<a href="v1.2.0/">v1.2.0/</a>
+<a href="v1.10.0/">v1.10.0/</a>
+<a href="v1.10.0/">v1.10.0/</a>
+<a href="v2.0.0-rc.1/">v2.0.0-rc.1/</a>
+<a href="vlatest/">vlatest/</a>
+<a href="v1.9.1/">v1.9.1/</a>
+</pre></body></html>`)
+	addr := serveFiles(t, map[string][]byte{"/mytool/": index})
+
+	lister := binr.HTMLDirLister(fmt.Sprintf("http://%v/mytool/", addr),
+		regexp.MustCompile(`href="(v[^/"]*)/?"`))
+	versions, err := lister.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"v2.0.0-rc.1", "v1.10.0", "v1.9.1", "v1.2.0"}
+	if strings.Join(versions, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected versions %v, got %v", expected, versions)
+	}
+
+	// A missing index is not found
+	lister = binr.HTMLDirLister(fmt.Sprintf("http://%v/missing/", addr),
+		regexp.MustCompile(`href="(v[^/"]*)/?"`))
+	if _, err = lister.List(context.Background()); !errors.Is(err, binr.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// Options apply to the request of the index
+	var requested string
+	credentials := binr.WithCredentialProvider(func(ctx context.Context, url string) (string, string, error) {
+		requested = url
+		return "Authorization", "Bearer token", nil
+	})
+	lister = binr.HTMLDirLister(fmt.Sprintf("http://%v/mytool/", addr),
+		regexp.MustCompile(`href="(v[^/"]*)/?"`), credentials)
+	if _, err = lister.List(context.Background()); err != nil || requested == "" {
+		t.Fatalf("expected the credential provider to be used, got %v", err)
+	}
+	lister = binr.HTMLDirLister(fmt.Sprintf("http://%v/mytool/", addr),
+		regexp.MustCompile(`href="(v[^/"]*)/?"`), binr.WithAllowedHosts([]string{"example.com"}))
+	if _, err = lister.List(context.Background()); !errors.Is(err, binr.ErrHostNotAllowed) {
+		t.Fatalf("expected ErrHostNotAllowed, got %v", err)
+	}
+}
+
+// TestGet_ResumeVerify ensures a partial download is retained and resumed
+// only if it is consistent with its source, and is otherwise discarded and
+// downloaded from the start: when the source has changed since it was
+// written, when its size disagrees with that of the source, and when it has
+// no validators recorded by which it could be checked.
+func TestGet_ResumeVerify(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	content := bytes.Repeat([]byte("binr"), 1<<14)
+	sum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	var (
+		etag     = `"v1"`
+		requests int
+		ranges   []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		ranges = append(ranges, r.Header.Get("Range"))
+		r.Header.Del("If-Range") // as would a server which does not support it
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("ETag", etag)
+		if requests <= 2 { // interrupted half way
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	source := binr.InlineSource(sum, func(vers, os, arch string) (string, string, error) {
+		return server.URL + "/mybin", "", nil
+	})
+	cache := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache")
+	partial := filepath.Join(cache, sum+".partial")
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithResumeVerify()); err == nil {
+		t.Fatal("expected the interrupted download to fail")
+	}
+	if info, err := os.Stat(partial); err != nil || info.Size() != int64(len(content)/2) {
+		t.Fatalf("expected the partial to be retained (%v)", err)
+	}
+
+	// Unchanged, and so resumed (though again interrupted)
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithResumeVerify()); err == nil {
+		t.Fatal("expected the interrupted download to fail")
+	}
+	if ranges[1] != fmt.Sprintf("bytes=%d-", len(content)/2) {
+		t.Fatalf("expected the partial to be resumed, got ranges %q", ranges)
+	}
+
+	// Changed since the partial was written
+	etag = `"v2"`
+	if err := os.Truncate(partial, int64(len(content)/2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithResumeVerify()); err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 4 || ranges[2] == "" || ranges[3] != "" {
+		t.Fatalf("expected the changed source to be downloaded from the start, got ranges %q", ranges)
+	}
+	if _, err := os.Stat(partial + ".validators"); !os.IsNotExist(err) {
+		t.Fatalf("expected the validators to be removed with the partial, got %v", err)
+	}
+
+	// Larger than the source, and without validators
+	for i, validators := range []bool{true, false} {
+		if err := os.Remove(filepath.Join(cache, sum)); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(partial, append(content, "binr"...), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if validators {
+			if err := os.WriteFile(partial+".validators", []byte(etag+"\n\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ranges = nil
+		namespace := fmt.Sprintf("myapp%v", i) // not yet linked
+		path, err := binr.Get(ctx, namespace, "mybin", "v1.0.0", source, binr.WithResumeVerify())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ranges[len(ranges)-1] != "" {
+			t.Fatalf("expected the partial (validators %v) to be downloaded from the start, got ranges %q", validators, ranges)
+		}
+		if installed, err := os.ReadFile(path); err != nil || !bytes.Equal(installed, content) {
+			t.Fatalf("expected the download to be installed intact (%v)", err)
+		}
+	}
+}
+
+// TestUpdateAvailable ensures an update is reported when the checksum
+// published by the Source differs from that of the installed command.
+func TestUpdateAvailable(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	sum := testbinChecksum(t)
+	source := func(sum string) binr.Source {
+		return binr.InlineSource(sum, func(vers, os, arch string) (string, string, error) {
+			return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+		})
+	}
+
+	// Not installed
+	available, latest, err := binr.UpdateAvailable(ctx, "myapp", "testbin", "v1.0.0", source(sum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !available || latest != "v1.0.0" {
+		t.Fatalf("expected update v1.0.0 available when not installed, got %v %q", available, latest)
+	}
+
+	// Installed and current
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source(sum)); err != nil {
+		t.Fatal(err)
+	}
+	if available, _, err = binr.UpdateAvailable(ctx, "myapp", "testbin", "v1.0.0", source(sum)); err != nil {
+		t.Fatal(err)
+	}
+	if available {
+		t.Fatal("expected no update available for the installed command")
+	}
+
+	// Republished with a different checksum
+	other := strings.Repeat("0", 64)
+	if available, _, err = binr.UpdateAvailable(ctx, "myapp", "testbin", "v1.0.0", source(other)); err != nil {
+		t.Fatal(err)
+	}
+	if !available {
+		t.Fatal("expected an update available when the published checksum differs")
+	}
+}
+
+// TestGet_BeforeLink ensures a command rejected before linking is neither
+// installed nor left in the cache.
+func TestGet_BeforeLink(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	sum := testbinChecksum(t)
+	source := binr.InlineSource(sum, func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	})
+
+	var received string
+	errRejected := errors.New("rejected")
+	reject := binr.WithBeforeLink(func(path, checksum string) error {
+		if filepath.Base(path) != checksum {
+			t.Errorf("expected path to the cached object %q, got %q", checksum, path)
+		}
+		received = checksum
+		return errRejected
+	})
+
+	path, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, reject)
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("expected the rejection error, got %v", err)
+	}
+	if received != sum {
+		t.Fatalf("expected checksum %q, got %q", sum, received)
+	}
+	if _, err = os.Lstat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no link for a rejected command, got %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", sum)); !os.IsNotExist(err) {
+		t.Fatalf("expected a rejected command to be removed from the cache, got %v", err)
+	}
+
+	// Accepted
+	accept := binr.WithBeforeLink(func(string, string) error { return nil })
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, accept); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestExport ensures an installed command can be copied out of the store.
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	sum := testbinChecksum(t)
+	source := binr.InlineSource(sum, func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	})
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "testbin")
+	if err := binr.Export("myapp", "testbin", "v1.0.0", dest); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Lstat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.Mode().IsRegular() || fi.Mode().Perm()&0111 == 0 {
+		t.Fatalf("expected an executable regular file, got %v", fi.Mode())
+	}
+	content, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprintf("%x", sha256.Sum256(content)) != sum {
+		t.Fatal("exported command does not match its checksum")
+	}
+
+	// A corrupted object is not exported
+	object := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", sum)
+	if err = os.Chmod(object, 0755); err != nil { // cached objects are read-only
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(object, []byte("corrupt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dest = filepath.Join(t.TempDir(), "testbin")
+	if err = binr.Export("myapp", "testbin", "v1.0.0", dest); err == nil {
+		t.Fatal("expected an error exporting a corrupted command")
+	}
+	if _, err = os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("expected no file exported, got %v", err)
+	}
+
+	// Not installed
+	if err = binr.Export("myapp", "testbin", "v2.0.0", dest); err == nil {
+		t.Fatal("expected an error exporting a command which is not installed")
+	}
+}
+
+// TestGet_RateLimiter ensures requests to a host wait for its limiter.
+func TestGet_RateLimiter(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	sum := testbinChecksum(t)
+	source := binr.InlineSource(sum, func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	})
+
+	// A limiter with no tokens available and none forthcoming does not
+	// permit the download.
+	limiter := rate.NewLimiter(rate.Limit(0), 0)
+	_, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source,
+		binr.WithRateLimiter("127.0.0.1", limiter))
+	if err == nil {
+		t.Fatal("expected the rate limiter to prevent the request")
+	}
+
+	// A limiter for another host does not apply.
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source,
+		binr.WithRateLimiter("example.com", limiter)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// memFS is an in-memory Filesystem, such that operations on the store can
+// be shown not to touch the disk.
+type memFS struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode
+}
+
+// memNode is a file, directory or link of a memFS.
+type memNode struct {
+	data    []byte
+	mode    os.FileMode
+	target  string // of a link
+	modTime time.Time
+}
+
+func newMemFS() *memFS {
+	return &memFS{nodes: map[string]*memNode{}}
+}
+
+// resolve the links of the named path, returning the path ultimately
+// named and its node, which is nil if it does not exist.
+func (f *memFS) resolve(name string) (string, *memNode) {
+	name = filepath.Clean(name)
+	for i := 0; i < 40; i++ {
+		n := f.nodes[name]
+		if n == nil || n.mode&os.ModeSymlink == 0 {
+			return name, n
+		}
+		target := n.target
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(name), target)
+		}
+		name = filepath.Clean(target)
+	}
+	return name, nil
+}
+
+func (f *memFS) Open(name string) (binr.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (f *memFS) OpenFile(name string, flag int, perm os.FileMode) (binr.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path, n := f.resolve(name)
+	switch {
+	case n == nil && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	case n == nil:
+		n = &memNode{mode: perm, modTime: time.Now()}
+		f.nodes[path] = n
+	case flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	case n.mode.IsDir():
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	case flag&os.O_TRUNC != 0:
+		n.data, n.modTime = nil, time.Now()
+	}
+	file := &memFile{fs: f, name: path, node: n}
+	if flag&os.O_APPEND != 0 {
+		file.offset = int64(len(n.data))
+	}
+	return file, nil
+}
+
+func (f *memFS) Stat(name string) (os.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path, n := f.resolve(name)
+	if n == nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return memInfo{filepath.Base(path), *n}, nil
+}
+
+func (f *memFS) MkdirAll(path string, perm os.FileMode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for path = filepath.Clean(path); ; path = filepath.Dir(path) {
+		if n := f.nodes[path]; n != nil {
+			if !n.mode.IsDir() {
+				return &os.PathError{Op: "mkdir", Path: path, Err: syscall.ENOTDIR}
+			}
+			return nil
+		}
+		f.nodes[path] = &memNode{mode: os.ModeDir | perm, modTime: time.Now()}
+		if filepath.Dir(path) == path {
+			return nil
+		}
+	}
+}
+
+func (f *memFS) ReadDir(name string) ([]os.DirEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	dir, n := f.resolve(name)
+	if n == nil {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+	var entries []os.DirEntry
+	for path, n := range f.nodes {
+		if filepath.Dir(path) == dir && path != dir {
+			entries = append(entries, fs.FileInfoToDirEntry(memInfo{filepath.Base(path), *n}))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (f *memFS) Rename(oldpath, newpath string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	n := f.nodes[oldpath]
+	if n == nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+	delete(f.nodes, oldpath)
+	f.nodes[newpath] = n
+	return nil
+}
+
+func (f *memFS) Remove(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name = filepath.Clean(name)
+	n := f.nodes[name]
+	if n == nil {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	if n.mode.IsDir() {
+		for path := range f.nodes {
+			if filepath.Dir(path) == name && path != name {
+				return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+			}
+		}
+	}
+	delete(f.nodes, name)
+	return nil
+}
+
+func (f *memFS) Symlink(oldname, newname string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	newname = filepath.Clean(newname)
+	if f.nodes[newname] != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: os.ErrExist}
+	}
+	f.nodes[newname] = &memNode{mode: os.ModeSymlink | 0777, target: oldname, modTime: time.Now()}
+	return nil
+}
+
+func (f *memFS) Readlink(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := f.nodes[filepath.Clean(name)]
+	if n == nil {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrNotExist}
+	} else if n.mode&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+	}
+	return n.target, nil
+}
+
+func (f *memFS) Chtimes(name string, atime, mtime time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, n := f.resolve(name)
+	if n == nil {
+		return &os.PathError{Op: "chtimes", Path: name, Err: os.ErrNotExist}
+	}
+	n.modTime = mtime
+	return nil
+}
+
+func (f *memFS) Chmod(name string, mode os.FileMode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, n := f.resolve(name)
+	if n == nil {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+	n.mode = n.mode.Type() | mode.Perm()
+	return nil
+}
+
+// memFile is an open file of a memFS.
+type memFile struct {
+	fs     *memFS
+	name   string
+	node   *memNode
+	offset int64
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if end := f.offset + int64(len(p)); end > int64(len(f.node.data)) {
+		f.node.data = append(f.node.data, make([]byte, end-int64(len(f.node.data)))...)
+	}
+	copy(f.node.data[f.offset:], p)
+	f.offset += int64(len(p))
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Close() error { return nil }
+
+func (f *memFile) Sync() error { return nil }
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	return memInfo{filepath.Base(f.name), *f.node}, nil
+}
+
+// memInfo describes a memNode.
+type memInfo struct {
+	name string
+	node memNode
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i memInfo) Mode() os.FileMode  { return i.node.mode }
+func (i memInfo) ModTime() time.Time { return i.node.modTime }
+func (i memInfo) IsDir() bool        { return i.node.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
+
+// readFile returns the content of the named file of the memFS.
+func (f *memFS) readFile(t *testing.T, name string) string {
+	t.Helper()
+	file, err := f.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// TestGet_Filesystem ensures that the provided Filesystem is used for all
+// operations on the store, such that an in-memory filesystem leaves nothing
+// on disk, including when exporting commands and migrating the cache.
+func TestGet_Filesystem(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	address := serveFiles(t, map[string][]byte{"/v1.0.0/mytool": []byte("mytool")})
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mytool", address, vers), "", nil
+	}
+	mem := newMemFS()
+	fs := binr.WithFilesystem(mem)
+
+	path, err := binr.Get(ctx, "myapp", "mytool", "v1.0.0", source, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := mem.readFile(t, path); content != "mytool" {
+		t.Fatalf("expected the command in the filesystem, got %q", content)
+	}
+	unversioned, _ := binr.Path("myapp", "mytool", "")
+	if _, err = mem.Readlink(unversioned); err != nil {
+		t.Fatalf("expected the unversioned link in the filesystem. %v", err)
+	}
+
+	exported := filepath.Join(t.TempDir(), "mytool")
+	if err = binr.Export("myapp", "mytool", "v1.0.0", exported, fs); err != nil {
+		t.Fatal(err)
+	}
+	if content := mem.readFile(t, exported); content != "mytool" {
+		t.Fatalf("expected the command exported within the filesystem, got %q", content)
+	}
+
+	oldDir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache")
+	newDir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "cache")
+	if err = binr.MigrateCache(ctx, oldDir, newDir, fs); err != nil {
+		t.Fatal(err)
+	}
+	target, err := mem.Readlink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	if filepath.Dir(target) != newDir || mem.readFile(t, path) != "mytool" {
+		t.Fatalf("expected the link retargeted to the migrated cache, got %v", target)
+	}
+
+	if entries, err := os.ReadDir(os.Getenv("XDG_CONFIG_HOME")); err != nil || len(entries) != 0 {
+		t.Fatalf("expected nothing written to disk, got %v (%v)", entries, err)
+	}
+	if _, err = os.Stat(exported); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected nothing exported to disk, got %v", err)
+	}
+}
+
+// syncingFS is a Filesystem which records the names of files synced.
+type syncingFS struct {
+	binr.Filesystem
+	synced []string
+}
+
+type syncingFile struct {
+	binr.File
+	name string
+	fs   *syncingFS
+}
+
+func (f syncingFile) Sync() error {
+	f.fs.synced = append(f.fs.synced, f.name)
+	return f.File.Sync()
+}
+
+func (f *syncingFS) Open(name string) (binr.File, error) {
+	file, err := f.Filesystem.Open(name)
+	return syncingFile{file, name, f}, err
+}
+
+func (f *syncingFS) OpenFile(name string, flag int, perm os.FileMode) (binr.File, error) {
+	file, err := f.Filesystem.OpenFile(name, flag, perm)
+	return syncingFile{file, name, f}, err
+}
+
+// TestGet_Fsync ensures that WithFsync syncs the download before it is
+// moved into the cache, and the cache after.
+func TestGet_Fsync(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+
+	fs := &syncingFS{Filesystem: binr.OSFilesystem()}
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithFilesystem(fs)); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.synced) > 0 {
+		t.Fatalf("expected no syncs by default, got %v", fs.synced)
+	}
+
+	if _, err := binr.Get(ctx, "otherapp", "testbin", "v1.0.0", source,
+		binr.WithFilesystem(fs), binr.WithCacheDir(t.TempDir()), binr.WithFsync()); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.synced) != 2 || !strings.HasSuffix(fs.synced[0], ".partial") {
+		t.Fatalf("expected the download and then the cache to be synced, got %v", fs.synced)
+	}
+}
+
+// TestTemplateSource ensures a Source can be declared by URL templates, and
+// that invalid templates are rejected when constructed.
+func TestTemplateSource(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	sums := serveFiles(t, map[string][]byte{
+		"/1.0.0/SHA256SUMS": []byte(testbinChecksum(t) + "  testbin\n"),
+	})
+
+	source, err := binr.TemplateSource(
+		"http://"+serverAddress+"/{{.Version}}/{{.OS}}/{{.Arch}}/testbin",
+		"http://"+sums+`/{{trimPrefix .Version "v"}}/SHA256SUMS`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, sum, err := source("v1.0.0", "linux", "arm64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "http://"+serverAddress+"/v1.0.0/linux/arm64/testbin" || sum != "http://"+sums+"/1.0.0/SHA256SUMS" {
+		t.Fatalf("unexpected expansion %v %v", url, sum)
+	}
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = binr.TemplateSource("http://example.com/{{.Version", ""); err == nil {
+		t.Fatal("expected an error parsing an invalid template")
+	}
+	if source, err = binr.TemplateSource("http://example.com/{{.Missing}}", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = source("v1.0.0", "linux", "amd64"); err == nil {
+		t.Fatal("expected an error expanding an unknown field")
+	}
+}
+
+// TestGet_URLRewriter ensures the requests for both a command and its
+// checksum are made to the rewritten URLs.
+func TestGet_URLRewriter(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	sums := serveFiles(t, map[string][]byte{"/SHA256SUMS": []byte(testbinChecksum(t) + "  testbin\n")})
+
+	var (
+		mu      sync.Mutex
+		proxied []string
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("url")
+		mu.Lock()
+		proxied = append(proxied, target)
+		mu.Unlock()
+		res, err := http.Get(target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer res.Body.Close()
+		w.Header().Set("Content-Type", res.Header.Get("Content-Type"))
+		w.WriteHeader(res.StatusCode)
+		_, _ = io.Copy(w, res.Body)
+	}))
+	t.Cleanup(proxy.Close)
+
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "http://" + sums + "/SHA256SUMS", nil
+	}
+	rewriter := binr.WithURLRewriter(func(u string) string {
+		return proxy.URL + "/proxy?url=" + url.QueryEscape(u)
+	})
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, rewriter); err != nil {
+		t.Fatal(err)
+	}
+	if len(proxied) != 2 || !strings.HasSuffix(proxied[0], "/SHA256SUMS") || !strings.HasSuffix(proxied[1], "/testbin") {
+		t.Fatalf("expected the checksum and command to be requested via the proxy, got %v", proxied)
+	}
+}
+
+// TestGet_StartupVerify ensures a corrupt object in the cache is
+// quarantined and the command downloaded again.
+func TestGet_StartupVerify(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	path, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", testbinChecksum(t))
+	if err = os.Chmod(object, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(object, []byte("trunc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var downloads int
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithStartupVerify(),
+		binr.WithOnDownload(func(string) { downloads++ })); err != nil {
+		t.Fatal(err)
+	}
+	if downloads != 1 {
+		t.Fatalf("expected the command to be downloaded again, got %v downloads", downloads)
+	}
+	if out, err := exec.Command(path).Output(); err != nil || strings.TrimSpace(string(out)) != "OK" {
+		t.Fatalf("expected the repaired command to run, got %q %v", out, err)
+	}
+	if content, err := os.ReadFile(object + ".corrupt"); err != nil || string(content) != "trunc" {
+		t.Fatalf("expected the corrupt object to be quarantined, got %q %v", content, err)
+	}
+}
+
+// TestGet_MultihashNaming ensures objects can be named by multihash, and
+// are then recognized as cached objects.
+func TestGet_MultihashNaming(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	sum := testbinChecksum(t)
+	source := binr.InlineSource(sum, func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	})
+
+	res, err := binr.GetResult(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithMultihashNaming())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Checksum != sum {
+		t.Fatalf("expected the bare checksum reported, got %v", res.Checksum)
+	}
+	target, err := os.Readlink(res.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(target) != "1220"+sum {
+		t.Fatalf("expected the object to be named by multihash, got %v", target)
+	}
+
+	// Recognized as cached, by the name in use
+	res, err = binr.GetResult(ctx, "otherapp", "testbin", "v1.0.0", source, binr.WithMultihashNaming())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Cached {
+		t.Fatal("expected the multihash-named object to be found in the cache")
+	}
+	current, err := binr.Current("myapp", "testbin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Checksum != sum || current.Status != binr.StatusOK {
+		t.Fatalf("unexpected current installation %+v", current)
+	}
+	if report, err := binr.Doctor(); err != nil || len(report.Problems) > 0 {
+		t.Fatalf("expected no problems, got %v %v", report.Problems, err)
+	}
+}
+
+// TestGet_Digest ensures a build can be requested by git commit, which is
+// provided to the Source as-is and linked by its abbreviated digest.
+func TestGet_Digest(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	var requested string
+	source := func(vers, os, arch string) (string, string, error) {
+		requested = vers
+		return fmt.Sprintf("http://%v/v1.0.0/%v/%v/testbin", serverAddress, os, arch), "", nil
+	}
+
+	commit := "sha:0123456789ABCDEF0123456789abcdef01234567"
+	res, err := binr.GetResult(ctx, "myapp", "testbin", commit, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requested != commit {
+		t.Fatalf("expected the Source to receive %v, got %v", commit, requested)
+	}
+	if filepath.Base(res.Path) != "testbin-0123456789ab" {
+		t.Fatalf("expected the command linked by its abbreviated digest, got %v", res.Path)
+	}
+	if res.Checksum != testbinChecksum(t) {
+		t.Fatalf("expected the command cached by its checksum, got %v", res.Checksum)
+	}
+	if out, err := exec.Command(res.Path).Output(); err != nil || strings.TrimSpace(string(out)) != "OK" {
+		t.Fatalf("expected the command to run, got %q %v", out, err)
+	}
+	if _, err = os.Lstat(filepath.Join(filepath.Dir(res.Path), "testbin")); !os.IsNotExist(err) {
+		t.Fatalf("expected no unversioned link for a digest, got %v", err)
+	}
+}
+
+// noChmodFS is a Filesystem on which chmod is not permitted.
+type noChmodFS struct {
+	binr.Filesystem
+}
+
+func (noChmodFS) Chmod(name string, mode os.FileMode) error {
+	return &os.PathError{Op: "chmod", Path: name, Err: syscall.EPERM}
+}
+
+// TestGet_TolerateChmodErrors ensures a command is provided from a
+// filesystem which does not permit chmod only if permitted.
+func TestGet_TolerateChmodErrors(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	fs := binr.WithFilesystem(noChmodFS{binr.OSFilesystem()})
+
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, fs, binr.WithCacheDir(t.TempDir())); !errors.Is(err, syscall.EPERM) {
+		t.Fatalf("expected a chmod error, got %v", err)
+	}
+	path, err := binr.Get(ctx, "otherapp", "testbin", "v1.0.0", source, fs, binr.WithTolerateChmodErrors())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(path).Output(); err != nil || strings.TrimSpace(string(out)) != "OK" {
+		t.Fatalf("expected the command to run, got %q %v", out, err)
+	}
+}
+
+// TestDirectorySource ensures a command is downloaded from within a
+// release directory.
+func TestDirectorySource(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := binr.DirectorySource(func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/", serverAddress, vers, os), testbinChecksum(t), nil
+	}, runtime.GOARCH+"/testbin")
+
+	url, _, err := source("v1.0.0", "linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprintf("http://%v/v1.0.0/linux/amd64/testbin", serverAddress); url != expected {
+		t.Fatalf("expected %v, got %v", expected, url)
+	}
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGet_ErrorContext ensures that errors returned by Get identify the
+// namespace, command and version requested, while retaining the underlying
+// error.
+func TestGet_ErrorContext(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/missing/%v/%v/%v", serverAddress, vers, os, arch), "", nil
+	}
+
+	_, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source)
+	if err == nil {
+		t.Fatal("expected an error for a missing source URL")
+	}
+	if expected := "binr unable to get testbin v1.0.0 in namespace myapp. "; !strings.HasPrefix(err.Error(), expected) {
+		t.Fatalf("expected error prefixed %q, got %q", expected, err)
+	}
+	if !errors.Is(err, binr.ErrNotFound) {
+		t.Fatalf("expected the error to wrap ErrNotFound, got %v", err)
+	}
+}
+
+// TestGet_PersistPartials ensures that a download which fails part way
+// is retained and resumed by a later Get, rather than restarted.
+func TestGet_PersistPartials(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	content := bytes.Repeat([]byte("binr"), 1<<14)
+	sum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	var (
+		requests int
+		ranges   []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if requests == 1 { // interrupted half way
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	source := binr.InlineSource(sum, func(vers, os, arch string) (string, string, error) {
+		return server.URL + "/mybin", "", nil
+	})
+
+	partial := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", sum+".partial")
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithPersistPartials()); err == nil {
+		t.Fatal("expected the interrupted download to fail")
+	}
+	info, err := os.Stat(partial)
+	if err != nil {
+		t.Fatalf("expected the partial download to be retained. %v", err)
+	}
+	if info.Size() != int64(len(content)/2) {
+		t.Fatalf("expected a partial of %v bytes, got %v", len(content)/2, info.Size())
+	}
+
+	path, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithPersistPartials())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprintf("bytes=%d-", len(content)/2); ranges[1] != expected {
+		t.Fatalf("expected the download to resume with range %q, got %q", expected, ranges[1])
+	}
+	if installed, err := os.ReadFile(path); err != nil || !bytes.Equal(installed, content) {
+		t.Fatalf("expected the resumed download to be installed intact (%v)", err)
+	}
+	if _, err = os.Stat(partial); !os.IsNotExist(err) {
+		t.Fatalf("expected the partial to be removed once finalized, got %v", err)
+	}
+}
+
+// TestGet_CredentialProvider ensures credentials are obtained for each
+// request, and that a failure to obtain them aborts the request.
+func TestGet_CredentialProvider(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	content := []byte("mybin")
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Authorization"))
+		if r.URL.Path == "/mybin.sha256" {
+			fmt.Fprintf(w, "%x", sha256.Sum256(content))
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	source := func(vers, os, arch string) (string, string, error) {
+		return server.URL + "/mybin", server.URL + "/mybin.sha256", nil
+	}
+
+	var minted int
+	credentials := binr.WithCredentialProvider(func(ctx context.Context, url string) (string, string, error) {
+		minted++
+		return "Authorization", fmt.Sprintf("Bearer token-%d", minted), nil
+	})
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, credentials); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"Bearer token-1", "Bearer token-2"}; fmt.Sprint(received) != fmt.Sprint(expected) {
+		t.Fatalf("expected a fresh token for each request %v, got %v", expected, received)
+	}
+
+	errExpired := errors.New("expired")
+	failing := binr.WithCredentialProvider(func(context.Context, string) (string, string, error) {
+		return "", "", errExpired
+	})
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.1.0", source, failing); !errors.Is(err, errExpired) {
+		t.Fatalf("expected the credential provider's error, got %v", err)
+	}
+}
+
+// TestGet_CredentialProviderRedirect ensures credentials are not sent to a
+// host other than that for which they were provided when redirected.
+func TestGet_CredentialProviderRedirect(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	var received []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("X-Token"))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte("mybin"))
+	}))
+	t.Cleanup(other.Close)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("X-Token"))
+		if r.URL.Path == "/mybin" {
+			http.Redirect(w, r, "/redirected/mybin", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, other.URL+"/mybin", http.StatusFound)
+	}))
+	t.Cleanup(server.Close)
+	source := func(vers, os, arch string) (string, string, error) {
+		return server.URL + "/mybin", "", nil
+	}
+
+	credentials := binr.WithCredentialProvider(func(context.Context, string) (string, string, error) {
+		return "X-Token", "secret", nil
+	})
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, credentials); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"secret", "secret", ""}; fmt.Sprint(received) != fmt.Sprint(expected) {
+		t.Fatalf("expected credentials only for the original host %q, got %q", expected, received)
+	}
+}
+
+// TestGet_AssertVersionMatch ensures a command is only installed if the
+// version it reports matches that requested.
+func TestGet_AssertVersionMatch(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := binr.InlineSource(testbinChecksum(t), func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	})
+	reporting := func(version string) func(string) (string, error) {
+		return func(output string) (string, error) {
+			if output != "OK\n" {
+				return "", fmt.Errorf("unexpected output %q", output)
+			}
+			return version, nil
+		}
+	}
+
+	// The "v" prefix is not significant
+	for _, reported := range []string{"v1.0.0", "1.0.0"} {
+		if _, err := binr.Get(ctx, "app-"+reported, "testbin", "v1.0.0", source, binr.WithAssertVersionMatch(reporting(reported))); err != nil {
+			t.Fatalf("%v: %v", reported, err)
+		}
+	}
+
+	path, err := binr.Get(ctx, "mislabeled", "testbin", "v1.0.0", source, binr.WithAssertVersionMatch(reporting("1.2.0")))
+	if err == nil || !strings.Contains(err.Error(), "mislabeled") {
+		t.Fatalf("expected a version mismatch, got %v", err)
+	}
+	if _, err = os.Lstat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no link for a mislabeled command, got %v", err)
+	}
+}
+
+// TestGetPlatforms ensures a command is provisioned and linked for each of
+// several platforms, with commands shared by platforms cached once.
+func TestGetPlatforms(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	address := serveFiles(t, map[string][]byte{
+		"/v1.0.0/linux/amd64/mybin":   []byte("linux"),
+		"/v1.0.0/darwin/arm64/mybin":  []byte("darwin"),
+		"/v1.0.0/freebsd/amd64/mybin": []byte("linux"), // shared
+	})
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/mybin", address, vers, os, arch), "", nil
+	}
+	platforms := []binr.Platform{{"linux", "amd64"}, {"darwin", "arm64"}, {"freebsd", "amd64"}}
+
+	paths, err := binr.GetPlatforms(ctx, "myapp", "mybin", "v1.0.0", platforms, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range platforms {
+		if expected := "mybin-" + p.OS + "-" + p.Arch + "-v1.0.0"; filepath.Base(paths[p]) != expected {
+			t.Fatalf("expected %v linked as %v, got %v", p, expected, paths[p])
+		}
+	}
+	if content, err := os.ReadFile(paths[platforms[1]]); err != nil || string(content) != "darwin" {
+		t.Fatalf("expected the darwin command, got %q (%v)", content, err)
+	}
+	entries, err := os.ReadDir(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected the shared command to be cached once, got %v objects", len(entries))
+	}
+
+	// A platform which is not published
+	if _, err = binr.GetPlatforms(ctx, "myapp", "mybin", "v1.0.0", []binr.Platform{{"plan9", "386"}}, source); !errors.Is(err, binr.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestCheckSource ensures each platform's command and checksum URLs are
+// checked, and those which are unreachable reported.
+func TestCheckSource(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	address := serveFiles(t, map[string][]byte{
+		"/v1.0.0/linux/amd64/mybin":         []byte("linux"),
+		"/v1.0.0/linux/amd64/mybin.sha256":  []byte("sum"),
+		"/v1.0.0/darwin/arm64/mybin":        []byte("darwin"),
+		"/v1.0.0/darwin/arm64/mybin.sha256": []byte("sum"),
+		"/v1.0.0/windows/amd64/mybin":       []byte("windows"), // no checksum
+	})
+	source := func(vers, os, arch string) (string, string, error) {
+		url := fmt.Sprintf("http://%v/%v/%v/%v/mybin", address, vers, os, arch)
+		return url, url + ".sha256", nil
+	}
+	platforms := []binr.Platform{{"linux", "amd64"}, {"darwin", "arm64"}, {"windows", "amd64"}, {"plan9", "386"}}
+
+	results, err := binr.CheckSource(ctx, "v1.0.0", source, platforms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(platforms) {
+		t.Fatalf("expected %v results, got %v", len(platforms), len(results))
+	}
+	for i, r := range results[:2] {
+		if !r.OK() || r.Platform != platforms[i] || r.Status != http.StatusOK || r.ChecksumStatus != http.StatusOK {
+			t.Fatalf("expected %v to be reachable, got %+v", platforms[i], r)
+		}
+	}
+	if r := results[2]; r.OK() || r.Status != http.StatusOK || r.ChecksumStatus != http.StatusNotFound || !errors.Is(r.Err, binr.ErrNotFound) {
+		t.Fatalf("expected the missing checksum reported, got %+v", r)
+	}
+	if r := results[3]; r.OK() || r.Status != http.StatusNotFound || r.ChecksumStatus != 0 {
+		t.Fatalf("expected the missing command reported, got %+v", r)
+	}
+}
+
+// TestPrune ensures that objects no longer linked are pruned, and that
+// Prune waits for an install in progress rather than racing it.
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := binr.InlineSource(testbinChecksum(t), func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	})
+	cache := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache")
+
+	// An object whose command was removed is pruned, as is an abandoned
+	// partial, but not one retained to be resumed.
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := binr.RemoveCommand("myapp", "testbin"); err != nil {
+		t.Fatal(err)
+	}
+	retained := filepath.Join(cache, strings.Repeat("a", 64)+".partial")
+	for _, partial := range []string{retained, filepath.Join(cache, "abandoned.partial")} {
+		if err := os.WriteFile(partial, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, err := binr.Prune(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 {
+		t.Fatalf("expected the object and abandoned partial pruned, got %v", removed)
+	}
+	if _, err = os.Stat(retained); err != nil {
+		t.Fatalf("expected a partial retained to be resumed to be kept. %v", err)
+	}
+
+	// Prune waits for an install in progress
+	var (
+		release  = make(chan struct{})
+		started  = make(chan struct{})
+		content  = []byte("mybin")
+		sum      = fmt.Sprintf("%x", sha256.Sum256(content))
+		blocking = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(content)
+		}))
+	)
+	t.Cleanup(blocking.Close)
+	installed := make(chan error)
+	go func() {
+		_, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", binr.InlineSource(sum, func(vers, os, arch string) (string, string, error) {
+			return blocking.URL + "/mybin", "", nil
+		}))
+		installed <- err
+	}()
+	<-started
+
+	pruned := make(chan error)
+	go func() {
+		_, err := binr.Prune(ctx)
+		pruned <- err
+	}()
+	select {
+	case err := <-pruned:
+		t.Fatalf("expected Prune to wait for the install in progress, got %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+	close(release)
+	if err = <-installed; err != nil {
+		t.Fatal(err)
+	}
+	if err = <-pruned; err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(cache, sum)); err != nil {
+		t.Fatalf("expected the object installed during Prune to be kept. %v", err)
+	}
+}
+
+// readOnlyFS is a Filesystem which can not be written, such as a cache
+// baked into an image.
+type readOnlyFS struct {
+	binr.Filesystem
+}
+
+func (f readOnlyFS) OpenFile(name string, flag int, perm os.FileMode) (binr.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EROFS}
+	}
+	return f.Filesystem.OpenFile(name, flag, perm)
+}
+
+func (readOnlyFS) MkdirAll(path string, perm os.FileMode) error {
+	return &os.PathError{Op: "mkdir", Path: path, Err: syscall.EROFS}
+}
+
+func (readOnlyFS) Rename(oldpath, newpath string) error {
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EROFS}
+}
+
+func (readOnlyFS) Remove(name string) error {
+	return &os.PathError{Op: "remove", Path: name, Err: syscall.EROFS}
+}
+
+func (readOnlyFS) Symlink(oldname, newname string) error {
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: syscall.EROFS}
+}
+
+func (readOnlyFS) Chtimes(name string, atime, mtime time.Time) error {
+	return &os.PathError{Op: "chtimes", Path: name, Err: syscall.EROFS}
+}
+
+func (readOnlyFS) Chmod(name string, mode os.FileMode) error {
+	return &os.PathError{Op: "chmod", Path: name, Err: syscall.EROFS}
+}
+
+// TestGet_ReadOnlyFilesystem ensures commands already installed are
+// provided from a cache which is not writable, which can not be locked.
+func TestGet_ReadOnlyFilesystem(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := binr.InlineSource(testbinChecksum(t), func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	})
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source); err != nil {
+		t.Fatal(err)
+	}
+
+	readOnly := binr.WithFilesystem(readOnlyFS{binr.OSFilesystem()})
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, readOnly); err != nil {
+		t.Fatal(err)
+	}
+	r, _, err := binr.GetReader(ctx, "v1.0.0", source, readOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+}
+
+// userAgentTransport records the User-Agent of each request made.
+type userAgentTransport struct {
+	agents []string
+}
+
+func (t *userAgentTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.agents = append(t.agents, r.Header.Get("User-Agent"))
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// TestGetWithOptions ensures that options provided as a struct are applied.
+func TestGetWithOptions(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	transport := &userAgentTransport{}
+	opts := binr.Options{
+		CacheDir:   t.TempDir(),
+		UserAgent:  "mytool/1.0",
+		HTTPClient: &http.Client{Transport: transport},
+	}
+	_, err := binr.GetWithOptions(ctx, "myapp", "testbin", "v1.0.0", func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(transport.agents) != "[mytool/1.0]" {
+		t.Fatalf("expected one request by the client with the user agent, got %v", transport.agents)
+	}
+	if _, err = os.Stat(filepath.Join(opts.CacheDir, testbinChecksum(t))); err != nil {
+		t.Fatalf("expected the command cached in the cache directory. %v", err)
+	}
+}
+
+// TestGet_DiagnosticPrefix ensures that the errors of downloads which are
+// not a command include the start of what was received, such that an
+// error page served in place of the command is evident.
+func TestGet_DiagnosticPrefix(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	page := "<!DOCTYPE html><html><body>Rate limit exceeded</body></html>"
+	for _, contentType := range []string{"text/html", "application/octet-stream"} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			fmt.Fprint(w, page)
+		}))
+		source := binr.InlineSource(testbinChecksum(t), func(vers, os, arch string) (string, string, error) {
+			return server.URL + "/testbin", "", nil
+		})
+		_, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source)
+		server.Close()
+		if err == nil || !strings.Contains(err.Error(), "<!DOCTYPE html><html>") {
+			t.Fatalf("%v: expected the error to include the start of the response, got %v", contentType, err)
+		}
+	}
+}
+
+// TestGet_ReplacesUnversioned ensures that installing a newer version
+// replaces the existing unversioned link rather than failing.
+func TestGet_ReplacesUnversioned(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	address := serveFiles(t, map[string][]byte{
+		"/v1.0.0/mybin": []byte("v1.0.0"),
+		"/v1.1.0/mybin": []byte("v1.1.0"),
+	})
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mybin", address, vers), "", nil
+	}
+	for _, version := range []string{"v1.0.0", "v1.1.0"} {
+		if _, err := binr.Get(ctx, "myapp", "mybin", version, source); err != nil {
+			t.Fatalf("%v: %v", version, err)
+		}
+	}
+	unversioned, err := binr.Path("myapp", "mybin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(unversioned); err != nil || string(content) != "v1.1.0" {
+		t.Fatalf("expected the unversioned link to target v1.1.0, got %q (%v)", content, err)
+	}
+}
+
+// TestPrefetchManifest ensures the commands of a manifest are fetched into
+// the cache, and that the errors of those which fail are reported together.
+func TestPrefetchManifest(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	manifest := filepath.Join(t.TempDir(), "tools.json")
+	content := fmt.Sprintf(`{"commands": [
+		{"command": "testbin", "version": "v1.0.0", "url": "http://%[1]v/{{.Version}}/{{.OS}}/{{.Arch}}/testbin"},
+		{"command": "missing", "version": "v1.0.0", "url": "http://%[1]v/{{.Version}}/{{.OS}}/{{.Arch}}/missing"}
+	]}`, serverAddress)
+	if err := os.WriteFile(manifest, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := binr.PrefetchManifest(ctx, manifest)
+	if !errors.Is(err, binr.ErrNotFound) || !strings.Contains(err.Error(), "missing v1.0.0") {
+		t.Fatalf("expected the missing command to be reported, got %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", testbinChecksum(t))); err != nil {
+		t.Fatalf("expected testbin to be prefetched. %v", err)
+	}
+	if _, err = binr.Link("myapp", "testbin", "v1.0.0", testbinChecksum(t)); err != nil {
+		t.Fatalf("expected the prefetched command to be linkable. %v", err)
+	}
+}
+
+// TestGet_CertificatePin ensures that only a host presenting the pinned
+// certificate is trusted, despite its chain being trusted.
+func TestGet_CertificatePin(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	content := []byte("mybin")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	source := binr.InlineSource(fmt.Sprintf("%x", sha256.Sum256(content)), func(vers, os, arch string) (string, string, error) {
+		return server.URL + "/mybin", "", nil
+	})
+	client := binr.WithHTTPClient(server.Client())
+
+	_, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, client, binr.WithCertificatePin(strings.Repeat("0", 64)))
+	if !errors.Is(err, binr.ErrCertificatePin) {
+		t.Fatalf("expected ErrCertificatePin, got %v", err)
+	}
+
+	cert := server.Certificate()
+	certPin, keyPin := sha256.Sum256(cert.Raw), sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	for i, pin := range [][32]byte{certPin, keyPin} {
+		namespace, cache := fmt.Sprintf("myapp%v", i), binr.WithCacheDir(t.TempDir()) // downloaded by each
+		if _, err = binr.Get(ctx, namespace, "mybin", "v1.0.0", source, client, cache, binr.WithCertificatePin(fmt.Sprintf("%x", pin))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// xorReader decodes content obfuscated by an XOR with a single byte key.
+type xorReader struct {
+	r   io.Reader
+	key byte
+}
+
+func (x xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := range p[:n] {
+		p[i] ^= x.key
+	}
+	return n, err
+}
+
+// TestGet_BodyWrapper ensures the content written and verified is that
+// read from the body wrapper, and that a failing wrapper leaves no partial.
+func TestGet_BodyWrapper(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	content := []byte("mybin")
+	encoded, _ := io.ReadAll(xorReader{bytes.NewReader(content), 0x5a})
+	source := binr.InlineSource(fmt.Sprintf("%x", sha256.Sum256(content)), func(vers, os, arch string) (string, string, error) {
+		return "http://" + serveContent(t, encoded) + "/mybin", "", nil
+	})
+
+	errWrapper := errors.New("no key")
+	_, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithBodyWrapper(func(io.Reader) (io.Reader, error) {
+		return nil, errWrapper
+	}))
+	if !errors.Is(err, errWrapper) {
+		t.Fatalf("expected the wrapper's error, got %v", err)
+	}
+	partials, _ := filepath.Glob(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", "*.partial"))
+	if len(partials) > 0 {
+		t.Fatalf("expected no partial downloads, found %v", partials)
+	}
+
+	path, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithBodyWrapper(func(r io.Reader) (io.Reader, error) {
+		return xorReader{r, 0x5a}, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if installed, err := os.ReadFile(path); err != nil || !bytes.Equal(installed, content) {
+		t.Fatalf("expected the decoded command, got %q (%v)", installed, err)
+	}
+}
+
+// TestGet_ResponseValidator ensures that a JSON error served in place of a
+// command is reported clearly, and that responses can be validated.
+func TestGet_ResponseValidator(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/json" {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			fmt.Fprint(w, `{"error": "artifact expired"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("X-Artifact-Status", "expired")
+	}))
+	t.Cleanup(server.Close)
+	source := func(path string) binr.Source {
+		return func(vers, os, arch string) (string, string, error) { return server.URL + path, "", nil }
+	}
+
+	_, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source("/json"))
+	if err == nil || !strings.Contains(err.Error(), "received JSON") || !strings.Contains(err.Error(), "artifact expired") {
+		t.Fatalf("expected an error reporting the JSON received, got %v", err)
+	}
+
+	errExpired := errors.New("expired")
+	validator := binr.WithResponseValidator(func(res *http.Response) error {
+		if res.Header.Get("X-Artifact-Status") == "expired" {
+			return errExpired
+		}
+		return nil
+	})
+	if _, err = binr.Get(ctx, "myapp", "mybin", "v1.0.0", source("/mybin"), validator); !errors.Is(err, errExpired) {
+		t.Fatalf("expected the validator's error, got %v", err)
+	}
+}
+
+// TestGet_ConcurrentFirstInstall ensures that many concurrent installs into
+// a fresh cache and new namespaces do not fail creating their directories
+// or links.
+func TestGet_ConcurrentFirstInstall(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := binr.InlineSource(testbinChecksum(t), func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/v1.0.0/%v/%v/testbin", serverAddress, os, arch), "", nil
+	})
+
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, 32)
+	)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			namespace, command := fmt.Sprintf("ns%v", i%2), fmt.Sprintf("cmd%v", i%8)
+			if _, err := binr.Get(ctx, namespace, command, "v1.0.0", source); err != nil {
+				errs <- fmt.Errorf("%v/%v: %w", namespace, command, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestGet_MinFreeDisk ensures a download which would leave too little free
+// disk is refused before it begins, and one which would not proceeds.
+func TestGet_MinFreeDisk(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	address := serveFiles(t, map[string][]byte{"/mybin": []byte("mybin")}) // of known length
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/mybin", address), "", nil
+	}
+	cacheDir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache")
+
+	_, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithMinFreeDisk(1<<62))
+	switch runtime.GOOS {
+	case "linux", "darwin", "freebsd", "dragonfly", "windows":
+	default:
+		t.Skip("free disk is not determined on this system")
+	}
+	if !errors.Is(err, binr.ErrInsufficientDisk) {
+		t.Fatalf("expected ErrInsufficientDisk, got %v", err)
+	}
+	if partials, _ := filepath.Glob(filepath.Join(cacheDir, "*.partial")); len(partials) != 0 {
+		t.Fatalf("expected no partial download, got %v", partials)
+	}
+
+	if _, err = binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithMinFreeDisk(1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGet_VerifyWindow ensures a cached command is verified on each Get
+// unless verified within the window and unmodified since.
+func TestGet_VerifyWindow(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	window := binr.WithVerifyWindow(time.Hour)
+
+	res, err := binr.GetResult(ctx, "myapp", "testbin", "v1.0.0", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", res.Checksum)
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithVerifyCached(), window); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(object + ".verified"); err != nil {
+		t.Fatalf("expected the verification recorded. %v", err)
+	}
+
+	// Corrupted without modifying its mtime, the recent verification is
+	// trusted, but not without the window.
+	info, err := os.Stat(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chmod(object, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(object, []byte("corrupted"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chtimes(object, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithVerifyCached(), window); err != nil {
+		t.Fatalf("expected the recent verification to be trusted, got %v", err)
+	}
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithVerifyCached()); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch without the window, got %v", err)
+	}
+
+	// Modified since, or verified before the window, it is verified again.
+	if err = os.Chtimes(object, time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithVerifyCached(), window); err == nil {
+		t.Fatal("expected a modified command to be verified again")
+	}
+	if err = os.Chtimes(object, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	later := binr.WithClock(func() time.Time { return time.Now().Add(2 * time.Hour) })
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithVerifyCached(), window, later); err == nil {
+		t.Fatal("expected a command verified before the window to be verified again")
+	}
+}
+
+// fnvHasher is a non-cryptographic Hasher.
+type fnvHasher struct{}
+
+func (fnvHasher) Algorithm() string { return "fnv64a" }
+
+func (fnvHasher) New() hash.Hash { return fnv.New64a() }
+
+// TestGet_Hasher ensures a command without a published sha256 is addressed
+// by the checksum of the Hasher provided, and that one with a published
+// sha256 is addressed by it regardless.
+func TestGet_Hasher(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	hasher := binr.WithHasher(fnvHasher{})
+
+	res, err := binr.GetResult(ctx, "myapp", "testbin", "v1.0.0", source, hasher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(res.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := fnv.New64a()
+	h.Write(content)
+	if expected := fmt.Sprintf("fnv64a-%x", h.Sum(nil)); res.Checksum != expected {
+		t.Fatalf("expected checksum %v, got %v", expected, res.Checksum)
+	}
+	installed, err := binr.List("myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(installed) == 0 || installed[0].Status != binr.StatusOK || installed[0].Checksum != res.Checksum {
+		t.Fatalf("expected the command listed with its checksum, got %+v", installed)
+	}
+
+	// Verified with the hasher, and not without it
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, hasher, binr.WithVerifyCached()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithVerifyCached()); err == nil {
+		t.Fatal("expected the command can not be verified without its hasher")
+	}
+
+	// A published sha256 addresses the command
+	published := binr.InlineSource(testbinChecksum(t), source)
+	if res, err = binr.GetResult(ctx, "otherapp", "testbin", "v1.0.0", published, hasher); err != nil {
+		t.Fatal(err)
+	}
+	if res.Checksum != testbinChecksum(t) {
+		t.Fatalf("expected the published checksum %v, got %v", testbinChecksum(t), res.Checksum)
+	}
+}
+
+// TestGet_SharedCache ensures a cache populated by one system is used by
+// another, such as a network cache mounted by build agents of different
+// OSes, including when the mounting system does not preserve its mode.
+func TestGet_SharedCache(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	content := []byte("mybin")
+	sum := fmt.Sprintf("%x", sha256.Sum256(content))
+	address := serveFiles(t, map[string][]byte{"/linux/amd64/mybin": content})
+	source := binr.InlineSource(sum, func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/mybin", address, os, arch), "", nil
+	})
+	cache := binr.WithCacheDir(filepath.Join(t.TempDir(), "shared"))
+
+	// Populated by linux, whose object is then seen without its mode
+	res, err := binr.GetResult(ctx, "myapp", "mybin", "v1.0.0", source, cache, binr.WithPlatform("linux", "amd64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := filepath.EvalSymlinks(res.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(target) != sum {
+		t.Fatalf("expected the object named by its checksum alone, got %v", filepath.Base(target))
+	}
+	if err = os.Chmod(target, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Consumed by darwin, on another system, without a download (the source
+	// has none for darwin).
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	res, err = binr.GetResult(ctx, "myapp", "mybin", "v1.0.0", source, cache, binr.WithPlatform("darwin", "arm64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Cached || res.Checksum != sum {
+		t.Fatalf("expected the shared object provided, got %+v", res)
+	}
+	if linked, err := filepath.EvalSymlinks(res.Path); err != nil || linked != target {
+		t.Fatalf("expected %v linked to the shared object %v, got %v (%v)", res.Path, target, linked, err)
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0555 {
+		t.Fatalf("expected the shared object's mode restored, got %v (%v)", info.Mode(), err)
+	}
+}
+
+// TestGet_OnNewObject ensures the function is invoked once per command
+// added to the cache, regardless of the namespaces linking it, and that an
+// error rejects the command.
+func TestGet_OnNewObject(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	var objects []string
+	onNewObject := binr.WithOnNewObject(func(checksum, path string) error {
+		if filepath.Base(path) != checksum {
+			t.Fatalf("expected the path of object %v, got %v", checksum, path)
+		}
+		objects = append(objects, checksum)
+		return nil
+	})
+
+	// Each namespace downloads the command, as no checksum is published,
+	// but only the first adds it to the cache.
+	for _, namespace := range []string{"app1", "app2", "app1"} {
+		if _, err := binr.Get(ctx, namespace, "testbin", "v1.0.0", source, onNewObject); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(objects) != 1 || objects[0] != testbinChecksum(t) {
+		t.Fatalf("expected one new object %v, got %v", testbinChecksum(t), objects)
+	}
+
+	// Rejected
+	content := []byte("rejected")
+	address := serveContent(t, content)
+	rejected := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/rejected", address), "", nil
+	}
+	_, err := binr.Get(ctx, "app1", "rejected", "v1.0.0", rejected, binr.WithOnNewObject(func(checksum, path string) error {
+		return errors.New("license not permitted")
+	}))
+	if err == nil || !strings.Contains(err.Error(), "license not permitted") {
+		t.Fatalf("expected the rejection, got %v", err)
+	}
+	object := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", fmt.Sprintf("%x", sha256.Sum256(content)))
+	if _, err = os.Stat(object); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the rejected object removed, got %v", err)
+	}
+}
+
+// TestGet_ParallelChecksum ensures the checksum is fetched while the command
+// is downloaded, such that a slow checksum endpoint does not add to the
+// latency of the download, and that the download is cancelled if the
+// checksum can not be fetched.
+func TestGet_ParallelChecksum(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	const delay = 300 * time.Millisecond
+	content := []byte("mybin")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/mybin":
+			time.Sleep(delay)
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(content)
+		case "/mybin.sha256":
+			time.Sleep(delay)
+			fmt.Fprintf(w, "%x\n", sha256.Sum256(content))
+		case "/hanging":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(10 * time.Second):
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	source := func(path, sum string) binr.Source {
+		return func(vers, os, arch string) (string, string, error) {
+			return server.URL + path, server.URL + sum, nil
+		}
+	}
+
+	// Sequentially, the latencies are summed, and in parallel overlap.
+	for _, parallel := range []bool{false, true} {
+		get := func() (binr.Result, error) {
+			if parallel {
+				return binr.GetResult(ctx, "parallel", "mybin", "v1.0.0", source("/mybin", "/mybin.sha256"), binr.WithParallelChecksum())
+			}
+			return binr.GetResult(ctx, "sequential", "mybin", "v1.0.0", source("/mybin", "/mybin.sha256"), binr.WithCacheDir(t.TempDir()))
+		}
+		start := time.Now()
+		res, err := get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		elapsed := time.Since(start)
+		if res.Checksum != fmt.Sprintf("%x", sha256.Sum256(content)) {
+			t.Fatalf("expected the verified command, got %v", res.Checksum)
+		}
+		if !parallel && elapsed < 2*delay {
+			t.Fatalf("expected a sequential fetch to take at least %v, took %v", 2*delay, elapsed)
+		} else if parallel && elapsed >= 2*delay {
+			t.Fatalf("expected a parallel fetch to take less than %v, took %v", 2*delay, elapsed)
+		}
+		t.Logf("parallel %v: %v", parallel, elapsed)
+	}
+
+	// A checksum which is not found cancels the download.
+	start := time.Now()
+	_, err := binr.Get(ctx, "appmissing", "mybin", "v1.0.0", source("/hanging", "/missing.sha256"), binr.WithParallelChecksum())
+	if !errors.Is(err, binr.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for the checksum, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("expected the download to be cancelled")
+	}
+}
+
+// TestGet_Mirrors ensures a command is downloaded from a mirror when its
+// source's URL fails, and that adaptive ordering prefers the faster mirror.
+func TestGet_Mirrors(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	files := map[string][]byte{}
+	for _, version := range []string{"v1.0.0", "v2.0.0", "v3.0.0"} {
+		files["/releases/"+version+"/mybin"] = []byte(version)
+	}
+	source := serveFiles(t, nil)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(files[r.URL.Path])
+	}))
+	t.Cleanup(slow.Close)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(files[r.URL.Path])
+	}))
+	t.Cleanup(fast.Close)
+	src := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mybin", source, vers), "", nil
+	}
+
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", src); err == nil {
+		t.Fatal("expected an error without mirrors")
+	}
+	resolved := func(version string) string {
+		t.Helper()
+		res, err := binr.GetResult(ctx, "myapp", "mybin", version, src,
+			binr.WithMirrors(slow.URL+"/releases", fast.URL+"/releases"),
+			binr.WithAdaptiveMirrors())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.ResolvedURL
+	}
+	if got := resolved("v1.0.0"); !strings.HasPrefix(got, slow.URL) {
+		t.Fatalf("expected the first download from the first declared mirror, got %v", got)
+	}
+	resolved("v2.0.0") // measures the fast mirror
+	if got := resolved("v3.0.0"); !strings.HasPrefix(got, fast.URL) {
+		t.Fatalf("expected the download from the faster mirror, got %v", got)
+	}
+}
+
+// TestGet_MirrorsLatency ensures adaptive ordering scores a mirror by the
+// latency of its response rather than the time taken to download it, such
+// that a mirror which responds promptly is preferred even if the command
+// takes longer to transfer.
+func TestGet_MirrorsLatency(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	source := serveFiles(t, nil)
+	transferring := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte("my"))
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		_, _ = w.Write([]byte(path.Base(path.Dir(r.URL.Path))))
+	}))
+	t.Cleanup(transferring.Close)
+	responding := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte("my" + path.Base(path.Dir(r.URL.Path))))
+	}))
+	t.Cleanup(responding.Close)
+	src := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mybin", source, vers), "", nil
+	}
+
+	resolved := func(version string) string {
+		t.Helper()
+		res, err := binr.GetResult(ctx, "myapp", "mybin", version, src,
+			binr.WithMirrors(transferring.URL, responding.URL),
+			binr.WithAdaptiveMirrors())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.ResolvedURL
+	}
+	resolved("v1.0.0") // measures the mirror which is slow to transfer
+	resolved("v2.0.0") // measures the mirror which is slow to respond
+	if got := resolved("v3.0.0"); !strings.HasPrefix(got, transferring.URL) {
+		t.Fatalf("expected the download from the mirror which responds sooner, got %v", got)
+	}
+}
+
+// TestGet_InvalidURL ensures a Source returning a URL which is not absolute
+// http(s) results in ErrInvalidURL naming it.
+func TestGet_InvalidURL(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	for _, test := range []struct{ url, sum string }{
+		{"example.com/mybin", ""},
+		{"//example.com/mybin", ""},
+		{"/mybin", ""},
+		{"ftp://example.com/mybin", ""},
+		{"https://example.com/mybin", "example.com/mybin.sha256"},
+	} {
+		_, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", func(vers, os, arch string) (string, string, error) {
+			return test.url, test.sum, nil
+		})
+		if !errors.Is(err, binr.ErrInvalidURL) {
+			t.Fatalf("expected ErrInvalidURL for %q %q, got %v", test.url, test.sum, err)
+		}
+		invalid := test.url
+		if test.sum != "" {
+			invalid = test.sum
+		}
+		if !strings.Contains(err.Error(), invalid) {
+			t.Fatalf("expected the error to name the invalid URL, got %v", err)
+		}
+	}
+}
+
+// TestGet_MetadataFetch ensures release metadata is provided to the
+// function, that a failure to fetch it is a warning unless required, and
+// that its rejection aborts the install.
+func TestGet_MetadataFetch(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	address := serveFiles(t, map[string][]byte{
+		"/v1.0.0/mybin":        []byte("v1.0.0"),
+		"/v1.0.0/RELEASE.json": []byte(`{"notes":"v1.0.0"}`),
+		"/v2.0.0/mybin":        []byte("v2.0.0"),
+	})
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mybin", address, vers), "", nil
+	}
+	metadataURL := func(vers, os, arch string) string {
+		return fmt.Sprintf("http://%v/%v/RELEASE.json", address, vers)
+	}
+	var received string
+	dest := func(meta []byte) error {
+		received = string(meta)
+		return nil
+	}
+
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithMetadataFetch(metadataURL, dest)); err != nil {
+		t.Fatal(err)
+	}
+	if received != `{"notes":"v1.0.0"}` {
+		t.Fatalf("expected the release metadata, got %q", received)
+	}
+
+	// Missing metadata is a warning unless required
+	if _, err := binr.Get(ctx, "otherapp", "mybin", "v2.0.0", source,
+		binr.WithMetadataFetch(metadataURL, dest), binr.WithMetadataRequired()); !errors.Is(err, binr.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound when the metadata is required, got %v", err)
+	}
+	res, err := binr.GetResult(ctx, "myapp", "mybin", "v2.0.0", source, binr.WithMetadataFetch(metadataURL, dest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "metadata") {
+		t.Fatalf("expected a warning of the missing metadata, got %v", res.Warnings)
+	}
+
+	// Rejected metadata aborts the install
+	reject := func([]byte) error { return errors.New("unsupported release") }
+	if _, err = binr.Get(ctx, "thirdapp", "mybin", "v1.0.0", source, binr.WithMetadataFetch(metadataURL, reject)); err == nil || !strings.Contains(err.Error(), "unsupported release") {
+		t.Fatalf("expected the metadata to be rejected, got %v", err)
+	}
+	path, _ := binr.Path("thirdapp", "mybin", "v1.0.0")
+	if _, err = os.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected no link when the metadata is rejected")
+	}
+}
+
+// TestGet_InferredChecksum ensures the algorithm of a hex-encoded checksum
+// is inferred from its length, and that one of no known length is an error.
+func TestGet_InferredChecksum(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	content := []byte("mybin")
+	md5Sum, sha1Sum, sha512Sum := md5.Sum(content), sha1.Sum(content), sha512.Sum512(content)
+	address := serveFiles(t, map[string][]byte{
+		"/v1.0.0/mybin":     content,
+		"/v1.0.0/mybin.md5": []byte(fmt.Sprintf("%x", md5Sum) + "  mybin\n"),
+	})
+	sourceURL := fmt.Sprintf("http://%v/v1.0.0/mybin", address)
+
+	for i, sum := range []string{
+		fmt.Sprintf("%x", md5Sum),
+		fmt.Sprintf("%x", sha1Sum),
+		fmt.Sprintf("%x", sha512Sum),
+		sourceURL + ".md5",
+	} {
+		namespace := fmt.Sprintf("myapp%v", i)
+		if _, err := binr.Get(ctx, namespace, "mybin", "v1.0.0", func(vers, os, arch string) (string, string, error) {
+			return sourceURL, sum, nil
+		}); err != nil {
+			t.Fatalf("checksum %v: %v", sum, err)
+		}
+	}
+
+	// A corrupt download is detected by the inferred algorithm
+	wrong := md5.Sum([]byte("other"))
+	if _, err := binr.Get(ctx, "otherapp", "mybin", "v1.0.0", func(vers, os, arch string) (string, string, error) {
+		return sourceURL, fmt.Sprintf("%x", wrong), nil
+	}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+
+	// A checksum of no known length is an error
+	if _, err := binr.Get(ctx, "otherapp", "mybin", "v1.0.0", func(vers, os, arch string) (string, string, error) {
+		return sourceURL, strings.Repeat("ab", 15), nil
+	}); !errors.Is(err, binr.ErrChecksumFormat) {
+		t.Fatalf("expected ErrChecksumFormat, got %v", err)
+	}
+}
+
+// TestGet_ConfigRoot ensures commands are linked and cached beneath the
+// config root, rather than the working directory when there is no home.
+func TestGet_ConfigRoot(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "")
+	root := t.TempDir()
+	address := serveFiles(t, map[string][]byte{"/v1.0.0/mybin": []byte("mybin")})
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mybin", address, vers), "", nil
+	}
+
+	res, err := binr.GetResult(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithConfigRoot(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Warnings) != 0 {
+		t.Fatalf("expected no warnings, got %v", res.Warnings)
+	}
+	expected, err := binr.Path("myapp", "mybin", "v1.0.0", binr.WithConfigRoot(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path != expected || res.Path != filepath.Join(root, "myapp", "mybin-v1.0.0") {
+		t.Fatalf("expected the command linked beneath the config root, got %v", res.Path)
+	}
+	if _, err = os.Stat(filepath.Join(root, ".cache", res.Checksum)); err != nil {
+		t.Fatalf("expected the command cached beneath the config root. %v", err)
+	}
+	if _, err = os.Stat("binr"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected nothing created in the working directory")
+	}
+	if list, err := binr.List("myapp", binr.WithConfigRoot(root)); err != nil || len(list) != 2 {
+		t.Fatalf("expected both links listed, got %v (%v)", list, err)
+	}
+}
+
+// TestStream ensures content is streamed into a WriterAt and verified,
+// resuming from the offset reached when a download fails.
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		n := requests.Add(1)
+		if n == 1 { // fail part way
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			return
+		}
+		if n == 2 && r.Header.Get("Range") != fmt.Sprintf("bytes=%d-", len(content)/2) {
+			t.Errorf("expected the stream to resume from the offset reached, got range %q", r.Header.Get("Range"))
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	expected := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "stream"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	checksum, err := binr.Stream(ctx, server.URL+"/mybin", expected, file, binr.WithRetries(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != expected {
+		t.Fatalf("expected checksum %v, got %v", expected, checksum)
+	}
+	if written, _ := os.ReadFile(file.Name()); !bytes.Equal(written, content) {
+		t.Fatal("expected the content written to the writer")
+	}
+
+	// A mismatch is an error
+	wrong := fmt.Sprintf("%x", sha256.Sum256([]byte("other")))
+	if _, err = binr.Stream(ctx, server.URL+"/mybin", wrong, file); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+}
+
+// TestStream_RangeNotSatisfiable ensures a resumed stream whose range is not
+// satisfiable is taken to be complete only if the server reports the
+// content is of the length reached, and is otherwise restarted from zero.
+func TestStream_RangeNotSatisfiable(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	var (
+		stale    = bytes.Repeat([]byte("0123456789"), 1000)
+		content  = []byte("republished")
+		requests atomic.Int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if requests.Add(1) == 1 { // fail part way, before the content changes
+			w.Header().Set("Content-Length", strconv.Itoa(len(stale)))
+			_, _ = w.Write(stale[:len(stale)/2])
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	expected := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "stream"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	checksum, err := binr.Stream(ctx, server.URL+"/mybin", expected, file, binr.WithRetries(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != expected || requests.Load() != 3 {
+		t.Fatalf("expected the stream restarted from zero, got checksum %v after %v requests", checksum, requests.Load())
+	}
+	if written, _ := os.ReadFile(file.Name()); !bytes.HasPrefix(written, content) {
+		t.Fatal("expected the content written to the writer from offset zero")
+	}
+}
+
+// TestGet_VerifyTimeout ensures a download whose verification times out is
+// not installed and leaves no partial download behind.
+func TestGet_VerifyTimeout(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := binr.InlineSource(testbinChecksum(t), func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	})
+
+	_, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithVerifyTimeout(time.Nanosecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	partials, err := filepath.Glob(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", "*.partial"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(partials) > 0 {
+		t.Fatalf("expected partial downloads to be removed, found %v", partials)
+	}
+
+	// Ample time
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithVerifyTimeout(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGet_Cancelled ensures a download whose context is cancelled stops
+// promptly and leaves no partial download behind.
+func TestGet_Cancelled(t *testing.T) {
+	setupTestGet(t)
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(bytes.Repeat([]byte("x"), 1024))
+		w.(http.Flusher).Flush()
+		close(started)
+		select { // never completes the download
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	source := func(vers, os, arch string) (string, string, error) {
+		return server.URL + "/mytool", "", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err := binr.Get(ctx, "myapp", "mytool", "v1.0.0", source, binr.WithRetries(3))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancellation error, got %v", err)
+	}
+	partials, err := filepath.Glob(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", "*.partial"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(partials) > 0 {
+		t.Fatalf("expected partial downloads to be removed, found %v", partials)
+	}
+}
+
+// TestGet_PinsFile ensures a pinned command is provided at its pinned
+// version and checksum regardless of the version requested.
+func TestGet_PinsFile(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+
+	pins := filepath.Join(t.TempDir(), "binr.pins")
+	content := fmt.Sprintf("# team tools\nmyapp/testbin v1.0.0 %v\n", testbinChecksum(t))
+	if err := os.WriteFile(pins, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Strict pins refuse a conflicting version
+	_, err := binr.Get(ctx, "myapp", "testbin", "v2.0.0", source,
+		binr.WithPinsFile(pins), binr.WithStrictPins())
+	if err == nil {
+		t.Fatal("expected an error requesting a version other than that pinned")
+	}
+
+	// Otherwise the pinned version is used
+	path, err := binr.Get(ctx, "myapp", "testbin", "v2.0.0", source, binr.WithPinsFile(pins))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "testbin-v1.0.0" {
+		t.Fatalf("expected the pinned version to be installed, got %v", path)
+	}
+
+	// A pinned checksum is enforced
+	content = fmt.Sprintf("otherapp/testbin v1.0.0 %v\n", strings.Repeat("0", 64))
+	if err = os.WriteFile(pins, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = binr.Get(ctx, "otherapp", "testbin", "v1.0.0", source, binr.WithPinsFile(pins))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch for the pinned checksum, got %v", err)
+	}
+
+	// A pinned version must be exact
+	if err = os.WriteFile(pins, []byte("# team tools\nmyapp/testbin latest\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithPinsFile(pins))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected an error reporting the line of the version not exact, got %v", err)
+	}
+}
+
+// TestGet_MaxBandwidth ensures downloads are throttled to the maximum
+// bandwidth, and that a throttled download stops when the context is done.
+func TestGet_MaxBandwidth(t *testing.T) {
+	setupTestGet(t)
+	address := serveContent(t, bytes.Repeat([]byte("x"), 200))
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/mytool", address), "", nil
+	}
+
+	// 200 bytes at 100 bytes per second (bursting 100) takes about a second.
+	start := time.Now()
+	if _, err := binr.Get(context.Background(), "myapp", "mytool", "v1.0.0", source,
+		binr.WithMaxBandwidth(100)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Fatalf("expected the download to be throttled, took %v", elapsed)
+	}
+
+	// A very low limit does not prevent cancellation.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err := binr.Get(ctx, "otherapp", "mytool", "v1.0.0", source, binr.WithMaxBandwidth(1))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the download to stop with the context, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the download to stop promptly, took %v", elapsed)
+	}
+}
+
+// TestGet_Verifier ensures verifiers receive each download, and that a
+// download they reject is not installed.
+func TestGet_Verifier(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+
+	var received []string
+	accept := binr.WithVerifier(func(ctx context.Context, path, version, os, arch string) error {
+		received = append(received, version, os, arch)
+		return nil
+	})
+	errUnsigned := errors.New("unsigned")
+	reject := binr.WithVerifier(func(context.Context, string, string, string, string) error {
+		return errUnsigned
+	})
+
+	path, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, accept, reject)
+	if !errors.Is(err, errUnsigned) {
+		t.Fatalf("expected the verifier's error, got %v", err)
+	}
+	if _, err = os.Lstat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected a rejected command not to be installed, got %v", err)
+	}
+	expected := []string{"v1.0.0", runtime.GOOS, runtime.GOARCH}
+	if strings.Join(received, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected the verifier to receive %v, got %v", expected, received)
+	}
+
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source, accept); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGet_VerifierOptions ensures a verifier reads the download from the
+// filesystem of the Get, and makes its requests with the Get's request
+// policy.
+func TestGet_VerifierOptions(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	address := serveFiles(t, map[string][]byte{
+		"/v1.0.0/mytool":     []byte("mytool"),
+		"/v1.0.0/mytool.sig": []byte("signature"),
+	})
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mytool", address, vers), "", nil
+	}
+	_, port, _ := net.SplitHostPort(address)
+
+	verifier := binr.WithVerifier(func(ctx context.Context, path, version, os, arch string) error {
+		f, err := binr.VerifierFilesystem(ctx).Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if content, err := io.ReadAll(f); err != nil || string(content) != "mytool" {
+			return fmt.Errorf("expected the download in the filesystem of the Get, got %q (%v)", content, err)
+		}
+		res, err := binr.VerifierRequest(ctx, http.MethodGet, fmt.Sprintf("http://%v/%v/mytool.sig", address, version))
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		_, err = binr.VerifierRequest(ctx, http.MethodGet, fmt.Sprintf("http://localhost:%v/%v/mytool.sig", port, version))
+		if !errors.Is(err, binr.ErrHostNotAllowed) {
+			return fmt.Errorf("expected a request to a host not allowed to be refused, got %v", err)
+		}
+		return nil
+	})
+	if _, err := binr.Get(ctx, "myapp", "mytool", "v1.0.0", source, verifier,
+		binr.WithFilesystem(newMemFS()), binr.WithAllowedHosts([]string{"127.0.0.1"})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGet_DownloadBufferSize ensures downloads are complete regardless of
+// the size of the buffer used.
+func TestGet_DownloadBufferSize(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	content := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	address := serveContent(t, content)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/mytool", address), "", nil
+	}
+	expected := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	for i, size := range []int{1, 1024 * 1024} {
+		namespace := fmt.Sprintf("myapp%v", i)
+		res, err := binr.GetResult(ctx, namespace, "mytool", "v1.0.0", source,
+			binr.WithDownloadBufferSize(size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Checksum != expected {
+			t.Fatalf("buffer size %v: expected checksum %v, got %v", size, expected, res.Checksum)
+		}
+	}
+}
+
+// TestDoctor ensures that Doctor reports partial downloads, dangling links
+// and corrupted cache objects.
+func TestDoctor(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := binr.Doctor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) > 0 {
+		t.Fatalf("expected no problems, got %v", report.Problems)
+	}
+
+	var (
+		root   = filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr")
+		object = filepath.Join(root, ".cache", testbinChecksum(t))
+	)
+	if err = os.WriteFile(filepath.Join(root, ".cache", "abandoned.partial"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink("missing", filepath.Join(root, "myapp", "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chmod(object, 0755); err != nil { // cached objects are read-only
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(object, []byte("corrupt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if report, err = binr.Doctor(); err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() {
+		t.Fatal("expected the report to include errors")
+	}
+	for _, expected := range []string{"abandoned.partial", "dangling", object} {
+		var found bool
+		for _, p := range report.Problems {
+			if strings.Contains(p.Path, expected) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected a problem with %v, got %v", expected, report.Problems)
+		}
+	}
+}
+
+// TestGet_ReadOnlyCache ensures cached objects are made read-only unless
+// WithWritableCache, and that Doctor reports those which are writable.
+func TestGet_ReadOnlyCache(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	res, err := binr.GetResult(ctx, "myapp", "testbin", "v1.0.0", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache", res.Checksum)
+	info, err := os.Stat(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0555 {
+		t.Fatalf("expected the cached object to be read-only, got %v", info.Mode())
+	}
+
+	if err = os.Chmod(object, 0755); err != nil {
+		t.Fatal(err)
+	}
+	report, err := binr.Doctor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) != 1 || report.Problems[0].Path != object || report.Problems[0].Severity != binr.SeverityWarning {
+		t.Fatalf("expected a warning that the object is writable, got %v", report.Problems)
+	}
+	if report, err = binr.Doctor(binr.WithWritableCache()); err != nil || len(report.Problems) > 0 {
+		t.Fatalf("expected no problems WithWritableCache, got %v, %v", report.Problems, err)
+	}
+}
+
+// TestDoctor_Clock ensures a download lease is judged abandoned by the age
+// reported by the clock.
+func TestDoctor_Clock(t *testing.T) {
+	setupTestGet(t)
+	cache := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache")
+	if err := os.MkdirAll(cache, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	lease := filepath.Join(cache, strings.Repeat("a", 64)+".lease")
+	if err := os.WriteFile(lease, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := binr.Doctor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) > 0 {
+		t.Fatalf("expected a recent lease to be in use, got %v", report.Problems)
+	}
+
+	later := binr.WithClock(func() time.Time { return time.Now().Add(binr.DefaultLeaseTimeout + time.Minute) })
+	if report, err = binr.Doctor(later); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) != 1 || report.Problems[0].Path != lease {
+		t.Fatalf("expected the lease to be reported abandoned, got %v", report.Problems)
+	}
+}
+
+// TestVerifyAll ensures every installed command of every namespace is
+// verified, reporting those which fail.
+func TestVerifyAll(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	for _, ns := range []string{"myapp", "otherapp"} {
+		if _, err := binr.Get(ctx, ns, "testbin", "v1.0.0", source); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr")
+	if err := os.Symlink("../.cache/missing", filepath.Join(root, "otherapp", "dangling-v1.0.0")); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := binr.VerifyAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || len(results["myapp"]) != 2 || len(results["otherapp"]) != 3 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	for ns, rr := range results {
+		for _, r := range rr {
+			if r.Verified != (r.Command == "testbin") {
+				t.Fatalf("%v: unexpected result %+v", ns, r)
+			}
+		}
+	}
+
+	// Corrupt the shared object
+	object := filepath.Join(root, ".cache", testbinChecksum(t))
+	if err = os.Chmod(object, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(object, []byte("corrupt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if results, err = binr.VerifyAll(); err != nil {
+		t.Fatal(err)
+	}
+	for ns, rr := range results {
+		for _, r := range rr {
+			if r.Verified || r.Problem == "" {
+				t.Fatalf("%v: expected verification to fail, got %+v", ns, r)
+			}
+		}
+	}
+}
+
+// TestGet_Platform ensures commands installed for several platforms are
+// linked by platform, and do not overwrite one another.
+func TestGet_Platform(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	address := serveFiles(t, map[string][]byte{
+		"/v1.0.0/darwin/arm64/mytool": []byte("darwin\n"),
+		"/v1.0.0/linux/amd64/mytool":  []byte("linux\n"),
+	})
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/mytool", address, vers, os, arch), "", nil
+	}
+
+	for _, p := range [][2]string{{"darwin", "arm64"}, {"linux", "amd64"}} {
+		res, err := binr.GetResult(ctx, "myapp", "mytool", "v1.0.0", source, binr.WithPlatform(p[0], p[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.OS != p[0] || res.Arch != p[1] {
+			t.Fatalf("expected platform %v/%v, got %v/%v", p[0], p[1], res.OS, res.Arch)
+		}
+	}
+
+	dir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", "myapp")
+	for _, p := range [][2]string{{"darwin", "arm64"}, {"linux", "amd64"}} {
+		for _, name := range []string{"mytool-" + p[0] + "-" + p[1], "mytool-" + p[0] + "-" + p[1] + "-v1.0.0"} {
+			content, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != p[0]+"\n" {
+				t.Fatalf("expected %v to provide the %v command, got %q", name, p[0], content)
+			}
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "mytool")); !os.IsNotExist(err) {
+		t.Fatalf("expected no unqualified link for cross-platform installs, got %v", err)
+	}
+}
+
+// TestGet_ArchVariant ensures the Source is asked for the ARM variant, and
+// that plain "arm" is used if the variant is not provided.
+func TestGet_ArchVariant(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	address := serveFiles(t, map[string][]byte{
+		"/v1.0.0/linux/armv7/mytool": []byte("armv7\n"),
+		"/v1.0.0/linux/arm/mytool":   []byte("arm\n"),
+	})
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/mytool", address, vers, os, arch), "", nil
+	}
+
+	tests := []struct {
+		namespace, variant, arch string
+	}{
+		{"v7app", "v7", "armv7"},
+		{"v6app", "6", "arm"}, // not published for the variant
+		{"plainapp", "", "arm"},
+	}
+	for _, test := range tests {
+		res, err := binr.GetResult(ctx, test.namespace, "mytool", "v1.0.0", source,
+			binr.WithPlatform("linux", "arm"), binr.WithArchVariant(test.variant))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Arch != test.arch {
+			t.Fatalf("variant %q: expected arch %v, got %v", test.variant, test.arch, res.Arch)
+		}
+		content, err := os.ReadFile(res.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != test.arch+"\n" {
+			t.Fatalf("variant %q: expected the %v command, got %q", test.variant, test.arch, content)
+		}
+	}
+}
+
+// TestGet_Retries ensures that a download which fails part way through is
+// retried to a fresh partial, and that no partials are left behind.
+func TestGet_Retries(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	content := bytes.Repeat([]byte("x"), 1024)
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		if atomic.AddInt32(&requests, 1) == 1 {
+			_, _ = w.Write(content[:100]) // fail mid-download
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	source := func(vers, os, arch string) (string, string, error) {
+		return server.URL + "/mytool", "", nil
+	}
+	cacheDir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", ".cache")
+
+	// Without retries the failure is returned
+	if _, err := binr.Get(ctx, "myapp", "mytool", "v1.0.0", source); err == nil {
+		t.Fatal("expected the incomplete download to fail")
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	var names int
+	namer := binr.WithTempNamer(func() string {
+		names++
+		return fmt.Sprintf("attempt%v", names)
+	})
+	res, err := binr.GetResult(ctx, "myapp", "mytool", "v1.0.0", source, binr.WithRetries(2), namer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 || names != 2 {
+		t.Fatalf("expected 2 attempts to unique partials, got %v requests and %v names", requests, names)
+	}
+	if res.Checksum != fmt.Sprintf("%x", sha256.Sum256(content)) {
+		t.Fatal("expected the complete download to be installed")
+	}
+	if res.Attempts != 2 || res.ResolvedURL != server.URL+"/mytool" {
+		t.Fatalf("expected 2 attempts resolved to %v, got %v resolved to %q", server.URL+"/mytool", res.Attempts, res.ResolvedURL)
+	}
+	partials, err := filepath.Glob(filepath.Join(cacheDir, "*.partial"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(partials) > 0 {
+		t.Fatalf("expected no partials to remain, found %v", partials)
+	}
+}
+
+// TestVersions ensures the exact versions installed for a command are
+// listed newest first.
+func TestVersions(t *testing.T) {
+	setupTestGet(t)
+	dir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", "myapp")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"mytool", "mytool-v1.2.0", "mytool-v1.10.0", "mytool-v2.0.0-rc.1",
+		"mytool-v1", "mytool-v1.10", "mytool-linux-amd64-v3.0.0", "other-v4.0.0",
+	} {
+		if err := os.Symlink("target", filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := binr.Versions("myapp", "mytool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"v2.0.0-rc.1", "v1.10.0", "v1.2.0"}
+	if strings.Join(versions, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected versions %v, got %v", expected, versions)
+	}
+}
+
+// TestVersions_Filesystem ensures versions are listed from the provided
+// Filesystem, and only those of the platform given, and that Current agrees
+// with Versions on the links of the platform.
+func TestVersions_Filesystem(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	address := serveFiles(t, map[string][]byte{"/v1.0.0/mytool": []byte("mytool")})
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mytool", address, vers), "", nil
+	}
+	fs, platform := binr.WithFilesystem(newMemFS()), binr.WithPlatform("linux", "arm64")
+	if _, err := binr.Get(ctx, "myapp", "mytool", "v1.0.0", source, fs, platform); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := binr.Versions("myapp", "mytool", fs, platform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions[0] != "v1.0.0" {
+		t.Fatalf("expected the version installed in the filesystem, got %v", versions)
+	}
+	if versions, err = binr.Versions("myapp", "mytool", fs); err != nil || len(versions) != 0 {
+		t.Fatalf("expected no versions of another platform, got %v (%v)", versions, err)
+	}
+	current, err := binr.Current("myapp", "mytool", fs, platform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Version != "v1.0.0" || current.Status != binr.StatusOK {
+		t.Fatalf("expected the current installation of v1.0.0, got %+v", current)
+	}
+}
+
+// TestRemoveCommand ensures all links of a command are removed, leaving
+// other commands and the cache intact.
+func TestRemoveCommand(t *testing.T) {
+	setupTestGet(t)
+	dir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", "myapp")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"mytool", "mytool-v1.2.0", "mytool-v1.10.0", "mytool-extra-v1.0.0", "other-v4.0.0"}
+	for _, name := range names {
+		if err := os.Symlink("target", filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := binr.RemoveCommand("myapp", "mytool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Fatalf("expected 2 version links removed, got %v", removed)
+	}
+	remaining := map[string]bool{"mytool-extra-v1.0.0": true, "other-v4.0.0": true}
+	for _, name := range names {
+		if _, err := os.Lstat(filepath.Join(dir, name)); (err == nil) != remaining[name] {
+			t.Fatalf("unexpected state of link %v after removal: %v", name, err)
+		}
+	}
+
+	// Removing a command which is not installed is a no-op
+	if removed, err = binr.RemoveCommand("myapp", "mytool"); err != nil || removed != 0 {
+		t.Fatalf("expected removing an uninstalled command to do nothing, got %v, %v", removed, err)
+	}
+	if _, err = binr.RemoveCommand("missing", "mytool"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRemoveCommand_NumericArch ensures the links of a platform whose arch
+// is all digits (386) are not mistaken for floating versions.
+func TestRemoveCommand_NumericArch(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	address := serveFiles(t, map[string][]byte{"/v1.0.0/mytool": []byte("mytool")})
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mytool", address, vers), "", nil
+	}
+	platform := binr.WithPlatform("linux", "386")
+	if _, err := binr.Get(ctx, "myapp", "mytool", "v1.0.0", source, platform); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := binr.Get(ctx, "myapp", "mytool", "v1.0.0", source); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := binr.List("myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range list {
+		if i.Command == "mytool-linux" {
+			t.Fatalf("expected the platform-qualified link not to be split at its arch, got %+v", i)
+		}
+	}
+	if versions, err := binr.Versions("myapp", "mytool", platform); err != nil || len(versions) != 1 || versions[0] != "v1.0.0" {
+		t.Fatalf("expected the platform's version, got %v (%v)", versions, err)
+	}
+
+	removed, err := binr.RemoveCommand("myapp", "mytool", platform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Fatalf("expected 1 version link removed, got %v", removed)
+	}
+	dir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", "myapp")
+	for name, remaining := range map[string]bool{
+		"mytool-linux-386":        false,
+		"mytool-linux-386-v1.0.0": false,
+		"mytool":                  true,
+		"mytool-v1.0.0":           true,
+	} {
+		if _, err := os.Lstat(filepath.Join(dir, name)); (err == nil) != remaining {
+			t.Fatalf("unexpected state of link %v after removal: %v", name, err)
+		}
+	}
+}
+
+// TestList ensures installed commands are listed with the status of their
+// links, and that the current version of a command is reported.
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", "myapp")
+	foreign := filepath.Join(t.TempDir(), "foreign")
+	if err := os.WriteFile(foreign, []byte("foreign"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(foreign, filepath.Join(dir, "foreign-v1.0.0")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../.cache/missing", filepath.Join(dir, "dangling-v2.0.0")); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := binr.List("myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]binr.Status{
+		"dangling v2.0.0": binr.StatusDangling,
+		"foreign v1.0.0":  binr.StatusForeign,
+		"testbin ":        binr.StatusOK,
+		"testbin v1.0.0":  binr.StatusOK,
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("expected %v installations, got %v", len(expected), list)
+	}
+	for _, i := range list {
+		if status, ok := expected[i.Command+" "+i.Version]; !ok || status != i.Status {
+			t.Fatalf("unexpected installation %+v", i)
+		}
+	}
+
+	current, err := binr.Current("myapp", "testbin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Version != "v1.0.0" || current.Checksum != testbinChecksum(t) || current.Status != binr.StatusOK {
+		t.Fatalf("unexpected current installation %+v", current)
+	}
+}
+
+// TestChecksum ensures the checksum of an installed command is reported,
+// verified if requested, and that a dangling link is an error.
+func TestChecksum(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	}
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", source); err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range []string{"v1.0.0", ""} {
+		sum, err := binr.Checksum("myapp", "testbin", version, binr.WithVerifyCached())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum != testbinChecksum(t) {
+			t.Fatalf("expected checksum %v, got %v", testbinChecksum(t), sum)
+		}
+	}
+
+	if _, err := binr.Checksum("myapp", "testbin", "v2.0.0"); err == nil {
+		t.Fatal("expected an error for a command not installed")
+	}
+	dir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", "myapp")
+	if err := os.Symlink("../.cache/missing", filepath.Join(dir, "dangling-v1.0.0")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := binr.Checksum("myapp", "dangling", "v1.0.0"); err == nil || !strings.Contains(err.Error(), "Dangling") {
+		t.Fatalf("expected an error for a dangling link, got %v", err)
+	}
+}
+
+// TestGet_NoCache ensures a command can be provided without persisting
+// anything in the binr directory.
+func TestGet_NoCache(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	source := binr.InlineSource(testbinChecksum(t), func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch), "", nil
+	})
+
+	res, err := binr.GetResult(ctx, "myapp", "testbin", "v1.0.0", source, binr.WithNoCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(res.Path)) })
+
+	if filepath.Base(res.Path) != "testbin" {
+		t.Fatalf("expected the command to be named testbin, got %v", res.Path)
+	}
+	if out, err := exec.Command(res.Path).Output(); err != nil || strings.TrimSpace(string(out)) != "OK" {
+		t.Fatalf("expected the command to run, got %q %v", out, err)
+	}
+	if _, err = os.Stat(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing in the binr directory, got %v", err)
+	}
+}
+
+// TestGetResult_Download ensures the Result describes a download, and is
+// zero when the command was not downloaded.
+func TestGetResult_Download(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	content := bytes.Repeat([]byte("x"), 4096)
+	address := serveContent(t, content)
+	source := binr.InlineSource(fmt.Sprintf("%x", sha256.Sum256(content)), func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/mytool", address), "", nil
+	})
+
+	res, err := binr.GetResult(ctx, "myapp", "mytool", "v1.0.0", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Warnings) != 0 {
+		t.Fatalf("expected no warnings, got %q", res.Warnings)
+	}
+	if res.DownloadBytes != int64(len(content)) || res.DownloadDuration <= 0 {
+		t.Fatalf("expected a download of %v bytes with a duration, got %v bytes in %v",
+			len(content), res.DownloadBytes, res.DownloadDuration)
+	}
+	if url := fmt.Sprintf("http://%v/mytool", address); res.ResolvedURL != url || res.Attempts != 1 {
+		t.Fatalf("expected 1 attempt resolved to %v, got %v resolved to %q", url, res.Attempts, res.ResolvedURL)
+	}
+	if res.HashBytes != int64(len(content)) {
+		t.Fatalf("expected the download hashed once, got %v bytes hashed", res.HashBytes)
+	}
+
+	res, err = binr.GetResult(ctx, "otherapp", "mytool", "v1.0.0", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.DownloadBytes != 0 || res.DownloadDuration != 0 || res.ResolvedURL != "" || res.Attempts != 0 {
+		t.Fatalf("expected no download for a cached command, got %v bytes in %v from %q",
+			res.DownloadBytes, res.DownloadDuration, res.ResolvedURL)
+	}
+	if res.HashBytes != 0 || res.HashDuration != 0 {
+		t.Fatalf("expected nothing hashed for a cached command, got %v bytes in %v", res.HashBytes, res.HashDuration)
+	}
+}
+
+// countingProvider is a stateful SourceProvider which counts resolutions.
+type countingProvider struct {
+	address  string
+	resolved int
+}
+
+func (p *countingProvider) Resolve(version, os, arch string) (string, string, error) {
+	p.resolved++
+	return fmt.Sprintf("http://%v/%v/%v/%v/testbin", p.address, version, os, arch), "", nil
+}
+
+// TestGet_SourceProvider ensures an interface-based source can be used
+// wherever a Source is accepted.
+func TestGet_SourceProvider(t *testing.T) {
+	ctx := context.Background()
+	provider := &countingProvider{address: setupTestGet(t)}
+	if _, err := binr.Get(ctx, "myapp", "testbin", "v1.0.0", binr.ProviderSource(provider)); err != nil {
+		t.Fatal(err)
+	}
+	if provider.resolved != 1 {
+		t.Fatalf("expected the provider to resolve once, got %v", provider.resolved)
+	}
+}
+
+// TestGet_ChecksumFormat ensures that BSD checksum files are understood,
+// and that a custom format can be provided for others.
+func TestGet_ChecksumFormat(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	sum := testbinChecksum(t)
+	sums := serveFiles(t, map[string][]byte{
+		"/BSD":    []byte("SHA256 (other) = " + strings.Repeat("a", 64) + "\nSHA256 (testbin) = " + sum + "\n"),
+		"/CUSTOM": []byte("other," + strings.Repeat("a", 64) + "\ntestbin," + sum + "\n"),
+	})
+	source := func(sums string) binr.Source {
+		return func(vers, os, arch string) (string, string, error) {
+			return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch),
+				"http://" + sums, nil
+		}
+	}
+
+	if _, err := binr.Get(ctx, "bsdapp", "testbin", "v1.0.0", source(sums+"/BSD")); err != nil {
+		t.Fatal(err)
+	}
+
+	csv := binr.WithChecksumFormat(func(line string) (string, string, bool) {
+		filename, checksum, ok := strings.Cut(line, ",")
+		return filename, checksum, ok
+	})
+	if _, err := binr.Get(ctx, "csvapp", "testbin", "v1.0.0", source(sums+"/CUSTOM")); !errors.Is(err, binr.ErrChecksumFormat) {
+		t.Fatalf("expected ErrChecksumFormat without the custom format, got %v", err)
+	}
+	if _, err := binr.Get(ctx, "csvapp", "testbin", "v1.0.0", source(sums+"/CUSTOM"), csv); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGet_BSDChecksums ensures that GNU and BSD formats may be mixed in a
+// checksum file, and that the algorithm named by a BSD entry is that by
+// which the command is verified.
+func TestGet_BSDChecksums(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	bb, err := os.ReadFile(filepath.Join("testbins", "v1.0.0", runtime.GOOS, runtime.GOARCH, "testbin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum512, wrong := sha512.Sum512(bb), sha512.Sum512([]byte("wrong"))
+	sums := serveFiles(t, map[string][]byte{
+		"/MIXED": []byte(fmt.Sprintf("%v  other\nMD5 (testbin) = %v\nSHA512 (testbin) = %x\n",
+			strings.Repeat("a", 64), strings.Repeat("b", 32), sum512)),
+		"/WRONG": []byte(fmt.Sprintf("SHA512 (testbin) = %x\n", wrong)),
+	})
+	source := func(sums string) binr.Source {
+		return func(vers, os, arch string) (string, string, error) {
+			return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch),
+				"http://" + sums, nil
+		}
+	}
+
+	if _, err = binr.Get(ctx, "myapp", "testbin", "v1.0.0", source(sums+"/MIXED")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = binr.Get(ctx, "otherapp", "testbin", "v1.0.0", source(sums+"/WRONG")); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected the command to be verified by sha512, got %v", err)
+	}
+}
+
+// TestGet_ChecksumEncoding ensures checksums published base64-encoded or in
+// SRI format are decoded and verified, including those of other algorithms.
+func TestGet_ChecksumEncoding(t *testing.T) {
+	ctx := context.Background()
+	serverAddress := setupTestGet(t)
+	bb, err := os.ReadFile(filepath.Join("testbins", "v1.0.0", runtime.GOOS, runtime.GOARCH, "testbin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum256, sum512 := sha256.Sum256(bb), sha512.Sum512(bb)
+	wrong := sha512.Sum512([]byte("wrong"))
+	sums := serveFiles(t, map[string][]byte{
+		"/base64": []byte(base64.StdEncoding.EncodeToString(sum256[:]) + "  testbin\n"),
+		"/sri256": []byte("sha256-" + base64.StdEncoding.EncodeToString(sum256[:])),
+		"/sri512": []byte("sha512-" + base64.StdEncoding.EncodeToString(sum512[:])),
+		"/wrong":  []byte("sha512-" + base64.StdEncoding.EncodeToString(wrong[:])),
+	})
+	source := func(file string) binr.Source {
+		return func(vers, os, arch string) (string, string, error) {
+			return fmt.Sprintf("http://%v/%v/%v/%v/testbin", serverAddress, vers, os, arch),
+				"http://" + sums + file, nil
+		}
+	}
+
+	tests := []struct {
+		namespace, file, encoding string
+	}{
+		{"base64app", "/base64", "base64"},
+		{"sri256app", "/sri256", "sri"},
+		{"sri512app", "/sri512", "sri"},
+	}
+	for _, test := range tests {
+		res, err := binr.GetResult(ctx, test.namespace, "testbin", "v1.0.0", source(test.file),
+			binr.WithChecksumEncoding(test.encoding))
+		if err != nil {
+			t.Fatalf("%v: %v", test.file, err)
+		}
+		if res.Checksum != testbinChecksum(t) {
+			t.Fatalf("%v: expected the command cached by its sha256, got %v", test.file, res.Checksum)
+		}
+	}
+
+	_, err = binr.Get(ctx, "wrongapp", "testbin", "v1.0.0", source("/wrong"),
+		binr.WithChecksumEncoding("sri"))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+
+	_, err = binr.Get(ctx, "hexapp", "testbin", "v1.0.0", source("/base64"))
+	if !errors.Is(err, binr.ErrChecksumFormat) {
+		t.Fatalf("expected base64 checksums to be unrecognized as hex, got %v", err)
+	}
+
+	_, err = binr.Get(ctx, "otherapp", "testbin", "v1.0.0", source("/base64"),
+		binr.WithChecksumEncoding("base32"))
+	if err == nil || !strings.Contains(err.Error(), "base32") {
+		t.Fatalf("expected an unsupported encoding error, got %v", err)
+	}
+}
+
+// staticLister is a Lister of a fixed list of releases.
+type staticLister []string
+
+func (l *staticLister) List(context.Context) ([]string, error) { return *l, nil }
+
+// listingProvider is a SourceProvider which also lists its releases.
+type listingProvider struct {
+	staticLister
+}
+
+func (listingProvider) Resolve(version, os, arch string) (string, string, error) {
+	return "", "", errors.New("not implemented")
+}
+
+// TestAvailable ensures the versions of a source which is a Lister are
+// listed, and that a plain Source reports that listing is unsupported.
+func TestAvailable(t *testing.T) {
+	ctx := context.Background()
+	p := &listingProvider{staticLister{"v1.2.0", "latest", "v1.10.0", "v1.3.0-rc.1", "v1"}}
+	versions, err := binr.Available(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"v1.10.0", "v1.3.0-rc.1", "v1.2.0"}; fmt.Sprint(versions) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, got %v", expected, versions)
+	}
+
+	source := binr.Source(func(vers, os, arch string) (string, string, error) { return "", "", nil })
+	if _, err = binr.Available(ctx, source); !errors.Is(err, binr.ErrListingUnsupported) {
+		t.Fatalf("expected ErrListingUnsupported, got %v", err)
+	}
+}
+
+// TestUpdate ensures that requesting that a binary be updated causes the
+// abolute latest version to be installed, as well as the latest for each
+// of the major and minor versions installed.
+// For example, if the versions of myapp released, were:
+//
+//	v2.0.0
+//	v1.3.0
+//	v1.2.2
+//	v1.2.1
+//	v1.2.0
+//	v1.1.2
+//	v1.1.1
+//	v1.0.0
+//
+// Previous calls to .Get have resulted in the following versions being
+// installed locally, and then the updated target after a later .Update:
+//
+//	Invocation       Symlink      Initial Target  Target after .Update
+//	Get "latest"  => mybin        -> v1.2.1       -> v2.0.0
+//	Get "v1"      => mybin-v1     -> v1.2.1       -> v1.3.0
+//	Get "v1.1"    => mybin-v1.1   -> v1.1.1       -> v1.1.2
+//	Get "v1.1.2"  => mybin-v1.1.2 -> v1.1.2       -> v1.1.2 (unchanged)
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	released := []string{"v2.0.0", "v1.3.0", "v1.2.2", "v1.2.1", "v1.2.0", "v1.1.2", "v1.1.1", "v1.0.0"}
+	files := map[string][]byte{}
+	for _, v := range released {
+		files["/"+v+"/mybin"] = []byte(v)
+	}
+	address := serveFiles(t, files)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mybin", address, vers), "", nil
+	}
+
+	// Initially the releases up to v1.2.1, excluding v1.1.2
+	lister := staticLister{"v1.2.1", "v1.2.0", "v1.1.1", "v1.0.0"}
+	for _, version := range []string{"latest", "v1", "v1.1", "v1.1.2"} {
+		if _, err := binr.Get(ctx, "myapp", "mybin", version, source, binr.WithLister(&lister)); err != nil {
+			t.Fatalf("%v: %v", version, err)
+		}
+	}
+	expectTargets := func(expected map[string]string) {
+		t.Helper()
+		for name, version := range expected {
+			content, err := os.ReadFile(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", "myapp", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != version {
+				t.Fatalf("expected %v to target %v, got %v", name, version, string(content))
+			}
+		}
+	}
+	expectTargets(map[string]string{
+		"mybin":        "v1.2.1",
+		"mybin-v1":     "v1.2.1",
+		"mybin-v1.1":   "v1.1.1",
+		"mybin-v1.1.2": "v1.1.2",
+	})
+
+	lister = released
+	if _, err := binr.Get(ctx, "myapp", "mybin", "v1.1.2", source, binr.WithLister(&lister), binr.WithUpdate()); err != nil {
+		t.Fatal(err)
+	}
+	expectTargets(map[string]string{
+		"mybin":        "v2.0.0",
+		"mybin-v1":     "v1.3.0",
+		"mybin-v1.1":   "v1.1.2",
+		"mybin-v1.1.2": "v1.1.2",
+		"mybin-v2.0.0": "v2.0.0",
+		"mybin-v1.3.0": "v1.3.0",
+	})
+
+	// Floating versions can not be resolved without a Lister
+	if _, err := binr.Get(ctx, "otherapp", "mybin", "v1", source); err == nil || !strings.Contains(err.Error(), "WithLister") {
+		t.Fatalf("expected an error requiring a Lister, got %v", err)
+	}
+}
+
+// TestGet_FloatingInstalled ensures a floating version already installed is
+// served as installed, rather than resolved anew, such that a release
+// published since does not replace it without WithUpdate and no Lister is
+// needed to serve it.
+func TestGet_FloatingInstalled(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	files := map[string][]byte{}
+	for _, v := range []string{"v1.0.0", "v1.1.0"} {
+		files["/"+v+"/mybin"] = []byte(v)
+	}
+	address := serveFiles(t, files)
+	source := func(vers, os, arch string) (string, string, error) {
+		return fmt.Sprintf("http://%v/%v/mybin", address, vers), "", nil
+	}
+	lister := staticLister{"v1.0.0"}
+	expectTarget := func(path, version string) {
+		t.Helper()
+		if content, err := os.ReadFile(path); err != nil || string(content) != version {
+			t.Fatalf("expected %v to target %v, got %q (%v)", path, version, content, err)
+		}
+	}
+
+	res, err := binr.GetResult(ctx, "myapp", "mybin", "v1", source, binr.WithLister(&lister))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTarget(res.Path, "v1.0.0")
+
+	// A newer release is not installed without WithUpdate
+	lister = staticLister{"v1.1.0", "v1.0.0"}
+	if res, err = binr.GetResult(ctx, "myapp", "mybin", "v1", source, binr.WithLister(&lister)); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Cached || res.Version != "v1.0.0" {
+		t.Fatalf("expected the installed v1.0.0 served, got %+v", res)
+	}
+	expectTarget(res.Path, "v1.0.0")
+
+	// Served without a Lister, and offline
+	offline := func(vers, os, arch string) (string, string, error) {
+		return "", "", errors.New("offline")
+	}
+	if res, err = binr.GetResult(ctx, "myapp", "mybin", "v1", offline); err != nil {
+		t.Fatal(err)
+	}
+	expectTarget(res.Path, "v1.0.0")
+
+	// Resolved anew WithUpdate
+	if res, err = binr.GetResult(ctx, "myapp", "mybin", "v1", source, binr.WithLister(&lister), binr.WithUpdate()); err != nil {
+		t.Fatal(err)
+	}
+	expectTarget(res.Path, "v1.1.0")
+}
+
+// TestUpdate_Swap ensures that with WithSwapOnUpdate no floating link is
+// changed unless every release to which they are updated is verified.
+func TestUpdate_Swap(t *testing.T) {
+	ctx := context.Background()
+	setupTestGet(t)
+	released := []string{"v2.0.0", "v1.1.0", "v1.0.0"}
+	files := map[string][]byte{}
+	for _, v := range released {
+		files["/"+v+"/mybin"] = []byte(v)
+	}
+	address := serveFiles(t, files)
+	corrupt := "v1.1.0"
+	source := func(vers, os, arch string) (string, string, error) {
+		content := vers
+		if vers == corrupt {
+			content = "corrupt"
+		}
+		return fmt.Sprintf("http://%v/%v/mybin", address, vers), fmt.Sprintf("%x", sha256.Sum256([]byte(content))), nil
+	}
+	expectTargets := func(expected map[string]string) {
+		t.Helper()
+		for name, version := range expected {
+			content, err := os.ReadFile(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", "myapp", name))
+			if err != nil {
+				t.Fatalf("%v: %v", name, err)
+			}
+			if string(content) != version {
+				t.Fatalf("expected %v to target %v, got %v", name, version, string(content))
+			}
+		}
+	}
+
+	lister := staticLister{"v1.0.0"}
+	for _, version := range []string{"latest", "v1"} {
+		if _, err := binr.Get(ctx, "myapp", "mybin", version, source, binr.WithLister(&lister)); err != nil {
+			t.Fatalf("%v: %v", version, err)
+		}
+	}
+
+	// The unversioned link would be updated to v2.0.0 before v1 failed
+	// verification, were the links not swapped only once all are verified.
+	lister = released
+	_, err := binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithLister(&lister), binr.WithSwapOnUpdate())
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	expectTargets(map[string]string{
+		"mybin":    "v1.0.0",
+		"mybin-v1": "v1.0.0",
+	})
+	if _, err = os.Lstat(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "binr", "myapp", "mybin-v2.0.0")); !os.IsNotExist(err) {
+		t.Fatalf("expected v2.0.0 not to be linked, got %v", err)
+	}
+
+	// Once republished, all links are swapped.
+	corrupt = ""
+	if _, err = binr.Get(ctx, "myapp", "mybin", "v1.0.0", source, binr.WithLister(&lister), binr.WithSwapOnUpdate()); err != nil {
+		t.Fatal(err)
+	}
+	expectTargets(map[string]string{
+		"mybin":        "v2.0.0",
+		"mybin-v1":     "v1.1.0",
+		"mybin-v1.1.0": "v1.1.0",
+		"mybin-v2.0.0": "v2.0.0",
+	})
+}
+
 // TODO: Several more tests are needed because the above only confirms the
 // basic, happy path.
 //
@@ -101,28 +4613,35 @@ func TestGet(t *testing.T) {
 //
 // TestGet_Minor ensures that requesting only the major version results
 // in the latest release for that major version being isntalled
-//
-// TestUpdate ensures that requesting that a binary be updated causes the
-// abolute latest version to be installed, as well as the latest for each
-// of the major and minor versions installed.
-// For example, if the versions of myapp released, were:
-//   v2.0.0
-//   v1.3.0
-//   v1.2.2
-//   v1.2.1
-//   v1.2.0
-//   v1.1.2
-//   v1.1.1
-//   v1.0.0
-//
-// Previous calls to .Get have resulted in the following versions being
-// installed locally, and then the updated target after a later .Update:
-//
-//   Invocation       Symlink      Initial Target  Target afte .Update
-//   Get "latest"  => mybin        -> v1.2.1       -> v2.0.0
-//   Get "v1"      => mybin-v1     -> v1.2.1       -> v1.3.0
-//   Get "v1.1"    => mybin-v1.1   -> v1.1.1       -> v1.1.2
-//   Get "v1.1.2"  => mybin-v1.1.2 -> v1.1.2       -> v1.1.2 (unchanged)
+
+// TestClassifyVersion ensures version strings are classified by kind.
+func TestClassifyVersion(t *testing.T) {
+	tests := map[string]binr.VersionKind{
+		"v1.2.3":        binr.Exact,
+		"1.2.3-rc.1+b1": binr.Exact,
+		"v1":            binr.MajorConstraint,
+		"v1.2":          binr.MinorConstraint,
+		"latest":        binr.Latest,
+		">=1.2, <2":     binr.Constraint,
+		"~1.2":          binr.Constraint,
+		"stable":        binr.Channel,
+		"sha:0123abc":   binr.Digest,
+	}
+	for s, expected := range tests {
+		kind, err := binr.ClassifyVersion(s)
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if kind != expected {
+			t.Fatalf("%q: expected %v, got %v", s, expected, kind)
+		}
+	}
+	for _, s := range []string{"", "v1.2.3.4/..", "not a version"} {
+		if kind, err := binr.ClassifyVersion(s); err == nil {
+			t.Fatalf("%q: expected an error, got %v", s, kind)
+		}
+	}
+}
 
 // TestPath ensures that the expected absolute path is returned from the
 // Path method.
@@ -195,52 +4714,59 @@ func TestPath(t *testing.T) {
 
 // Helpers
 
+// serveBinaries from the testbins directory, refusing HEAD requests.
 func serveBinaries(t *testing.T) (string, error) {
 	t.Helper()
-	handler := http.NewServeMux()
-	handler.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	binaries := binrtest.Handler("testbins")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 			return
 		}
-		filepath := filepath.Join("testbins", r.URL.Path) // TODO: Convert slashes for windows :(
+		binaries.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server.Listener.Addr().String(), nil
+}
 
-		file, err := os.Open(filepath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			http.Error(w, "File not found", http.StatusNotFound)
-			return
-		}
-		defer file.Close()
+// testbinChecksum returns the sha256 of the test binary for the current
+// platform.
+func testbinChecksum(t *testing.T) string {
+	t.Helper()
+	bb, err := os.ReadFile(filepath.Join("testbins", "v1.0.0", runtime.GOOS, runtime.GOARCH, "testbin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(bb))
+}
 
+// serveContent serves the given content as a binary for all requests.
+func serveContent(t *testing.T, content []byte) string {
+	t.Helper()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/octet-stream")
-
-		_, err = io.Copy(w, file)
-		if err != nil {
-			log.Printf("Failed to write response: %v", err)
-			http.Error(w, fmt.Sprintf("Failed to write response, %v", err), http.StatusInternalServerError)
-		}
-
+		_, _ = w.Write(content)
 	})
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.Listener.Addr().String()
+}
 
-	listener, err := net.Listen("tcp4", "127.0.0.1:")
-	if err != nil {
-		log.Fatalf("Failed to create listener: %v", err)
-		return "", err
-	}
-
-	server := http.Server{Handler: handler}
-	go func() {
-		if err = server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			fmt.Fprintf(os.Stderr, "error serving: %v", err)
+// serveFiles serves the given content by path.
+func serveFiles(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
 		}
-	}()
-	t.Cleanup(func() {
-		_ = server.Close()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(content)
 	})
-
-	return listener.Addr().String(), nil
-
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.Listener.Addr().String()
 }
 
 func setupTestGet(t *testing.T) (serverAddr string) {
@@ -0,0 +1,100 @@
+// Package binrtest provides an HTTP server of commands, such as that of a
+// release host, against which to test Sources.
+package binrtest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"time"
+)
+
+// checksumSuffix is the extension of the checksum files served.
+const checksumSuffix = ".sha256"
+
+// NewServer returns a started test server of the files beneath rootDir,
+// which the caller is to close.  See Handler.
+func NewServer(rootDir string) *httptest.Server {
+	return httptest.NewServer(Handler(rootDir))
+}
+
+// Handler serves the files beneath rootDir at their relative paths (such as
+// /v1.0.0/linux/amd64/mycmd) as does a typical release host: with the
+// content type application/octet-stream, and supporting HEAD and Range
+// requests.  A request for a file's path with the suffix .sha256 is served
+// that file if it exists, or otherwise the sha256 of the file in the format
+// of the sha256sum utility.  Directories are not served.
+func Handler(rootDir string) http.Handler {
+	root := http.Dir(rootDir)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := r.URL.Path
+		contentType := "application/octet-stream"
+		if strings.HasSuffix(name, checksumSuffix) {
+			contentType = "text/plain; charset=utf-8"
+		}
+		file, modTime, err := open(root, name)
+		if errors.Is(err, fs.ErrNotExist) && strings.HasSuffix(name, checksumSuffix) {
+			file, modTime, err = checksum(root, strings.TrimSuffix(name, checksumSuffix))
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		w.Header().Set("Content-Type", contentType)
+		http.ServeContent(w, r, "", modTime, file)
+	})
+}
+
+// open the named regular file.
+func open(root http.FileSystem, name string) (io.ReadSeekCloser, time.Time, error) {
+	file, err := root.Open(name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, time.Time{}, err
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, time.Time{}, fmt.Errorf("%v is not a file. %w", name, fs.ErrNotExist)
+	}
+	return file, info.ModTime(), nil
+}
+
+// checksum returns a checksum file of the named file.
+func checksum(root http.FileSystem, name string) (io.ReadSeekCloser, time.Time, error) {
+	file, modTime, err := open(root, name)
+	if err != nil {
+		return nil, modTime, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return nil, modTime, err
+	}
+	content := fmt.Sprintf("%v  %v\n", hex.EncodeToString(hash.Sum(nil)), path.Base(name))
+	return memFile{bytes.NewReader([]byte(content))}, modTime, nil
+}
+
+// memFile is an in-memory file.
+type memFile struct{ *bytes.Reader }
+
+func (memFile) Close() error { return nil }
@@ -0,0 +1,55 @@
+package binrtest_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lkingland/binr"
+	"github.com/lkingland/binr/binrtest"
+)
+
+// TestNewServer ensures a Source of the server's files, with checksums it
+// serves, installs, and that Range requests are supported.
+func TestNewServer(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "v1.0.0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "v1.0.0", "mytool"), []byte("mytool"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := binrtest.NewServer(root)
+	t.Cleanup(server.Close)
+
+	source := func(vers, os, arch string) (string, string, error) {
+		url := server.URL + "/" + vers + "/mytool"
+		return url, url + ".sha256", nil
+	}
+	path, err := binr.Get(context.Background(), "myapp", "mytool", "v1.0.0", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(path); err != nil || string(content) != "mytool" {
+		t.Fatalf("expected the installed command, got %q (%v)", content, err)
+	}
+	if _, err = binr.Get(context.Background(), "myapp", "mytool", "v2.0.0", source); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/v1.0.0/mytool", nil)
+	req.Header.Set("Range", "bytes=2-")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusPartialContent || string(body) != "tool" {
+		t.Fatalf("expected a partial response, got %v %q", res.Status, body)
+	}
+}
@@ -0,0 +1,350 @@
+package binr
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrChecksumFormat is returned (wrapped) when the content of a checksum URL
+// can not be understood, or does not contain a checksum for the command.
+var ErrChecksumFormat = errors.New("binr checksum format not recognized")
+
+// ChecksumFormat parses a line of a checksum file, returning the filename
+// and checksum it lists.  ok is false if the line is not an entry.  See
+// WithChecksumFormat.
+type ChecksumFormat func(line string) (filename, checksum string, ok bool)
+
+// GNUChecksumFormat parses lines in the format output by GNU coreutils'
+// sha256sum, in which the filename is prefixed by an asterisk in binary
+// mode:
+//
+//	<checksum>  <filename>
+func GNUChecksumFormat(line string) (filename, checksum string, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return strings.TrimPrefix(fields[1], "*"), fields[0], true
+}
+
+// bsdChecksumLine is the format of a line output by BSD's sha256 and by
+// shasum --tag.
+var bsdChecksumLine = regexp.MustCompile(`^\s*([A-Za-z0-9-]+) \((.+)\) = (\S+)\s*$`)
+
+// BSDChecksumFormat parses lines in the format output by BSD's sha256 (and
+// by shasum --tag):
+//
+//	SHA256 (<filename>) = <checksum>
+//
+// The algorithm named determines that by which the command is verified.
+// Lines of algorithms which are not supported (such as MD5) are not
+// entries.
+func BSDChecksumFormat(line string) (filename, checksum string, ok bool) {
+	m := bsdChecksumLine.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+	algorithm := strings.ToLower(m[1])
+	if _, ok := checksumAlgorithms[algorithm]; !ok {
+		return "", "", false
+	}
+	if algorithm == "sha256" {
+		return m[2], m[3], true
+	}
+	return m[2], algorithm + "-" + m[3], true
+}
+
+// parseChecksums returns the checksum for the file at sourceURL from the
+// content received from checksumURL.  The content is expected to be either
+// a lone checksum, or a list of checksums one per line, in which case the
+// entry whose filename matches that of the sourceURL is used.  Lines are
+// parsed by the given format if provided (see WithChecksumFormat).
+// Otherwise the formats of both GNUChecksumFormat and BSDChecksumFormat are
+// understood, and failing a match of the filename, entries keyed by the
+// full source URL (or its path) are matched, in either order:
+//
+//	<url>  <checksum>
+//
+// Checksums are decoded from the given encoding (see WithChecksumEncoding).
+func parseChecksums(content, checksumURL, sourceURL, encoding string, format ChecksumFormat) (string, error) {
+	content = strings.TrimSpace(content)
+	if sum, ok := decodeChecksum(encoding, content); ok {
+		return sum, nil
+	}
+
+	filename := sourceFilename(sourceURL)
+	lines := strings.Split(content, "\n")
+	if format != nil {
+		for _, line := range lines {
+			name, s, ok := format(line)
+			if !ok || (name != filename && !isSourceURL(name, sourceURL)) {
+				continue
+			}
+			if sum, ok := decodeChecksum(encoding, s); ok {
+				return sum, nil
+			}
+		}
+	} else {
+		for _, format := range []ChecksumFormat{GNUChecksumFormat, BSDChecksumFormat} {
+			for _, line := range lines {
+				name, s, ok := format(line)
+				if !ok || name != filename {
+					continue
+				}
+				if sum, ok := decodeChecksum(encoding, s); ok {
+					return sum, nil
+				}
+			}
+		}
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			sum, ok := decodeChecksum(encoding, fields[0])
+			name := fields[1]
+			if !ok {
+				sum, ok = decodeChecksum(encoding, fields[1])
+				name = fields[0]
+			}
+			if ok && isSourceURL(strings.TrimPrefix(name, "*"), sourceURL) {
+				return sum, nil
+			}
+		}
+	}
+
+	if err := hexDigestError(content); err != nil && (encoding == "" || encoding == "hex") {
+		return "", err
+	}
+	if content == "" {
+		lines = nil
+	}
+	return "", fmt.Errorf("binr could not find a checksum for %q in the %v line(s) received from checksum URL %q. "+
+		"Expected either a lone checksum, or lines of the form \"<checksum>  %v\". %w",
+		filename, len(lines), checksumURL, filename, ErrChecksumFormat)
+}
+
+// isSourceURL returns true if the given checksum entry name is the source
+// URL, or the path portion of it.
+func isSourceURL(name, sourceURL string) bool {
+	if name == sourceURL {
+		return true
+	}
+	u, err := url.Parse(sourceURL)
+	if err != nil || u.Path == "" {
+		return false
+	}
+	return strings.TrimPrefix(name, "/") == strings.TrimPrefix(u.Path, "/")
+}
+
+// sourceFilename returns the filename portion of the given source URL.
+func sourceFilename(sourceURL string) string {
+	if u, err := url.Parse(sourceURL); err == nil {
+		return path.Base(u.Path)
+	}
+	return path.Base(sourceURL)
+}
+
+// checksumAlgorithms are the digests in which a checksum may be published,
+// keyed by the algorithm name used in SRI (Subresource Integrity) format.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// inferredAlgorithms are the digests which, in addition to those of
+// checksumAlgorithms, may be inferred from the length of a published
+// hex-encoded checksum.  They are weak, and supported only such that
+// commands published with no stronger checksum may still be verified.
+var inferredAlgorithms = map[string]func() hash.Hash{
+	"sha1": sha1.New,
+	"md5":  md5.New,
+}
+
+// inferAlgorithm returns the algorithm of the hex-encoded digest by its
+// length.  ok is false if it is not hex or its length is that of no known
+// algorithm.
+func inferAlgorithm(digest string) (algorithm string, ok bool) {
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", false
+	}
+	for _, algorithms := range []map[string]func() hash.Hash{checksumAlgorithms, inferredAlgorithms} {
+		for algorithm, newHash := range algorithms {
+			if len(digest) == newHash().Size()*2 {
+				return algorithm, true
+			}
+		}
+	}
+	return "", false
+}
+
+// hexDigestError returns an error if s appears to be a hex-encoded checksum
+// but its algorithm can not be inferred from its length.
+func hexDigestError(s string) error {
+	if _, err := hex.DecodeString(s); err != nil || s == "" {
+		return nil
+	}
+	return fmt.Errorf("binr unable to infer the algorithm of checksum %q from its length of %v. "+
+		"Expected 32 (md5), 40 (sha1), 64 (sha256), 96 (sha384) or 128 (sha512) hex digits. %w", s, len(s), ErrChecksumFormat)
+}
+
+// validChecksumEncoding returns true if the given encoding is supported by
+// WithChecksumEncoding.  The empty string is the default (hex).
+func validChecksumEncoding(encoding string) bool {
+	switch encoding {
+	case "", "hex", "base64", "sri":
+		return true
+	}
+	return false
+}
+
+// decodeChecksum returns the checksum s, published in the given encoding,
+// in the form used internally: a lowercase hex-encoded sha256, or for other
+// algorithms the algorithm's name and hex-encoded digest separated by a
+// hyphen (sha512-<hex>).  A hex-encoded sha256, or a checksum already in
+// the internal form, is accepted regardless of encoding, such that inline
+// and pinned checksums continue to work.  The algorithm of a hex-encoded
+// checksum is inferred from its length (see inferAlgorithm).  ok is false
+// if s is not a checksum in the given encoding.
+func decodeChecksum(encoding, s string) (sum string, ok bool) {
+	if isChecksum(s) {
+		return strings.ToLower(s), true
+	}
+	if algorithm, digest, found := strings.Cut(s, "-"); found {
+		if newHash, known := checksumAlgorithms[algorithm]; known && len(digest) == newHash().Size()*2 {
+			if _, err := hex.DecodeString(digest); err != nil {
+				return "", false
+			} else if algorithm == "sha256" {
+				return strings.ToLower(digest), true
+			}
+			return algorithm + "-" + strings.ToLower(digest), true
+		}
+	}
+	switch encoding {
+	case "", "hex":
+		if algorithm, ok := inferAlgorithm(s); ok {
+			return algorithm + "-" + strings.ToLower(s), true
+		}
+	case "base64":
+		if digest, ok := decodeBase64(s); ok && len(digest) == sha256.Size {
+			return hex.EncodeToString(digest), true
+		}
+	case "sri":
+		algorithm, b64, found := strings.Cut(s, "-")
+		newHash, known := checksumAlgorithms[algorithm]
+		if !found || !known {
+			return "", false
+		}
+		digest, ok := decodeBase64(b64)
+		if !ok || len(digest) != newHash().Size() {
+			return "", false
+		}
+		if algorithm == "sha256" {
+			return hex.EncodeToString(digest), true
+		}
+		return algorithm + "-" + hex.EncodeToString(digest), true
+	}
+	return "", false
+}
+
+// decodeBase64 decodes s in standard base64 encoding, with or without
+// padding.
+func decodeBase64(s string) ([]byte, bool) {
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, true
+	}
+	if b, err := base64.RawStdEncoding.DecodeString(s); err == nil {
+		return b, true
+	}
+	return nil, false
+}
+
+// splitChecksum returns the algorithm and hex-encoded digest of a checksum
+// in the internal form returned by decodeChecksum.
+func splitChecksum(checksum string) (algorithm, digest string) {
+	if algorithm, digest, ok := strings.Cut(checksum, "-"); ok {
+		return algorithm, digest
+	}
+	return "sha256", checksum
+}
+
+// multihashCodes are the multihash codes of the checksum algorithms.
+var multihashCodes = map[string]byte{
+	"sha256": 0x12,
+	"sha384": 0x20,
+	"sha512": 0x13,
+}
+
+// multihash returns the hex-encoded multihash of the given checksum: the
+// code of its algorithm and the length of its digest preceding the digest
+// (1220<hex> for sha256).
+func multihash(checksum string) string {
+	algorithm, digest := splitChecksum(checksum)
+	return fmt.Sprintf("%02x%02x%v", multihashCodes[algorithm], len(digest)/2, digest)
+}
+
+// parseMultihash returns the checksum encoded by the hex-encoded multihash,
+// in the form returned by decodeChecksum.  ok is false if s is not the
+// multihash of a supported algorithm.
+func parseMultihash(s string) (checksum string, ok bool) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) < 2 || int(b[1]) != len(b)-2 {
+		return "", false
+	}
+	for algorithm, code := range multihashCodes {
+		if code != b[0] || checksumAlgorithms[algorithm]().Size() != len(b)-2 {
+			continue
+		}
+		digest := hex.EncodeToString(b[2:])
+		if algorithm == "sha256" {
+			return digest, true
+		}
+		return algorithm + "-" + digest, true
+	}
+	return "", false
+}
+
+// objectName returns the name of the object in the cache with the given
+// checksum, which is the checksum itself unless WithMultihashNaming.  The
+// name is solely that of its content, with nothing of the OS which cached
+// it, such that a cache may be shared by several systems.
+func (c config) objectName(checksum string) string {
+	algorithm, _ := splitChecksum(checksum)
+	if _, ok := multihashCodes[algorithm]; c.multihashNaming && ok {
+		return multihash(checksum)
+	}
+	return checksum
+}
+
+// objectPath returns the path of the object in the cache with the given
+// checksum.
+func (c config) objectPath(checksum string) string {
+	return filepath.Join(c.cachePath(), c.objectName(checksum))
+}
+
+// hasherChecksum matches the checksums calculated by a Hasher other than
+// sha256 (see calculateChecksum).
+var hasherChecksum = regexp.MustCompile(`^[a-z][a-z0-9]*-(?:[0-9a-f]{2}){4,}$`)
+
+// objectChecksum returns the checksum of the cached object with the given
+// name, which may be either the checksum or its multihash.  ok is false if
+// the name is not that of a cached object.
+func objectChecksum(name string) (checksum string, ok bool) {
+	if isChecksum(name) || hasherChecksum.MatchString(name) {
+		return name, true
+	}
+	return parseMultihash(name)
+}
@@ -0,0 +1,39 @@
+package binr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInsufficientDisk is returned (wrapped) when a download would leave
+// less free disk than required.  See WithMinFreeDisk.
+var ErrInsufficientDisk = errors.New("binr insufficient disk space")
+
+// errDiskFreeUnsupported is returned by diskFree on systems where the free
+// space of a filesystem can not be determined.
+var errDiskFreeUnsupported = errors.New("binr unable to determine free disk space on this system")
+
+// checkFreeDisk returns an error if writing size bytes in dir would leave
+// less than the config's minimum free.  A negative size (unknown) is not
+// checked, nor is a directory on a Filesystem other than the OS's.
+func checkFreeDisk(cfg config, dir string, size int64) error {
+	if size < 0 {
+		cfg.log.Debug().Msg("binr download size unknown. skipping free disk check")
+		return nil
+	}
+	if _, ok := cfg.fs.(osFilesystem); !ok {
+		cfg.log.Debug().Msg("binr unable to check free disk of a custom filesystem. skipping")
+		return nil
+	}
+	free, err := diskFree(dir)
+	if errors.Is(err, errDiskFreeUnsupported) {
+		cfg.log.Debug().Msg("binr free disk check unsupported. skipping")
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("binr unable to determine free disk space of %v. %w", dir, err)
+	}
+	if required := uint64(size) + uint64(cfg.minFreeDisk); free < required {
+		return fmt.Errorf("binr refusing a download of %v bytes as %v has %v bytes free, and %v are to be left free. %w", size, dir, free, cfg.minFreeDisk, ErrInsufficientDisk)
+	}
+	return nil
+}
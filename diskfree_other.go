@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd || dragonfly || windows)
+
+package binr
+
+// diskFree is not supported on this system.
+func diskFree(path string) (uint64, error) {
+	return 0, errDiskFreeUnsupported
+}
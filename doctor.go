@@ -0,0 +1,180 @@
+package binr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Severity of a Problem found by Doctor.
+type Severity string
+
+const (
+	// SeverityWarning problems may be benign, such as a partial download
+	// which is in progress.
+	SeverityWarning Severity = "warning"
+	// SeverityError problems will cause commands to fail.
+	SeverityError Severity = "error"
+)
+
+// Problem found by Doctor.
+type Problem struct {
+	Severity Severity
+	Path     string // file or directory concerned, if any
+	Message  string
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return fmt.Sprintf("%v: %v", p.Severity, p.Message)
+	}
+	return fmt.Sprintf("%v: %v: %v", p.Severity, p.Path, p.Message)
+}
+
+// Report of the problems found by Doctor.
+type Report struct {
+	Problems []Problem
+}
+
+// OK returns true if no errors were found (warnings may have been).
+func (r Report) OK() bool {
+	for _, p := range r.Problems {
+		if p.Severity == SeverityError {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) add(severity Severity, path, format string, args ...any) {
+	r.Problems = append(r.Problems, Problem{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Doctor checks the environment for common problems, such as an unwritable
+// cache, orphaned partial downloads, dangling links and cached objects
+// which fail their checksum, returning a Report by which users can diagnose
+// them.  An error is returned only if the checks themselves could not be
+// completed.
+func Doctor(options ...option) (report Report, err error) {
+	cfg := newConfig(options...)
+
+	// Home directory
+	if cfg.workingDirFallback() {
+		report.add(SeverityWarning, "", "neither a home directory nor XDG_CONFIG_HOME were found, so the current working directory is used")
+	}
+
+	// Cache
+	cacheDir := cfg.cachePath()
+	if _, err = cfg.fs.Stat(cacheDir); errors.Is(err, os.ErrNotExist) {
+		report.add(SeverityWarning, cacheDir, "cache does not yet exist and will be created by the first Get")
+	} else if err != nil {
+		return report, fmt.Errorf("binr Doctor unable to access cache. %w", err)
+	} else if err = doctorCache(cfg, cacheDir, &report); err != nil {
+		return
+	}
+
+	// Namespaces
+	if err = doctorLinks(cfg, cacheDir, &report); err != nil {
+		return
+	}
+
+	// Symlinks (which require a privilege on some systems such as Windows)
+	doctorSymlinks(&report)
+	return report, nil
+}
+
+// doctorCache checks the cache is writable, and for partial downloads,
+// abandoned leases, and objects which fail their checksum or are writable.
+func doctorCache(cfg config, dir string, report *Report) error {
+	probe := filepath.Join(dir, ".doctor."+cfg.tempNamer())
+	if f, err := cfg.fs.OpenFile(probe, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); err != nil {
+		report.add(SeverityError, dir, "cache is not writable: %v", err)
+	} else {
+		f.Close()
+		_ = cfg.fs.Remove(probe)
+	}
+
+	entries, err := cfg.fs.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("binr Doctor unable to read cache. %w", err)
+	}
+	for _, entry := range entries {
+		var (
+			name = entry.Name()
+			path = filepath.Join(dir, name)
+		)
+		switch {
+		case strings.HasSuffix(name, ".partial"):
+			report.add(SeverityWarning, path, "partial download which is either in progress or was abandoned")
+		case strings.HasSuffix(name, ".lease"):
+			if info, err := entry.Info(); err == nil && cfg.clock().Sub(info.ModTime()) > cfg.leaseTimeout {
+				report.add(SeverityWarning, path, "download lease which was abandoned")
+			}
+		case isObjectName(name):
+			expected, _ := objectChecksum(name)
+			sum, err := checksumOf(context.Background(), cfg, path, expected)
+			if err != nil {
+				report.add(SeverityError, path, "cached object can not be read: %v", err)
+			} else if sum != expected {
+				report.add(SeverityError, path, "cached object fails its checksum (calculated %v)", sum)
+			} else if info, err := entry.Info(); err == nil && info.Mode().Perm()&0222 != 0 && !cfg.writableCache {
+				report.add(SeverityWarning, path, "cached object is writable, and may have been modified")
+			}
+		}
+	}
+	return nil
+}
+
+// doctorLinks checks the links of all namespaces for those which dangle.
+func doctorLinks(cfg config, cacheDir string, report *Report) error {
+	root := cfg.rootPath()
+	namespaces, err := cfg.fs.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("binr Doctor unable to read namespaces. %w", err)
+	}
+	for _, ns := range namespaces {
+		dir := filepath.Join(root, ns.Name())
+		if !ns.IsDir() || dir == cacheDir {
+			continue
+		}
+		entries, err := cfg.fs.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("binr Doctor unable to read namespace %v. %w", ns.Name(), err)
+		}
+		for _, entry := range entries {
+			if entry.Type()&os.ModeSymlink == 0 {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if _, err := cfg.fs.Stat(path); err != nil {
+				target, _ := cfg.fs.Readlink(path)
+				report.add(SeverityError, path, "link to %v is dangling. Reinstall the command or see Relink", target)
+			}
+		}
+	}
+	return nil
+}
+
+// doctorSymlinks checks that the current user may create symlinks.
+func doctorSymlinks(report *Report) {
+	dir, err := os.MkdirTemp("", "binr-doctor")
+	if err != nil {
+		report.add(SeverityWarning, "", "unable to check symlinks are permitted: %v", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	if err = os.Symlink("target", filepath.Join(dir, "link")); err != nil {
+		report.add(SeverityError, "", "symlinks are not permitted for the current user (on Windows, enable Developer Mode): %v", err)
+	}
+}
+
+// isObjectName returns true if name is that of an object in the cache.
+func isObjectName(name string) bool {
+	_, ok := objectChecksum(name)
+	return ok
+}
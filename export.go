@@ -0,0 +1,44 @@
+package binr
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Export copies an installed command out of binr's store to destPath,
+// producing a standalone executable which does not depend upon the cache
+// or namespace links.  Version is optional, in which case the command's
+// unversioned link is exported.  The command is verified against its
+// checksum as it is copied, and destPath is replaced if it exists.  Like
+// the store, destPath is within the Filesystem (see WithFilesystem).
+func Export(namespace, command, version, destPath string, options ...option) error {
+	cfg := newConfig(options...)
+	if destPath == "" {
+		return errors.New("binr Export requires a destination path")
+	}
+	path, err := cfg.linkPath(namespace, command, version)
+	if err != nil {
+		return err
+	}
+	target, err := cfg.fs.Readlink(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("binr Export found no command installed at %v", path)
+	} else if err != nil {
+		return fmt.Errorf("binr Export unable to read link %v. %w", path, err)
+	}
+	sum, ok := objectChecksum(filepath.Base(target))
+	if !ok {
+		return fmt.Errorf("binr Export found a link which does not target a cached object: %v -> %v", path, target)
+	} else if !isChecksum(sum) {
+		sum = "" // addressed by another Hasher (see WithHasher), so not verified
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	if err = copyFile(cfg.fs, target, destPath, 0755, sum); err != nil {
+		return fmt.Errorf("binr Export unable to export %v. %w", command, err)
+	}
+	return nil
+}
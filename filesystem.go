@@ -0,0 +1,93 @@
+package binr
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// Filesystem provides the filesystem operations by which binr manages its
+// cache and namespaces.  The default is the filesystem of the operating
+// system (see OSFilesystem).  Errors should wrap those of the os package
+// where applicable, such that, for example, errors.Is(err, os.ErrNotExist)
+// reports a missing file.  See WithFilesystem.
+type Filesystem interface {
+	Open(name string) (File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Stat(name string) (os.FileInfo, error)
+	MkdirAll(path string, perm os.FileMode) error
+	ReadDir(name string) ([]os.DirEntry, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+	Chtimes(name string, atime, mtime time.Time) error
+	Chmod(name string, mode os.FileMode) error
+}
+
+// File is an open file of a Filesystem.
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Closer
+	Stat() (os.FileInfo, error)
+	Sync() error
+}
+
+// OSFilesystem returns the Filesystem of the operating system.
+func OSFilesystem() Filesystem {
+	return osFilesystem{}
+}
+
+type osFilesystem struct{}
+
+func (osFilesystem) Open(name string) (File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osFilesystem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osFilesystem) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+func (osFilesystem) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+
+func (osFilesystem) ReadDir(name string) ([]os.DirEntry, error) { return os.ReadDir(name) }
+
+func (osFilesystem) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+
+func (osFilesystem) Remove(name string) error { return os.Remove(name) }
+
+func (osFilesystem) Symlink(oldname, newname string) error { return os.Symlink(oldname, newname) }
+
+func (osFilesystem) Readlink(name string) (string, error) { return os.Readlink(name) }
+
+func (osFilesystem) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (osFilesystem) Chmod(name string, mode os.FileMode) error { return os.Chmod(name, mode) }
+
+// ensureDir creates the directory at path within fsys, and any parents,
+// unless it exists.  A directory created concurrently by another goroutine
+// or process is not an error, regardless of the Filesystem.
+func ensureDir(fsys Filesystem, path string) error {
+	err := fsys.MkdirAll(path, os.ModePerm)
+	if err == nil {
+		return nil
+	}
+	if info, statErr := fsys.Stat(path); statErr == nil && info.IsDir() {
+		return nil
+	}
+	return err
+}
@@ -3,9 +3,14 @@ module github.com/lkingland/binr
 go 1.20
 
 require (
-	github.com/Masterminds/semver v1.5.0 // indirect
+	github.com/Masterminds/semver v1.5.0
+	github.com/rs/zerolog v1.29.1
+	github.com/ulikunitz/xz v0.5.17
+	golang.org/x/time v0.5.0
+)
+
+require (
 	github.com/mattn/go-colorable v0.1.12 // indirect
 	github.com/mattn/go-isatty v0.0.14 // indirect
-	github.com/rs/zerolog v1.29.1 // indirect
 	golang.org/x/sys v0.0.0-20210927094055-39ccf1dd6fa6 // indirect
 )
@@ -0,0 +1,54 @@
+package binr
+
+import (
+	"crypto/sha256"
+	"hash"
+	"sync"
+	"time"
+)
+
+// Hasher calculates the checksums by which commands are addressed in the
+// cache.  See WithHasher.
+type Hasher interface {
+	// Algorithm names the hash.  Checksums calculated by the hasher are
+	// named by it, as <algorithm>-<hex digest>, and it should therefore be
+	// short, lowercase and alphanumeric (such as "xxh3").
+	Algorithm() string
+
+	// New returns a new hash.
+	New() hash.Hash
+}
+
+// sha256Hasher is the default Hasher.
+type sha256Hasher struct{}
+
+func (sha256Hasher) Algorithm() string { return "sha256" }
+
+func (sha256Hasher) New() hash.Hash { return sha256.New() }
+
+// hashed is a tally of the files hashed by a call, such that its hashing
+// throughput may be reported (see Result).
+type hashed struct {
+	mu       sync.Mutex
+	bytes    int64
+	duration time.Duration
+}
+
+func (h *hashed) add(bytes int64, duration time.Duration) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.bytes += bytes
+	h.duration += duration
+}
+
+func (h *hashed) total() (bytes int64, duration time.Duration) {
+	if h == nil {
+		return 0, 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.bytes, h.duration
+}
@@ -0,0 +1,312 @@
+package binr
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrHostNotAllowed is returned (wrapped) when a URL's host is not among
+// those allowed.  See WithAllowedHosts.
+var ErrHostNotAllowed = errors.New("binr host not allowed")
+
+// request the given URL, applying the request policy of the config.  All
+// HTTP requests made by binr are made via request.  The header, if not nil,
+// is added to the request.
+func request(ctx context.Context, cfg config, method, rawURL string, header http.Header) (*http.Response, error) {
+	if cfg.urlRewriter != nil {
+		original := rawURL
+		rawURL = cfg.urlRewriter(rawURL)
+		cfg.log.Debug().
+			Str("url", redact(original)).
+			Str("rewritten", redact(rawURL)).
+			Msg("binr rewrote URL")
+	}
+	if err := checkScheme(cfg, rawURL); err != nil {
+		return nil, err
+	}
+	if err := checkHost(cfg, rawURL); err != nil {
+		return nil, err
+	}
+	if err := waitForLimiter(ctx, cfg, rawURL); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	var credential string // header key of the credentials, if any
+	if cfg.credentialProvider != nil {
+		key, value, err := cfg.credentialProvider(ctx, rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("binr unable to obtain credentials to request %q. %w", redact(rawURL), err)
+		}
+		if key != "" {
+			req.Header.Set(key, value)
+			credential = key
+		}
+	}
+	if cfg.userAgent != "" {
+		req.Header.Set("User-Agent", cfg.userAgent)
+	}
+	client := http.DefaultClient
+	if cfg.httpClient != nil {
+		client = cfg.httpClient
+	}
+	if credential != "" {
+		client = withholdCredentials(client, credential)
+	}
+	if cfg.certificatePin != "" {
+		if req.URL.Scheme != "https" {
+			return nil, fmt.Errorf("binr refusing to request %q as a certificate pin requires https. %w", redact(rawURL), ErrCertificatePin)
+		}
+		if client, err = pinnedClient(client, cfg.certificatePin); err != nil {
+			return nil, err
+		}
+	}
+	if !cfg.allowInsecureHTTP {
+		client = checkRedirects(client, func(u *url.URL) error { return checkScheme(cfg, u.String()) })
+	}
+	return client.Do(req)
+}
+
+// ErrInsecureURL is returned (wrapped) when a URL to be requested uses
+// plain http.  See WithAllowInsecureHTTP.
+var ErrInsecureURL = errors.New("binr refusing insecure http URL")
+
+// checkScheme returns an error if the given URL uses plain http, unless
+// allowed by the config or its host is the loopback interface.
+func checkScheme(cfg config, rawURL string) error {
+	if cfg.allowInsecureHTTP {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("binr unable to parse URL %q. %w", rawURL, err)
+	}
+	if !strings.EqualFold(u.Scheme, "http") || isLoopback(u.Hostname()) {
+		return nil
+	}
+	return fmt.Errorf("binr refusing to request %q as it is not https. See WithAllowInsecureHTTP. %w", redact(rawURL), ErrInsecureURL)
+}
+
+// isLoopback returns true if host names the loopback interface.
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkRedirects returns a copy of the client which additionally refuses to
+// follow redirects to URLs for which check returns an error.
+func checkRedirects(client *http.Client, check func(*url.URL) error) *http.Client {
+	checked := *client
+	checked.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := check(req.URL); err != nil {
+			return err
+		}
+		if client.CheckRedirect != nil {
+			return client.CheckRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &checked
+}
+
+// withholdCredentials returns a copy of the client which removes the header
+// of the given key from requests redirected to a host other than that of
+// the original request, such that credentials minted for one host are not
+// disclosed to another.
+func withholdCredentials(client *http.Client, key string) *http.Client {
+	withheld := *client
+	withheld.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+			req.Header.Del(key)
+		}
+		if client.CheckRedirect != nil {
+			return client.CheckRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &withheld
+}
+
+// ErrCertificatePin is returned (wrapped) when a host does not present the
+// pinned certificate.  See WithCertificatePin.
+var ErrCertificatePin = errors.New("binr certificate pin not matched")
+
+// pinnedClient returns a copy of the client which accepts only connections
+// presenting a certificate matching the pin: the hex-encoded sha256 of
+// either the certificate or its public key (SubjectPublicKeyInfo).  This is
+// in addition to the verification of its chain.  Keep-alives are disabled,
+// as the client is not reused.
+func pinnedClient(client *http.Client, pin string) (*http.Client, error) {
+	var transport *http.Transport
+	switch t := client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return nil, fmt.Errorf("binr requires the HTTP client's transport be an *http.Transport to verify a certificate pin, not %T", t)
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	verify := transport.TLSClientConfig.VerifyConnection
+	transport.TLSClientConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(state); err != nil {
+				return err
+			}
+		}
+		for _, cert := range state.PeerCertificates {
+			certSum, keySum := sha256.Sum256(cert.Raw), sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			if strings.EqualFold(hex.EncodeToString(certSum[:]), pin) || strings.EqualFold(hex.EncodeToString(keySum[:]), pin) {
+				return nil
+			}
+		}
+		return fmt.Errorf("binr found no certificate presented by %v matches the pin %v. %w", state.ServerName, pin, ErrCertificatePin)
+	}
+	transport.DisableKeepAlives = true
+
+	pinned := *client
+	pinned.Transport = transport
+	return checkRedirects(&pinned, func(u *url.URL) error {
+		if u.Scheme != "https" {
+			return fmt.Errorf("binr refusing redirect to %q as a certificate pin requires https. %w", redact(u.String()), ErrCertificatePin)
+		}
+		return nil
+	}), nil
+}
+
+// redact the password, if any, from the given URL for logging.
+func redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "<unparseable URL>"
+	}
+	return u.Redacted()
+}
+
+// checkHost returns an error if the host of the given URL is not allowed.
+func checkHost(cfg config, rawURL string) error {
+	if len(cfg.allowedHosts) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("binr unable to parse URL %q. %w", rawURL, err)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range cfg.allowedHosts {
+		if hostAllowed(host, strings.ToLower(allowed)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("binr refusing to request %q as its host %q is not one of the allowed hosts %v. %w", rawURL, host, cfg.allowedHosts, ErrHostNotAllowed)
+}
+
+// hostAllowed returns true if the host matches the allowed pattern, which
+// is either an exact host or a wildcard matching any subdomain.
+func hostAllowed(host, allowed string) bool {
+	if domain, ok := strings.CutPrefix(allowed, "*."); ok {
+		return strings.HasSuffix(host, "."+domain)
+	}
+	return host == allowed
+}
+
+// hostLimiter is a rate limiter applied to requests to matching hosts.
+type hostLimiter struct {
+	host    string
+	limiter *rate.Limiter
+}
+
+// waitForLimiter waits until the rate limiter of the first host matching the
+// given URL permits a request.  Requests to hosts without a limiter are not
+// delayed.
+func waitForLimiter(ctx context.Context, cfg config, rawURL string) error {
+	if len(cfg.limiters) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("binr unable to parse URL %q. %w", rawURL, err)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, l := range cfg.limiters {
+		if !hostAllowed(host, strings.ToLower(l.host)) {
+			continue
+		}
+		cfg.log.Debug().Str("host", host).Msg("binr waiting for rate limiter")
+		if err = l.limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("binr rate limited request to %q was not permitted. %w", rawURL, err)
+		}
+		return nil
+	}
+	return nil
+}
+
+// maxThrottleBurst is the most a throttled reader reads at once, such that
+// throughput is smooth at high limits.
+const maxThrottleBurst = 32 * 1024
+
+// throttledReader limits the rate at which an underlying reader is read.
+type throttledReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+// newThrottledReader returns a reader of r limited to bytesPerSec.  Reads
+// are no larger than the limiter's burst, such that even very low limits
+// make progress, and waiting stops when the context is done.
+func newThrottledReader(ctx context.Context, r io.Reader, bytesPerSec int64) io.Reader {
+	burst := int(bytesPerSec)
+	if bytesPerSec > maxThrottleBurst {
+		burst = maxThrottleBurst
+	}
+	return &throttledReader{
+		ctx:     ctx,
+		r:       r,
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSec), burst),
+	}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > t.limiter.Burst() {
+		p = p[:t.limiter.Burst()]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if werr := t.limiter.WaitN(t.ctx, n); werr != nil {
+			if cerr := t.ctx.Err(); cerr != nil {
+				return n, cerr
+			}
+			// The limiter refuses to wait beyond the context's deadline.
+			return n, fmt.Errorf("binr download can not complete within the context deadline at the maximum bandwidth. %w", context.DeadlineExceeded)
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,101 @@
+package binr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultLeaseTimeout is the default time after which a download lease which
+// has not been renewed is considered abandoned.  See WithLeaseTimeout.
+const DefaultLeaseTimeout = 2 * time.Minute
+
+// leasePollInterval is how often a process waiting on another's download
+// lease checks if it has been released.
+var leasePollInterval = 100 * time.Millisecond
+
+// minLeaseRenewal is the shortest interval at which a lease is renewed,
+// such that a very short lease timeout does not renew it continuously.
+const minLeaseRenewal = time.Millisecond
+
+// acquireLease on the download of the object with the given checksum.
+//
+// Only one process at a time may download a given object.  The holder of the
+// lease is the process which succeeds in creating the lease file (named for
+// the checksum) in the cache.  Others wait for the file to be removed, after
+// which they will typically find the object already cached.  The holder
+// renews its lease periodically, such that a lease which has not been
+// renewed within the timeout was abandoned (for example by a process which
+// was killed) and can be taken over.
+//
+// The returned function releases the lease.
+func acquireLease(ctx context.Context, cfg config, checksum string) (release func(), err error) {
+	var (
+		path    = filepath.Join(cfg.cachePath(), checksum+".lease")
+		timeout = cfg.leaseTimeout
+	)
+	for {
+		file, err := cfg.fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(file, "%v\n", os.Getpid())
+			file.Close()
+			return renewLease(cfg, path), nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("binr unable to create download lease. %w", err)
+		}
+
+		if info, err := cfg.fs.Stat(path); err == nil && cfg.clock().Sub(info.ModTime()) > timeout {
+			cfg.warn(nil, path, "binr removing abandoned download lease")
+			if err := cfg.fs.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("binr unable to remove abandoned download lease. %w", err)
+			}
+			continue
+		}
+
+		cfg.log.Debug().Str("checksum", checksum).Msg("binr waiting for download by another process")
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("binr stopped waiting for another process's download. %w", ctx.Err())
+		case <-time.After(leasePollInterval):
+		}
+	}
+}
+
+// renewLease at path until the returned release function is invoked, which
+// also removes the lease.
+func renewLease(cfg config, path string) (release func()) {
+	interval := cfg.leaseTimeout / 2
+	if interval < minLeaseRenewal {
+		interval = minLeaseRenewal
+	}
+	var (
+		ticker = time.NewTicker(interval)
+		stop   = make(chan struct{})
+		done   = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case t := <-ticker.C:
+				if err := cfg.fs.Chtimes(path, t, t); err != nil {
+					cfg.warn(err, path, "binr unable to renew download lease")
+				}
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(stop)
+		<-done
+		if err := cfg.fs.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			cfg.warn(err, path, "binr unable to release download lease")
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package binr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// Versions returns the versions of the command installed in the namespace,
+// newest first by semver precedence.  Only exact versions (vX.Y.Z) are
+// included; floating links and those of platforms other than that given by
+// WithPlatform (if any) are not.
+func Versions(namespace, command string, options ...option) ([]string, error) {
+	return versions(newConfig(options...), namespace, command)
+}
+
+func versions(cfg config, namespace, command string) ([]string, error) {
+	if namespace == "" {
+		return nil, errors.New("binr Versions requires namespace")
+	} else if command == "" {
+		return nil, errors.New("binr Versions requires command")
+	}
+	dir := filepath.Join(cfg.rootPath(), namespace)
+	entries, err := cfg.fs.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("binr unable to read namespace %v. %w", namespace, err)
+	}
+
+	var versions []*semver.Version
+	for _, entry := range entries {
+		suffix, ok := linkVersion(entry.Name(), cfg.linkName(command))
+		if !ok || entry.Type()&os.ModeSymlink == 0 || !exactVersion.MatchString(suffix) {
+			continue
+		}
+		v, err := semver.NewVersion(suffix)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
+
+	list := make([]string, len(versions))
+	for i, v := range versions {
+		list[i] = v.Original()
+	}
+	return list, nil
+}
+
+// Status of an installed command's link.
+type Status string
+
+const (
+	// StatusOK links target an object in the cache.
+	StatusOK Status = "OK"
+	// StatusDangling links target a file which does not exist.
+	StatusDangling Status = "Dangling"
+	// StatusForeign links target a file outside the cache.
+	StatusForeign Status = "Foreign"
+	// StatusCorrupt links target a file in the cache which is not a cached
+	// object, such as a directory or a file not named by its checksum.
+	StatusCorrupt Status = "Corrupt"
+)
+
+// Installed command, as linked in a namespace.
+type Installed struct {
+	// Command name and Version of the link, which may be a floating
+	// version (vX, vX.Y).  Version is empty for the unversioned link (the
+	// command's current version).
+	Command, Version string
+
+	// Path of the link, and its Target (absolute).
+	Path, Target string
+
+	// Checksum of the command, by which the object targeted is named.
+	Checksum string
+
+	// Status of the link.
+	Status Status
+}
+
+// List the commands installed in the namespace, including both their
+// versioned and unversioned links, ordered by name.  Each link is resolved
+// and its Status reported, such that damage to the store is surfaced.
+func List(namespace string, options ...option) ([]Installed, error) {
+	cfg := newConfig(options...)
+	if namespace == "" {
+		return nil, errors.New("binr List requires namespace")
+	}
+	dir := filepath.Join(cfg.rootPath(), namespace)
+	entries, err := cfg.fs.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("binr unable to read namespace %v. %w", namespace, err)
+	}
+	var list []Installed
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		command, version := splitLinkName(entry.Name())
+		i, err := installed(cfg, filepath.Join(dir, entry.Name()), command, version)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, i)
+	}
+	return list, nil
+}
+
+// Current returns the current (unversioned) installation of the command in
+// the namespace.  Its Version is that of the newest versioned link to the
+// same object, if any.
+func Current(namespace, command string, options ...option) (Installed, error) {
+	cfg := newConfig(options...)
+	name := cfg.linkName(command)
+	path, err := cfg.linkPath(namespace, name, "")
+	if err != nil {
+		return Installed{}, err
+	}
+	if _, err = cfg.fs.Readlink(path); errors.Is(err, os.ErrNotExist) {
+		return Installed{}, fmt.Errorf("binr found no current installation of %v in %v", command, namespace)
+	}
+	current, err := installed(cfg, path, command, "")
+	if err != nil {
+		return current, err
+	}
+	current.Version = linkedVersion(cfg, namespace, command, path)
+	return current, nil
+}
+
+// linkedVersion returns the newest exact version of the command in the
+// namespace whose link targets the same object as the link at path, or
+// the empty string if there is none.
+func linkedVersion(cfg config, namespace, command, path string) string {
+	target, err := cfg.fs.Readlink(path)
+	if err != nil {
+		return ""
+	}
+	versions, err := versions(cfg, namespace, command)
+	if err != nil {
+		return ""
+	}
+	for _, v := range versions {
+		vpath, err := cfg.linkPath(namespace, cfg.linkName(command), v)
+		if err != nil {
+			return ""
+		}
+		if vtarget, err := cfg.fs.Readlink(vpath); err == nil && filepath.Base(vtarget) == filepath.Base(target) {
+			return v
+		}
+	}
+	return ""
+}
+
+// Checksum returns the checksum of the given version of the command
+// installed in the namespace (or of its current version if version is
+// empty), which is the name of the object its link targets.  The object is
+// not hashed unless WithVerifyCached is provided, in which case an error is
+// returned if it does not match.  Links which are not to an object in the
+// cache, such as those dangling, are an error.
+func Checksum(namespace, command, version string, options ...option) (string, error) {
+	cfg := newConfig(options...)
+	if namespace == "" {
+		return "", errors.New("binr Checksum requires namespace")
+	} else if command == "" {
+		return "", errors.New("binr Checksum requires command")
+	}
+	path, err := cfg.linkPath(namespace, cfg.linkName(command), version)
+	if err != nil {
+		return "", err
+	}
+	if _, err = cfg.fs.Readlink(path); errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("binr found no installation of %v %v in %v", command, version, namespace)
+	}
+	i, err := installed(cfg, path, command, version)
+	if err != nil {
+		return "", err
+	}
+	if i.Status != StatusOK {
+		return "", fmt.Errorf("binr found link %v is %v (targets %v)", path, i.Status, i.Target)
+	}
+	if cfg.verifyCached {
+		if err = verifyObject(context.Background(), cfg, i.Target, i.Checksum); err != nil {
+			return "", err
+		}
+	}
+	return i.Checksum, nil
+}
+
+// installed returns the installation at the link path, resolving its
+// target and determining its status.
+func installed(cfg config, path, command, version string) (i Installed, err error) {
+	i = Installed{Command: command, Version: version, Path: path}
+	target, err := cfg.fs.Readlink(path)
+	if err != nil {
+		return i, fmt.Errorf("binr unable to read link %v. %w", path, err)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	if _, err = resolveLink(cfg.fs, path); err != nil {
+		return i, err
+	}
+	i.Target = filepath.Clean(target)
+	sum, isObject := objectChecksum(filepath.Base(i.Target))
+	if i.Checksum = sum; !isObject {
+		i.Checksum = filepath.Base(i.Target)
+	}
+
+	info, err := cfg.fs.Stat(i.Target)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		i.Status = StatusDangling
+	case err != nil:
+		return i, fmt.Errorf("binr unable to read target of link %v. %w", path, err)
+	case filepath.Dir(i.Target) != cfg.cachePath():
+		i.Status = StatusForeign
+	case !info.Mode().IsRegular() || !isObject:
+		i.Status = StatusCorrupt
+	default:
+		i.Status = StatusOK
+	}
+	return i, nil
+}
+
+// ErrSymlinkLoop is returned (wrapped) when a link in the store resolves,
+// directly or via other links, to itself.
+var ErrSymlinkLoop = errors.New("binr symlink loop detected")
+
+// maxLinkDepth is the number of links resolveLink follows before giving up,
+// as does the operating system (ELOOP).
+const maxLinkDepth = 40
+
+// resolveLink follows the link at path, and any links it targets in turn,
+// returning the path of the file ultimately targeted, which may not exist.
+// Resolution is bounded, such that a loop results in ErrSymlinkLoop rather
+// than an opaque error from the operating system.
+func resolveLink(fsys Filesystem, path string) (string, error) {
+	link, seen := path, map[string]bool{}
+	for depth := 0; depth < maxLinkDepth; depth++ {
+		if seen[path] {
+			return "", fmt.Errorf("binr found link %v loops via %v. %w", link, path, ErrSymlinkLoop)
+		}
+		seen[path] = true
+		target, err := fsys.Readlink(path)
+		if err != nil {
+			return path, nil // not a link
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = filepath.Clean(target)
+	}
+	return "", fmt.Errorf("binr found link %v exceeds %v levels of links. %w", link, maxLinkDepth, ErrSymlinkLoop)
+}
+
+// splitLinkName into the command and version it links, which may be a
+// floating version (vX, vX.Y).  The version is empty for unversioned links.
+// A floating version is recognized only with its "v" prefix, as otherwise
+// the arch of a platform-qualified link (mytool-linux-386) would be taken
+// for one.  Where the command is known, use linkVersion.
+func splitLinkName(name string) (command, version string) {
+	for i := strings.Index(name, "-"); i > 0; {
+		suffix := name[i+1:]
+		if exactVersion.MatchString(suffix) || (strings.HasPrefix(suffix, "v") && floatingVersion.MatchString(suffix)) {
+			if _, err := semver.NewVersion(suffix); err == nil {
+				return name[:i], suffix
+			}
+		}
+		next := strings.Index(name[i+1:], "-")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return name, ""
+}
+
+// linkVersion returns the version linked by the link of the given name if
+// it is a link of the command (as named by linkName), which may be a
+// floating version and is empty for the command's unversioned link.  ok is
+// false if it is a link of another command.
+func linkVersion(name, command string) (version string, ok bool) {
+	if name == command {
+		return "", true
+	}
+	suffix, ok := strings.CutPrefix(name, command+"-")
+	if !ok || !(exactVersion.MatchString(suffix) || floatingVersion.MatchString(suffix)) {
+		return "", false
+	}
+	if _, err := semver.NewVersion(suffix); err != nil {
+		return "", false
+	}
+	return suffix, true
+}
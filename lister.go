@@ -0,0 +1,122 @@
+package binr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/Masterminds/semver"
+)
+
+// Lister lists the versions of a command which are available.
+type Lister interface {
+	// List the available versions, newest first.
+	List(context.Context) ([]string, error)
+}
+
+// ErrListingUnsupported is returned by Available when the source is unable
+// to enumerate its versions.
+var ErrListingUnsupported = errors.New("binr source does not support listing")
+
+// Available returns the versions which the source is able to install,
+// newest first, for presenting a choice of version.  The source must also
+// implement Lister, such as a SourceProvider which is also a Lister.  A
+// plain Source can not be enumerated, and ErrListingUnsupported is returned.
+// Versions listed which are not exact (vX.Y.Z) are omitted.
+func Available(ctx context.Context, source SourceProvider) ([]string, error) {
+	lister, ok := source.(Lister)
+	if !ok {
+		return nil, ErrListingUnsupported
+	}
+	releases, err := lister.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("binr unable to list the releases available. %w", err)
+	}
+	var versions []*semver.Version
+	for _, release := range releases {
+		if !exactVersion.MatchString(release) {
+			continue
+		}
+		if v, err := semver.NewVersion(release); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
+
+	list := make([]string, len(versions))
+	for i, v := range versions {
+		list[i] = v.Original()
+	}
+	return list, nil
+}
+
+// HTMLDirLister returns a Lister which reads the versions available from a
+// plain directory listing such as those served by Apache or nginx.  The
+// page at indexURL is matched against linkPattern, and the first
+// subexpression of each match (or the entire match if it has none) is
+// taken to be a version.  Matches which are not valid semver are ignored.
+// The options apply to the request of the index as they would to a
+// download, such as WithHTTPClient, WithAllowedHosts and
+// WithCredentialProvider.
+//
+// For example, to list the versions of an index linking to v1.2.3/:
+//
+//	HTMLDirLister("https://example.com/mytool/",
+//		regexp.MustCompile(`href="(v[0-9][^/"]*)/?"`))
+func HTMLDirLister(indexURL string, linkPattern *regexp.Regexp, options ...option) Lister {
+	return htmlDirLister{indexURL: indexURL, pattern: linkPattern, cfg: newConfig(options...)}
+}
+
+type htmlDirLister struct {
+	indexURL string
+	pattern  *regexp.Regexp
+	cfg      config
+}
+
+func (l htmlDirLister) List(ctx context.Context) ([]string, error) {
+	res, err := request(ctx, l.cfg, http.MethodGet, l.indexURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("binr unable to request version index. %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("binr version index %v. %w", l.indexURL, ErrNotFound)
+	} else if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("binr version index request returned status %v", res.Status)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("binr unable to read version index. %w", err)
+	}
+
+	var (
+		seen     = map[string]bool{}
+		versions []*semver.Version
+	)
+	for _, m := range l.pattern.FindAllSubmatch(body, -1) {
+		s := string(m[0])
+		if len(m) > 1 {
+			s = string(m[1])
+		}
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		v, err := semver.NewVersion(s)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
+
+	list := make([]string, len(versions))
+	for i, v := range versions {
+		list[i] = v.Original()
+	}
+	return list, nil
+}
@@ -0,0 +1,169 @@
+package binr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// The cache is protected by a shared/exclusive lock, such that objects and
+// partial downloads are never removed by Prune while an install may be
+// about to link them.  Installs (Get) hold the shared lock for their
+// duration, and Prune the exclusive lock.
+//
+// As a Filesystem offers no locks, both are files in the cache.  Each
+// holder of the shared lock creates its own reader file (.reader), and the
+// holder of the exclusive lock creates the lock file (named cacheLockName).
+// A reader which finds the lock file after creating its reader file
+// withdraws and waits, and the writer, having created the lock file, waits
+// for all reader files to be removed.  The two therefore never proceed
+// together.  As with download leases (see acquireLease), each file is
+// renewed while held, and is considered abandoned if it has not been
+// renewed within the lease timeout.
+
+// cacheLockName is the name of the cache's exclusive lock file.
+const cacheLockName = "gc.lock"
+
+// readerSuffix is the extension of the files of holders of the shared lock.
+const readerSuffix = ".reader"
+
+// readers is a count of the shared locks taken by this process, by which
+// their reader files are uniquely named.
+var readers atomic.Int64
+
+// readLock acquires a shared lock on the cache, waiting while the
+// exclusive lock is held.  The returned function releases the lock.  A
+// cache which is not writable, such as one baked into an image or owned by
+// another user, can be neither pruned nor installed to, and so is read
+// without a lock.
+func readLock(ctx context.Context, cfg config) (unlock func(), err error) {
+	var (
+		lock = filepath.Join(cfg.cachePath(), cacheLockName)
+		path = filepath.Join(cfg.cachePath(), fmt.Sprintf("%d-%d%v", os.Getpid(), readers.Add(1), readerSuffix))
+	)
+	for {
+		if err = waitForUnlocked(ctx, cfg, lock); err != nil {
+			return nil, err
+		}
+		file, err := cfg.fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if readOnly(err) {
+			cfg.log.Debug().Err(err).Msg("binr reading cache which is not writable without a lock")
+			return func() {}, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("binr unable to create cache reader lock. %w", err)
+		}
+		file.Close()
+		if _, err = cfg.fs.Stat(lock); errors.Is(err, os.ErrNotExist) {
+			return renewLease(cfg, path), nil
+		}
+		// Withdraw in favor of the holder of the exclusive lock.
+		if err = cfg.fs.Remove(path); err != nil {
+			return nil, fmt.Errorf("binr unable to remove cache reader lock. %w", err)
+		}
+	}
+}
+
+// readOnly returns true if the error is that of writing to a filesystem or
+// directory which is not writable.
+func readOnly(err error) bool {
+	return errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EROFS)
+}
+
+// writeLock acquires the exclusive lock on the cache, waiting for the
+// holders of the shared lock to release it.  The returned function
+// releases the lock.
+func writeLock(ctx context.Context, cfg config) (unlock func(), err error) {
+	lock := filepath.Join(cfg.cachePath(), cacheLockName)
+	for {
+		file, err := cfg.fs.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(file, "%v\n", os.Getpid())
+			file.Close()
+			break
+		} else if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("binr unable to create cache lock. %w", err)
+		}
+		if err = waitForUnlocked(ctx, cfg, lock); err != nil {
+			return nil, err
+		}
+	}
+	unlock = renewLease(cfg, lock)
+
+	for {
+		held, err := readersHolding(cfg)
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		if held == 0 {
+			return unlock, nil
+		}
+		cfg.log.Debug().Int("readers", held).Msg("binr waiting for installs in progress")
+		select {
+		case <-ctx.Done():
+			unlock()
+			return nil, fmt.Errorf("binr stopped waiting for installs in progress. %w", ctx.Err())
+		case <-time.After(leasePollInterval):
+		}
+	}
+}
+
+// waitForUnlocked waits until the lock file at path does not exist,
+// removing it if it was abandoned.
+func waitForUnlocked(ctx context.Context, cfg config, path string) error {
+	for {
+		info, err := cfg.fs.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("binr unable to read cache lock. %w", err)
+		}
+		if cfg.clock().Sub(info.ModTime()) > cfg.leaseTimeout {
+			cfg.warn(nil, path, "binr removing abandoned cache lock")
+			if err := cfg.fs.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("binr unable to remove abandoned cache lock. %w", err)
+			}
+			return nil
+		}
+		cfg.log.Debug().Msg("binr waiting for cache lock")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("binr stopped waiting for cache lock. %w", ctx.Err())
+		case <-time.After(leasePollInterval):
+		}
+	}
+}
+
+// readersHolding returns the number of holders of the shared lock, removing
+// the reader files of those which were abandoned.
+func readersHolding(cfg config) (n int, err error) {
+	entries, err := cfg.fs.ReadDir(cfg.cachePath())
+	if err != nil {
+		return 0, fmt.Errorf("binr unable to read cache. %w", err)
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), readerSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // released
+		}
+		if cfg.clock().Sub(info.ModTime()) > cfg.leaseTimeout {
+			path := filepath.Join(cfg.cachePath(), entry.Name())
+			cfg.warn(nil, path, "binr removing abandoned cache reader lock")
+			if err := cfg.fs.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return 0, fmt.Errorf("binr unable to remove abandoned cache reader lock. %w", err)
+			}
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
@@ -1,6 +1,13 @@
 package binr
 
-import "github.com/rs/zerolog"
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
 
 func init() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
@@ -21,3 +28,68 @@ const (
 func SetLogLevel(l logLevel) {
 	zerolog.SetGlobalLevel(zerolog.Level(l))
 }
+
+// trace adds the trace ID extracted from the context (if any) to the
+// config's logger, returning the ID.  See WithTraceExtractor.
+func (c *config) trace(ctx context.Context) (id string) {
+	if c.traceExtractor == nil {
+		return
+	}
+	if id = c.traceExtractor(ctx); id != "" {
+		c.log = c.log.With().Str("trace_id", id).Logger()
+	}
+	return
+}
+
+// warnings encountered by a call, which are reported in its Result such
+// that callers may surface them regardless of logging.  A nil *warnings
+// records nothing.
+type warnings struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (w *warnings) add(message string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.messages = append(w.messages, message)
+}
+
+func (w *warnings) list() []string {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.messages...)
+}
+
+// warn logs the message as a warning with the path and error, each if
+// provided, and records it among the config's warnings.
+func (c config) warn(err error, path, msg string) {
+	event := c.log.Warn()
+	if path != "" {
+		event = event.Str("path", path)
+	}
+	event.Err(err).Msg(msg)
+
+	msg = strings.TrimSuffix(msg, ".")
+	if path != "" {
+		msg += fmt.Sprintf(" (%v)", path)
+	}
+	if err != nil {
+		msg += ". " + err.Error()
+	}
+	c.warnings.add(msg)
+}
+
+// traceError includes the trace ID in the error, if there is one of each.
+func traceError(err error, id string) error {
+	if err == nil || id == "" {
+		return err
+	}
+	return fmt.Errorf("%w (trace_id %v)", err, id)
+}
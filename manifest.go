@@ -0,0 +1,119 @@
+package binr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// manifestConcurrency is the number of commands of a manifest which are
+// prefetched at once.
+const manifestConcurrency = 4
+
+// ManifestEntry is a command listed by a manifest (see PrefetchManifest).
+// URL and Checksum are templates as accepted by TemplateSource.
+type ManifestEntry struct {
+	Command  string `json:"command"`
+	Version  string `json:"version"`
+	URL      string `json:"url"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// Manifest of the commands of a toolchain.
+type Manifest struct {
+	Commands []ManifestEntry `json:"commands"`
+}
+
+// PrefetchManifest fetches every command listed by the JSON manifest at
+// path into the cache, without installing them into a namespace, such as
+// to warm a shared cache before going offline.  For example:
+//
+//	{"commands": [
+//	  {"command": "mytool", "version": "v1.2.3",
+//	   "url": "https://example.com/{{.Version}}/mytool_{{.OS}}_{{.Arch}}",
+//	   "checksum": "https://example.com/{{.Version}}/SHA256SUMS"}
+//	]}
+//
+// Commands are fetched concurrently, and each which fails does not prevent
+// the others.  The errors of all which failed are returned together.
+func PrefetchManifest(ctx context.Context, path string, options ...option) error {
+	cfg := newConfig(options...)
+	manifest, err := readManifest(cfg.fs, path)
+	if err != nil {
+		return err
+	}
+	if err = setup(ctx, cfg); err != nil {
+		return err
+	}
+	unlock, err := readLock(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, manifestConcurrency)
+		errs = make([]error, len(manifest.Commands))
+	)
+	for i, entry := range manifest.Commands {
+		wg.Add(1)
+		go func(i int, entry ManifestEntry) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := prefetch(ctx, cfg, entry); err != nil {
+				errs[i] = fmt.Errorf("binr unable to prefetch %v %v. %w", entry.Command, entry.Version, err)
+			}
+		}(i, entry)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// prefetch the command of the manifest entry into the cache.
+func prefetch(ctx context.Context, cfg config, entry ManifestEntry) error {
+	source, err := TemplateSource(entry.URL, entry.Checksum)
+	if err != nil {
+		return err
+	}
+	res, done, err := fetchForSystem(ctx, cfg, entry.Command, entry.Version, source)
+	if err != nil {
+		return err
+	}
+	defer done()
+	cfg.log.Debug().
+		Str("command", entry.Command).
+		Str("version", entry.Version).
+		Str("checksum", res.Checksum).
+		Msg("binr prefetched command")
+	return nil
+}
+
+// readManifest at path within fsys, validating its entries.
+func readManifest(fsys Filesystem, path string) (manifest Manifest, err error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return manifest, fmt.Errorf("binr unable to open manifest. %w", err)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return manifest, fmt.Errorf("binr unable to read manifest. %w", err)
+	}
+	if err = json.Unmarshal(content, &manifest); err != nil {
+		return manifest, fmt.Errorf("binr unable to parse manifest %v. %w", path, err)
+	}
+	for i, entry := range manifest.Commands {
+		if entry.Command == "" || entry.URL == "" {
+			return manifest, fmt.Errorf("binr manifest %v entry %v requires a command and url", path, i+1)
+		}
+		if kind, err := ClassifyVersion(entry.Version); err != nil || kind != Exact {
+			return manifest, fmt.Errorf("binr manifest %v entry %v (%v) requires version to be a valid semver (ex: v1.2.3)", path, i+1, entry.Command)
+		}
+	}
+	return manifest, nil
+}
@@ -0,0 +1,62 @@
+package binr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxMetadataSize is the most release metadata read, such that a
+// misbehaving host can not exhaust memory.
+const maxMetadataSize = 1 << 20
+
+// fetchMetadata fetches the release metadata of the version for the system
+// and provides it to the config's metadata function.  See WithMetadataFetch.
+// A failure to fetch the metadata is only a warning unless it is required,
+// but an error returned by the metadata function is always returned.
+func fetchMetadata(ctx context.Context, cfg config, version, os, arch string) error {
+	url := cfg.metadataURL(version, os, arch)
+	if url == "" {
+		return nil
+	}
+	meta, err := getMetadata(ctx, cfg, url)
+	if err != nil {
+		if cfg.metadataRequired {
+			return err
+		}
+		cfg.warn(err, "", "binr unable to fetch release metadata")
+		return nil
+	}
+	if cfg.metadataDest == nil {
+		return nil
+	}
+	if err = cfg.metadataDest(meta); err != nil {
+		return fmt.Errorf("binr release metadata rejected. %w", err)
+	}
+	return nil
+}
+
+// getMetadata returns the content at the metadata URL.
+func getMetadata(ctx context.Context, cfg config, url string) ([]byte, error) {
+	if err := checkSourceURL(url); err != nil {
+		return nil, err
+	}
+	res, err := request(ctx, cfg, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("binr was unable to fetch release metadata from url %q. %w", redact(url), err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("binr received an HTTP 404 from metadata URL %q. %w", redact(url), ErrNotFound)
+	} else if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("binr received an HTTP %v from metadata URL %q", res.StatusCode, redact(url))
+	}
+	meta, err := io.ReadAll(io.LimitReader(res.Body, maxMetadataSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("binr received an error reading the metadata URL %q. %w", redact(url), err)
+	} else if len(meta) > maxMetadataSize {
+		return nil, fmt.Errorf("binr found release metadata at %q exceeds %v bytes", redact(url), maxMetadataSize)
+	}
+	return meta, nil
+}
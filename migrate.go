@@ -0,0 +1,148 @@
+package binr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MigrateCache moves the objects of the cache at oldDir to newDir, and
+// updates all links in all namespaces which target them.  This prevents
+// dangling links when the cache location changes (see WithCacheDir).  The
+// default cache location is ~/.config/binr/.cache.
+//
+// Migration copies each object and verifies the copy before relinking, and
+// only then removes the originals, such that an interrupted migration
+// leaves all links working.  It is idempotent, and an interrupted migration
+// can be completed by running it again.  The old cache is locked for the
+// duration, such that the migration waits for installs in progress.
+func MigrateCache(ctx context.Context, oldDir, newDir string, options ...option) (err error) {
+	if oldDir == "" || newDir == "" {
+		return errors.New("binr MigrateCache requires both the old and new cache directories")
+	}
+	if oldDir, err = filepath.Abs(oldDir); err != nil {
+		return
+	}
+	if newDir, err = filepath.Abs(newDir); err != nil {
+		return
+	}
+	if oldDir == newDir {
+		return nil
+	}
+	cfg := newConfig(options...)
+	if _, err = cfg.fs.Stat(oldDir); errors.Is(err, os.ErrNotExist) {
+		cfg.log.Debug().Str("path", oldDir).Msg("binr found no cache to migrate")
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("binr unable to read cache to migrate. %w", err)
+	}
+	if err = migrateObjects(ctx, cfg, oldDir, newDir); err != nil {
+		return
+	}
+	if err = cfg.fs.Remove(oldDir); err != nil {
+		cfg.log.Debug().Err(err).Msg("binr leaving old cache directory in place")
+	}
+	return nil
+}
+
+// migrateObjects of the cache at oldDir to newDir, retargeting links to
+// them, while holding the exclusive lock on the old cache such that a
+// concurrent Get or Prune can not use an object as it is moved.
+func migrateObjects(ctx context.Context, cfg config, oldDir, newDir string) (err error) {
+	old := cfg
+	old.cacheDir = oldDir
+	unlock, err := writeLock(ctx, old)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	entries, err := cfg.fs.ReadDir(oldDir)
+	if err != nil {
+		return fmt.Errorf("binr unable to read cache to migrate. %w", err)
+	}
+	if err = ensureDir(cfg.fs, newDir); err != nil {
+		return fmt.Errorf("binr unable to create new cache directory. %w", err)
+	}
+
+	// Copy then verify
+	var migrated []string
+	for _, entry := range entries {
+		name := entry.Name()
+		sum, ok := objectChecksum(name)
+		if !ok {
+			continue // partial downloads, leases etc.
+		}
+		dst := filepath.Join(newDir, name)
+		if verify(ctx, cfg, dst, sum) != nil {
+			cfg.log.Debug().Str("checksum", sum).Str("to", newDir).Msg("binr migrating object")
+			if err = copyFile(cfg.fs, filepath.Join(oldDir, name), dst, objectMode, ""); err != nil {
+				return
+			}
+			if err = verify(ctx, cfg, dst, sum); err != nil {
+				_ = removeObject(cfg.fs, dst)
+				return fmt.Errorf("binr unable to verify migrated object %v. %w", sum, err)
+			}
+		}
+		migrated = append(migrated, name)
+	}
+
+	// Swap links
+	if err = retarget(cfg, oldDir, newDir); err != nil {
+		return
+	}
+
+	// Remove originals
+	for _, name := range migrated {
+		if err = removeObject(cfg.fs, filepath.Join(oldDir, name)); err != nil {
+			return fmt.Errorf("binr unable to remove migrated object. %w", err)
+		}
+	}
+	return nil
+}
+
+// retarget all links in all namespaces which point to an object in oldDir
+// to the object of the same name in newDir.
+func retarget(cfg config, oldDir, newDir string) error {
+	root := cfg.rootPath()
+	namespaces, err := cfg.fs.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("binr unable to read namespaces. %w", err)
+	}
+	for _, ns := range namespaces {
+		dir := filepath.Join(root, ns.Name())
+		if !ns.IsDir() || dir == oldDir || dir == newDir {
+			continue
+		}
+		entries, err := cfg.fs.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("binr unable to read namespace %v. %w", ns.Name(), err)
+		}
+		for _, entry := range entries {
+			if entry.Type()&os.ModeSymlink == 0 {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			target, err := cfg.fs.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("binr unable to read link %v. %w", path, err)
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(dir, target)
+			}
+			if filepath.Dir(target) != oldDir {
+				continue
+			}
+			newTarget := linkTarget(newDir, path, filepath.Base(target))
+			cfg.log.Debug().Str("path", path).Str("target", newTarget).Msg("binr retargeting link")
+			if err = replaceSymlink(cfg.fs, newTarget, path); err != nil {
+				return fmt.Errorf("binr unable to retarget link %v. %w", path, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package binr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mirrorAlpha is the weight of each new latency sample in a mirror's
+// exponentially-weighted mean latency.  A sample is the time to the
+// headers of a response, which unlike the time to download the whole
+// response does not depend on the size of the command.
+const mirrorAlpha = 0.3
+
+// mirrorFailurePenalty is the latency recorded for a failed download from a
+// mirror, such that unreliable mirrors are ordered after slow ones.
+const mirrorFailurePenalty = 30 * time.Second
+
+// mirrorScore is the measured latency of a mirror.
+type mirrorScore struct {
+	latency float64 // seconds, exponentially weighted
+	samples int
+}
+
+// scoreboard of the mirrors downloaded from by this process, keyed by
+// origin (scheme and host).
+type scoreboard struct {
+	mu     sync.Mutex
+	scores map[string]mirrorScore
+}
+
+// mirrorScores is the process-wide scoreboard, such that the measurements
+// of one call inform the mirror order of the next.
+var mirrorScores = &scoreboard{scores: map[string]mirrorScore{}}
+
+// record the latency of a download from the mirror, or a failure.
+func (s *scoreboard) record(mirror string, latency time.Duration, failed bool) {
+	if failed && latency < mirrorFailurePenalty {
+		latency = mirrorFailurePenalty
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	score := s.scores[mirror]
+	if score.samples == 0 {
+		score.latency = latency.Seconds()
+	} else {
+		score.latency = mirrorAlpha*latency.Seconds() + (1-mirrorAlpha)*score.latency
+	}
+	score.samples++
+	s.scores[mirror] = score
+}
+
+// order the URLs (in place) fastest first by the latency of their mirrors.
+// Mirrors not yet measured are ordered first, such that each is measured
+// once before the order is by latency, and the sort is stable, such that
+// the declared order is kept until then.
+func (s *scoreboard) order(urls []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scores := make([]mirrorScore, len(urls))
+	for i, u := range urls {
+		scores[i] = s.scores[mirrorOrigin(u)]
+	}
+	sort.Stable(byLatency{urls, scores})
+}
+
+type byLatency struct {
+	urls   []string
+	scores []mirrorScore
+}
+
+func (b byLatency) Len() int { return len(b.urls) }
+func (b byLatency) Swap(i, j int) {
+	b.urls[i], b.urls[j] = b.urls[j], b.urls[i]
+	b.scores[i], b.scores[j] = b.scores[j], b.scores[i]
+}
+func (b byLatency) Less(i, j int) bool {
+	if b.scores[i].samples == 0 || b.scores[j].samples == 0 {
+		return b.scores[i].samples < b.scores[j].samples
+	}
+	return b.scores[i].latency < b.scores[j].latency
+}
+
+// mirrorOrigin returns the scheme and host of the URL, by which its mirror
+// is scored.
+func mirrorOrigin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// mirrorURLs returns the URL followed by its equivalent on each of the
+// mirrors of the config, ordered by their latency if adaptive.  The
+// equivalent of a URL on a mirror is the mirror's base URL joined with the
+// URL's path and query.
+func mirrorURLs(cfg config, rawURL string) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("binr unable to parse URL %q. %w", rawURL, err)
+	}
+	urls := []string{rawURL}
+	for _, mirror := range cfg.mirrors {
+		m, err := url.Parse(mirror)
+		if err != nil {
+			return nil, fmt.Errorf("binr unable to parse mirror %q. %w", mirror, err)
+		}
+		m = m.JoinPath(u.Path)
+		m.RawQuery = u.RawQuery
+		urls = append(urls, m.String())
+	}
+	if cfg.adaptiveMirrors {
+		mirrorScores.order(urls)
+	}
+	return urls, nil
+}
+
+// downloadMirrored downloads the command from the URL or, failing that,
+// from each of its mirrors in turn.  See WithMirrors.  The partial of a
+// failed download is removed before the next, unless resuming, as a
+// download may be resumed from any mirror.
+func downloadMirrored(ctx context.Context, cfg config, rawURL, outPath string, resume bool) (r received, err error) {
+	if len(cfg.mirrors) == 0 {
+		return download(ctx, cfg, rawURL, outPath, "application/octet-stream", resume)
+	}
+	urls, err := mirrorURLs(cfg, rawURL)
+	if err != nil {
+		return r, err
+	}
+	var errs []error
+	for _, u := range urls {
+		r, err = download(ctx, cfg, u, outPath, "application/octet-stream", resume)
+		if errors.Is(err, errPartialExists) {
+			return r, err
+		}
+		if ctx.Err() == nil {
+			mirrorScores.record(mirrorOrigin(u), r.latency, err != nil)
+		}
+		if err == nil || ctx.Err() != nil {
+			return r, err
+		}
+		cfg.log.Debug().Err(err).Str("url", redact(u)).Msg("binr mirror failed")
+		errs = append(errs, err)
+		if !resume {
+			removePartial(cfg, outPath)
+		}
+	}
+	return r, fmt.Errorf("binr unable to download the command from any mirror. %w", errors.Join(errs...))
+}
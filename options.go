@@ -0,0 +1,84 @@
+package binr
+
+import (
+	"context"
+	"net/http"
+)
+
+// Options for Get provided as a struct, an alternative to the functional
+// options for callers who prefer one.  Each field corresponds to an
+// option, and is not applied if it is the zero value.
+type Options struct {
+	// CacheDir in which commands are cached.  See WithCacheDir.
+	CacheDir string
+
+	// UserAgent of requests.  See WithUserAgent.
+	UserAgent string
+
+	// HTTPClient by which requests are made.  See WithHTTPClient.
+	HTTPClient *http.Client
+
+	// Filesystem in which commands are cached and linked.  See
+	// WithFilesystem.
+	Filesystem Filesystem
+
+	// Platform for which commands are provisioned.  See WithPlatform.
+	Platform Platform
+
+	// Lister of the command's releases.  See WithLister.
+	Lister Lister
+
+	// Update floating links.  See WithUpdate.
+	Update bool
+
+	// Retries of a failed download.  See WithRetries.
+	Retries int
+
+	// AllowedHosts from which commands may be requested.  See
+	// WithAllowedHosts.
+	AllowedHosts []string
+
+	// NoCache downloads the command to a temporary directory.  See
+	// WithNoCache.
+	NoCache bool
+}
+
+// options returns the functional options equivalent to the struct.
+func (o Options) options() (options []option) {
+	if o.CacheDir != "" {
+		options = append(options, WithCacheDir(o.CacheDir))
+	}
+	if o.UserAgent != "" {
+		options = append(options, WithUserAgent(o.UserAgent))
+	}
+	if o.HTTPClient != nil {
+		options = append(options, WithHTTPClient(o.HTTPClient))
+	}
+	if o.Filesystem != nil {
+		options = append(options, WithFilesystem(o.Filesystem))
+	}
+	if o.Platform != (Platform{}) {
+		options = append(options, WithPlatform(o.Platform.OS, o.Platform.Arch))
+	}
+	if o.Lister != nil {
+		options = append(options, WithLister(o.Lister))
+	}
+	if o.Update {
+		options = append(options, WithUpdate())
+	}
+	if o.Retries != 0 {
+		options = append(options, WithRetries(o.Retries))
+	}
+	if len(o.AllowedHosts) > 0 {
+		options = append(options, WithAllowedHosts(o.AllowedHosts))
+	}
+	if o.NoCache {
+		options = append(options, WithNoCache())
+	}
+	return
+}
+
+// GetWithOptions is Get with its options provided as a struct.
+func GetWithOptions(ctx context.Context, namespace, command, version string, source Source, opts Options) (string, error) {
+	return Get(ctx, namespace, command, version, source, opts.options()...)
+}
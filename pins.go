@@ -0,0 +1,95 @@
+package binr
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// pin locks a command to a version and (optionally) checksum.
+type pin struct {
+	version  string
+	checksum string
+}
+
+// readPins returns the pins of the file at path by namespace/command.  Each
+// line of the file pins one command:
+//
+//	<namespace>/<command> <version> [checksum]
+//
+// The version must be exact (vX.Y.Z).  Blank lines and those beginning
+// with # are ignored.  A file which does not exist contains no pins.
+func readPins(fsys Filesystem, path string) (map[string]pin, error) {
+	file, err := fsys.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("binr unable to open pins file. %w", err)
+	}
+	defer file.Close()
+
+	var (
+		pins    = map[string]pin{}
+		scanner = bufio.NewScanner(file)
+		n       int
+	)
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 || strings.Count(fields[0], "/") != 1 {
+			return nil, fmt.Errorf("binr pins file %v line %v is not of the form \"<namespace>/<command> <version> [checksum]\": %q", path, n, line)
+		}
+		if !exactVersion.MatchString(fields[1]) {
+			return nil, fmt.Errorf("binr pins file %v line %v has an invalid version %q. Expected an exact version (vX.Y.Z)", path, n, fields[1])
+		}
+		p := pin{version: fields[1]}
+		if len(fields) == 3 {
+			if !isChecksum(fields[2]) {
+				return nil, fmt.Errorf("binr pins file %v line %v has an invalid checksum %q. Expected a hex-encoded sha256", path, n, fields[2])
+			}
+			p.checksum = strings.ToLower(fields[2])
+		}
+		pins[fields[0]] = p
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("binr unable to read pins file. %w", err)
+	}
+	return pins, nil
+}
+
+// applyPins returns the version and source to use for the given command,
+// which are those requested unless the command is pinned by the config's
+// pins file.  A pinned checksum is used in place of any provided by the
+// source.
+func applyPins(cfg config, namespace, command, version string, source Source) (string, Source, error) {
+	if cfg.pinsFile == "" {
+		return version, source, nil
+	}
+	pins, err := readPins(cfg.fs, cfg.pinsFile)
+	if err != nil {
+		return "", nil, err
+	}
+	p, ok := pins[namespace+"/"+command]
+	if !ok {
+		return version, source, nil
+	}
+	if p.version != version {
+		if cfg.strictPins {
+			return "", nil, fmt.Errorf("binr requested %v %v, which conflicts with the version pinned in %v: %v", command, version, cfg.pinsFile, p.version)
+		}
+		cfg.log.Debug().
+			Str("requested", version).
+			Str("pinned", p.version).
+			Msg("binr using pinned version")
+	}
+	if p.checksum != "" && source != nil {
+		source = InlineSource(p.checksum, source)
+	}
+	return p.version, source, nil
+}
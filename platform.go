@@ -0,0 +1,165 @@
+package binr
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// elfMachines by GOARCH.
+var elfMachines = map[string]elf.Machine{
+	"386":      elf.EM_386,
+	"amd64":    elf.EM_X86_64,
+	"arm":      elf.EM_ARM,
+	"arm64":    elf.EM_AARCH64,
+	"loong64":  elf.EM_LOONGARCH,
+	"mips":     elf.EM_MIPS,
+	"mipsle":   elf.EM_MIPS,
+	"mips64":   elf.EM_MIPS,
+	"mips64le": elf.EM_MIPS,
+	"ppc64":    elf.EM_PPC64,
+	"ppc64le":  elf.EM_PPC64,
+	"riscv64":  elf.EM_RISCV,
+	"s390x":    elf.EM_S390,
+}
+
+// machoCPUs by GOARCH.
+var machoCPUs = map[string]macho.Cpu{
+	"386":   macho.Cpu386,
+	"amd64": macho.CpuAmd64,
+	"arm":   macho.CpuArm,
+	"arm64": macho.CpuArm64,
+	"ppc64": macho.CpuPpc64,
+}
+
+// peMachines by GOARCH.
+var peMachines = map[string]uint16{
+	"386":   pe.IMAGE_FILE_MACHINE_I386,
+	"amd64": pe.IMAGE_FILE_MACHINE_AMD64,
+	"arm":   pe.IMAGE_FILE_MACHINE_ARMNT,
+	"arm64": pe.IMAGE_FILE_MACHINE_ARM64,
+}
+
+// checkPlatform confirms that the executable at path was built for the
+// given os and arch by inspecting its header: ELF for Linux and most other
+// unix-like systems, Mach-O for Darwin and PE for Windows.  Platforms for
+// which the expected header is not known are not checked.
+func checkPlatform(cfg config, path, goos, goarch string) error {
+	if strings.HasPrefix(goarch, "arm") && goarch != "arm64" {
+		goarch = "arm" // variants such as armv7
+	}
+	switch goos {
+	case "darwin", "ios":
+		return checkMacho(cfg, path, goos, goarch)
+	case "windows":
+		return checkPE(cfg, path, goos, goarch)
+	case "linux", "android", "freebsd", "netbsd", "openbsd", "dragonfly", "solaris", "illumos", "aix":
+		return checkELF(cfg, path, goos, goarch)
+	}
+	cfg.log.Debug().Str("os", goos).Msg("binr has no executable header check for os. skipping")
+	return nil
+}
+
+func checkELF(cfg config, path, goos, goarch string) error {
+	expected, ok := elfMachines[goarch]
+	if !ok {
+		cfg.log.Debug().Str("arch", goarch).Msg("binr has no ELF header check for arch. skipping")
+		return nil
+	}
+	file, err := cfg.fs.Open(path)
+	if err != nil {
+		return fmt.Errorf("binr unable to open command to check its platform. %w", err)
+	}
+	defer file.Close()
+	f, err := elf.NewFile(file)
+	if err != nil {
+		return platformMismatchError(goos, goarch, detectFormat(file))
+	}
+	if f.Machine != expected {
+		return platformMismatchError(goos, goarch, "ELF "+f.Machine.String())
+	}
+	// Architectures available in both byte orders share a machine type.
+	biendian := strings.HasPrefix(goarch, "ppc64") || strings.HasPrefix(goarch, "mips")
+	if biendian && strings.HasSuffix(goarch, "le") != (f.Data == elf.ELFDATA2LSB) {
+		return platformMismatchError(goos, goarch, fmt.Sprintf("ELF %v %v", f.Machine, f.Data))
+	}
+	return nil
+}
+
+func checkMacho(cfg config, path, goos, goarch string) error {
+	expected, ok := machoCPUs[goarch]
+	if !ok {
+		cfg.log.Debug().Str("arch", goarch).Msg("binr has no Mach-O header check for arch. skipping")
+		return nil
+	}
+	file, err := cfg.fs.Open(path)
+	if err != nil {
+		return fmt.Errorf("binr unable to open command to check its platform. %w", err)
+	}
+	defer file.Close()
+	// Universal (fat) binaries need only contain the expected architecture.
+	if fat, err := macho.NewFatFile(file); err == nil {
+		var cpus []string
+		for _, a := range fat.Arches {
+			if a.Cpu == expected {
+				return nil
+			}
+			cpus = append(cpus, a.Cpu.String())
+		}
+		return platformMismatchError(goos, goarch, "Mach-O universal "+strings.Join(cpus, ","))
+	}
+	f, err := macho.NewFile(file)
+	if err != nil {
+		return platformMismatchError(goos, goarch, detectFormat(file))
+	}
+	if f.Cpu != expected {
+		return platformMismatchError(goos, goarch, "Mach-O "+f.Cpu.String())
+	}
+	return nil
+}
+
+func checkPE(cfg config, path, goos, goarch string) error {
+	expected, ok := peMachines[goarch]
+	if !ok {
+		cfg.log.Debug().Str("arch", goarch).Msg("binr has no PE header check for arch. skipping")
+		return nil
+	}
+	file, err := cfg.fs.Open(path)
+	if err != nil {
+		return fmt.Errorf("binr unable to open command to check its platform. %w", err)
+	}
+	defer file.Close()
+	f, err := pe.NewFile(file)
+	if err != nil {
+		return platformMismatchError(goos, goarch, detectFormat(file))
+	}
+	if f.Machine != expected {
+		return platformMismatchError(goos, goarch, fmt.Sprintf("PE machine %#x", f.Machine))
+	}
+	return nil
+}
+
+// detectFormat returns a description of the executable format of the file,
+// for use in error messages when it is not of the expected format.
+func detectFormat(r io.ReaderAt) string {
+	if f, err := elf.NewFile(r); err == nil {
+		return "ELF " + f.Machine.String()
+	}
+	if f, err := macho.NewFile(r); err == nil {
+		return "Mach-O " + f.Cpu.String()
+	}
+	if _, err := macho.NewFatFile(r); err == nil {
+		return "Mach-O universal"
+	}
+	if f, err := pe.NewFile(r); err == nil {
+		return fmt.Sprintf("PE machine %#x", f.Machine)
+	}
+	return "unrecognized format"
+}
+
+func platformMismatchError(goos, goarch, detected string) error {
+	return fmt.Errorf("binr refusing to install a command built for another platform. Expected %v/%v but the download is %v", goos, goarch, detected)
+}
@@ -0,0 +1,129 @@
+package binr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Platform is an operating system and architecture for which a command may
+// be provisioned, as named by GOOS and GOARCH.
+type Platform struct {
+	OS, Arch string
+}
+
+// String returns the platform as os/arch.
+func (p Platform) String() string {
+	return p.OS + "/" + p.Arch
+}
+
+// GetPlatforms is Get for each of the given platforms, such as when
+// building a bundle for several systems.  Each platform's command is linked
+// under a name qualified by its platform (see WithPlatform), and the paths
+// of the links are returned by platform.  Commands are cached by their own
+// checksum, and so are stored once should platforms share a command.
+//
+// The platforms are provisioned in order, stopping at the first which fails.
+// The paths of those provisioned before the failure are returned with its
+// error.
+func GetPlatforms(ctx context.Context, namespace, command, version string, platforms []Platform, source Source, options ...option) (map[Platform]string, error) {
+	if len(platforms) == 0 {
+		return nil, errors.New("binr GetPlatforms requires at least one platform")
+	}
+	paths := make(map[Platform]string, len(platforms))
+	for _, p := range platforms {
+		if p.OS == "" || p.Arch == "" {
+			return paths, fmt.Errorf("binr GetPlatforms requires both the OS and Arch of platform %q", p)
+		}
+		path, err := Get(ctx, namespace, command, version, source, append(options[:len(options):len(options)], WithPlatform(p.OS, p.Arch))...)
+		if err != nil {
+			return paths, fmt.Errorf("binr unable to get %v for %v. %w", command, p, err)
+		}
+		paths[p] = path
+	}
+	return paths, nil
+}
+
+// CheckResult of checking a Source for a platform.  See CheckSource.
+type CheckResult struct {
+	Platform
+
+	// URL of the command, and the HTTP status with which it responded to a
+	// HEAD request (zero if it was not reached).
+	URL    string
+	Status int
+
+	// ChecksumURL and ChecksumStatus are likewise those of the checksum.
+	// Both are empty if the Source provides no checksum, and the status is
+	// zero if the checksum is provided inline rather than by URL.
+	ChecksumURL    string
+	ChecksumStatus int
+
+	// Err is the problem found, or nil if the URLs are reachable.
+	Err error
+}
+
+// OK returns true if no problem was found for the platform.
+func (r CheckResult) OK() bool {
+	return r.Err == nil
+}
+
+// CheckSource confirms the Source provides reachable URLs for the given
+// version on each of the given platforms, such as to catch a typo in a URL
+// template during development.  The command's and checksum's URLs of each
+// platform are requested with HEAD, without downloading them, and the
+// results are returned in the order of the platforms.  Servers which do not
+// support HEAD (responding 405 Method Not Allowed) are assumed to be fine.
+//
+// Problems with individual platforms are reported by their result's Err;
+// an error is returned only if the check could not be made.
+func CheckSource(ctx context.Context, version string, source Source, platforms []Platform, options ...option) ([]CheckResult, error) {
+	if source == nil {
+		return nil, errors.New("binr CheckSource requires a Source")
+	} else if len(platforms) == 0 {
+		return nil, errors.New("binr CheckSource requires at least one platform")
+	}
+	cfg := newConfig(options...)
+	results := make([]CheckResult, 0, len(platforms))
+	for _, p := range platforms {
+		r := CheckResult{Platform: p}
+		r.URL, r.ChecksumURL, r.Err = resolve(cfg, source, version, p.OS, p.Arch)
+		if r.Err != nil {
+			r.Err = fmt.Errorf("binr Source failed for %v. %w", p, r.Err)
+		} else if r.Status, r.Err = checkURL(ctx, cfg, r.URL); r.Err == nil && r.ChecksumURL != "" {
+			if _, inline := decodeChecksum(cfg.checksumEncoding, r.ChecksumURL); !inline {
+				r.ChecksumStatus, r.Err = checkURL(ctx, cfg, r.ChecksumURL)
+			}
+		}
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		cfg.log.Debug().
+			Str("platform", p.String()).
+			Int("status", r.Status).
+			Int("checksumStatus", r.ChecksumStatus).
+			Err(r.Err).
+			Msg("binr checked source")
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// checkURL requests the URL with HEAD, returning its status and an error if
+// it is not reachable.
+func checkURL(ctx context.Context, cfg config, url string) (int, error) {
+	res, err := request(ctx, cfg, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("binr unable to reach %q. %w", redact(url), err)
+	}
+	res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusMethodNotAllowed:
+		return res.StatusCode, nil
+	case http.StatusNotFound:
+		return res.StatusCode, fmt.Errorf("binr received an HTTP 404 from %q. Is the Source's URL correct? %w", redact(url), ErrNotFound)
+	default:
+		return res.StatusCode, fmt.Errorf("binr received an HTTP %v from %q", res.StatusCode, redact(url))
+	}
+}
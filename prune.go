@@ -0,0 +1,90 @@
+package binr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Prune removes from the cache the objects which are not linked by any
+// namespace, such as those of commands removed (see RemoveCommand), and
+// partial downloads which were abandoned, returning the paths removed.
+// Partials retained to be resumed (see WithPersistPartials) are kept.
+//
+// Prune holds the cache's exclusive lock, and so waits for installs in
+// progress (in this or any other process) to complete, and installs wait
+// for Prune.  An object is therefore never removed while being installed.
+func Prune(ctx context.Context, options ...option) (removed []string, err error) {
+	cfg := newConfig(options...)
+	if _, err = cfg.fs.Stat(cfg.cachePath()); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	unlock, err := writeLock(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	linked, err := linkedObjects(cfg, options)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := cfg.fs.ReadDir(cfg.cachePath())
+	if err != nil {
+		return nil, fmt.Errorf("binr unable to read cache. %w", err)
+	}
+	for _, entry := range entries {
+		var (
+			name = entry.Name()
+			path = filepath.Join(cfg.cachePath(), name)
+		)
+		switch {
+		case isObjectName(name) && !linked[name]:
+			err = removeObject(cfg.fs, path)
+		case strings.HasSuffix(name, ".partial") && !isObjectName(strings.TrimSuffix(name, ".partial")):
+			err = cfg.fs.Remove(path)
+		case strings.HasSuffix(name, verifiedSuffix) && !linked[strings.TrimSuffix(name, verifiedSuffix)]:
+			err = cfg.fs.Remove(path)
+		case strings.HasSuffix(name, validatorsSuffix) && orphanedValidators(cfg, path):
+			err = cfg.fs.Remove(path)
+		default:
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("binr unable to prune %v. %w", path, err)
+		}
+		cfg.log.Debug().Str("path", path).Msg("binr pruned")
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// linkedObjects returns the names of the objects in the cache which are
+// targeted by a link in any namespace.
+func linkedObjects(cfg config, options []option) (map[string]bool, error) {
+	root := cfg.rootPath()
+	entries, err := cfg.fs.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("binr unable to read namespaces. %w", err)
+	}
+	linked := map[string]bool{}
+	for _, entry := range entries {
+		dir, _ := filepath.Abs(filepath.Join(root, entry.Name()))
+		if !entry.IsDir() || dir == cfg.cachePath() || entry.Name() == ".cache" {
+			continue
+		}
+		list, err := List(entry.Name(), options...)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range list {
+			if filepath.Dir(i.Target) == cfg.cachePath() {
+				linked[filepath.Base(i.Target)] = true
+			}
+		}
+	}
+	return linked, nil
+}
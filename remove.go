@@ -0,0 +1,51 @@
+package binr
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RemoveCommand uninstalls the command from the namespace entirely,
+// removing the links of all of its versions (including floating versions)
+// and its unversioned link.  Other commands in the namespace and the cache
+// are left intact, such that the command can be reinstalled without
+// downloading (see Relink).  The number of exact version links removed is
+// returned.  Removing a command which is not installed is not an error.
+// With WithPlatform, only the links of the given platform are removed.
+func RemoveCommand(namespace, command string, options ...option) (removed int, err error) {
+	cfg := newConfig(options...)
+	if namespace == "" {
+		return 0, errors.New("binr RemoveCommand requires namespace")
+	} else if command == "" {
+		return 0, errors.New("binr RemoveCommand requires command")
+	}
+	dir := filepath.Join(cfg.rootPath(), namespace)
+	entries, err := cfg.fs.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("binr unable to read namespace %v. %w", namespace, err)
+	}
+
+	name := cfg.linkName(command)
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		version, ok := linkVersion(entry.Name(), name)
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err = cfg.fs.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, fmt.Errorf("binr unable to remove link %v. %w", path, err)
+		}
+		cfg.log.Debug().Str("path", path).Msg("binr removed link")
+		if exactVersion.MatchString(version) {
+			removed++
+		}
+	}
+	return removed, nil
+}
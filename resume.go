@@ -0,0 +1,140 @@
+package binr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// validatorsSuffix is that of the sidecar file recording the validators
+// (ETag and Last-Modified) of the response from which a persisted partial
+// download was written.  See WithResumeVerify.
+const validatorsSuffix = ".validators"
+
+// validators of a response, by which a resource which has changed since a
+// partial download of it was written is detected.
+type validators struct {
+	etag, lastModified string
+}
+
+// responseValidators returns the validators of the response.
+func responseValidators(res *http.Response) validators {
+	return validators{res.Header.Get("ETag"), res.Header.Get("Last-Modified")}
+}
+
+// ifRange returns the value of an If-Range header for a request resuming a
+// download with the validators, which is empty if there is none usable.
+// Weak ETags are not permitted by If-Range.
+func (v validators) ifRange() string {
+	if v.etag != "" && !strings.HasPrefix(v.etag, "W/") {
+		return v.etag
+	}
+	return v.lastModified
+}
+
+// matches returns true if the validators of a response resuming a download
+// are those recorded for it.  Validators not provided by either are not
+// compared.
+func (v validators) matches(res validators) bool {
+	return (v.etag == "" || res.etag == "" || v.etag == res.etag) &&
+		(v.lastModified == "" || res.lastModified == "" || v.lastModified == res.lastModified)
+}
+
+// recordValidators of the response from which the partial at path is
+// written to its sidecar.
+func recordValidators(cfg config, path string, v validators) error {
+	f, err := cfg.fs.OpenFile(path+validatorsSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("binr unable to record the validators of partial download. %w", err)
+	}
+	_, err = fmt.Fprintf(f, "%v\n%v\n", v.etag, v.lastModified)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("binr unable to record the validators of partial download. %w", err)
+	}
+	return nil
+}
+
+// recordedValidators returns the validators recorded for the partial at
+// path.  ok is false if none were recorded, in which case the consistency
+// of the partial can not be established.
+func recordedValidators(cfg config, path string) (v validators, ok bool) {
+	f, err := cfg.fs.Open(path + validatorsSuffix)
+	if err != nil {
+		return v, false
+	}
+	defer f.Close()
+	recorded, err := io.ReadAll(io.LimitReader(f, 1024))
+	if err != nil {
+		return v, false
+	}
+	lines := strings.Split(string(recorded), "\n")
+	if len(lines) < 2 {
+		return v, false
+	}
+	return validators{lines[0], lines[1]}, true
+}
+
+// orphanedValidators returns true if the validators sidecar at path is that
+// of a partial download which no longer exists.
+func orphanedValidators(cfg config, path string) bool {
+	_, err := cfg.fs.Stat(strings.TrimSuffix(path, validatorsSuffix))
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// errInconsistentPartial is returned (wrapped) by checkResume when a partial
+// download is not consistent with the resource being downloaded.
+var errInconsistentPartial = errors.New("partial download is inconsistent with the source")
+
+// checkResume returns an error wrapping errInconsistentPartial if the
+// response to the request resuming a partial download of the given size is
+// not consistent with it: the resource has changed since the partial was
+// written (its validators differ), or the size of the resource reported by
+// the Content-Range and Content-Length does not agree with the partial.
+func checkResume(res *http.Response, offset int64, recorded validators) error {
+	if !recorded.matches(responseValidators(res)) {
+		return fmt.Errorf("binr found the source changed since the partial was written. %w", errInconsistentPartial)
+	}
+	contentRange := res.Header.Get("Content-Range")
+	size, known := rangeSize(contentRange)
+	switch {
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable && size != offset:
+		return fmt.Errorf("binr found a partial of %v bytes when the source reports %q. %w", offset, contentRange, errInconsistentPartial)
+	case res.StatusCode == http.StatusPartialContent && known && size <= offset:
+		return fmt.Errorf("binr found a partial of %v bytes when the source reports %q. %w", offset, contentRange, errInconsistentPartial)
+	case res.StatusCode == http.StatusPartialContent && known && res.ContentLength >= 0 && res.ContentLength != size-offset:
+		return fmt.Errorf("binr received %v bytes to complete a partial of %v bytes when the source reports %q. %w", res.ContentLength, offset, contentRange, errInconsistentPartial)
+	}
+	return nil
+}
+
+// rangeSize returns the complete length of the resource given by the
+// Content-Range header value (bytes 0-99/1000 or bytes */1000).  known is
+// false if the length is not provided (bytes 0-99/*) or the value is invalid.
+func rangeSize(contentRange string) (size int64, known bool) {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok || !strings.HasPrefix(contentRange, "bytes ") {
+		return -1, false
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return -1, false
+	}
+	return size, true
+}
+
+// restartDownload discards the partial download at outPath, which is not
+// consistent with its source, and downloads the url to it from the start.
+func restartDownload(ctx context.Context, cfg config, url, outPath, contentType string) (received, error) {
+	if err := cfg.fs.Remove(outPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return received{}, fmt.Errorf("binr unable to discard partial download. %w", err)
+	}
+	return download(ctx, cfg, url, outPath, contentType, true)
+}
@@ -0,0 +1,90 @@
+// Package sigstore verifies commands downloaded by binr against Sigstore
+// bundles.  It is a separate module such that its dependencies are required
+// only by those who use it:
+//
+//	binr.Get(ctx, "myapp", "mytool", "v1.2.3", source,
+//		binr.WithVerifier(sigstore.Bundle(bundleURL, trustedRoot, identity)))
+//
+// Bundle is a binr.Verifier rather than an option of its own, as binr's
+// options can be declared only by binr itself.
+package sigstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/lkingland/binr"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+// Bundle returns a binr.Verifier which verifies each download against the
+// Sigstore bundle at the URL returned by bundleURL for its version, OS and
+// architecture.  The bundle's certificate must have been issued to the
+// given identity, and its transparency log inclusion proof and timestamps
+// are verified offline against the trusted root.  Verification failure
+// aborts the install.  The bundle is requested, and the download read, with
+// the options of the Get (see binr.VerifierRequest and
+// binr.VerifierFilesystem), such as WithAllowedHosts and WithFilesystem.
+//
+// The options, if any, replace the default requirements of one signed
+// certificate timestamp, one transparency log entry and one observer
+// timestamp, such as for a private Sigstore without a certificate
+// transparency log.
+func Bundle(bundleURL func(version, os, arch string) string, trustedRoot root.TrustedMaterial, identity verify.CertificateIdentity, options ...verify.VerifierOption) binr.Verifier {
+	if len(options) == 0 {
+		options = []verify.VerifierOption{
+			verify.WithSignedCertificateTimestamps(1),
+			verify.WithTransparencyLog(1),
+			verify.WithObserverTimestamps(1),
+		}
+	}
+	return func(ctx context.Context, path, version, goos, goarch string) error {
+		url := bundleURL(version, goos, goarch)
+		b, err := fetchBundle(ctx, url)
+		if err != nil {
+			return err
+		}
+		verifier, err := verify.NewVerifier(trustedRoot, options...)
+		if err != nil {
+			return fmt.Errorf("binr unable to create sigstore verifier. %w", err)
+		}
+		artifact, err := binr.VerifierFilesystem(ctx).Open(path)
+		if err != nil {
+			return fmt.Errorf("binr unable to open download for sigstore verification. %w", err)
+		}
+		defer artifact.Close()
+		policy := verify.NewPolicy(verify.WithArtifact(artifact), verify.WithCertificateIdentity(identity))
+		if _, err = verifier.Verify(b, policy); err != nil {
+			return fmt.Errorf("binr sigstore bundle %q does not verify the download. %w", url, err)
+		}
+		return nil
+	}
+}
+
+// fetchBundle at the given URL, with the request policy of the Get.
+func fetchBundle(ctx context.Context, url string) (*bundle.Bundle, error) {
+	res, err := binr.VerifierRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return nil, fmt.Errorf("binr unable to fetch sigstore bundle %q. %w", url, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("binr received an HTTP 404 from sigstore bundle URL %q. %w", url, binr.ErrNotFound)
+	} else if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("binr received an HTTP %v from sigstore bundle URL %q", res.StatusCode, url)
+	}
+	var buf bytes.Buffer
+	if _, err = io.Copy(&buf, res.Body); err != nil {
+		return nil, fmt.Errorf("binr unable to read sigstore bundle %q. %w", url, err)
+	}
+	var b bundle.Bundle
+	if err = b.UnmarshalJSON(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("binr unable to parse sigstore bundle %q. %w", url, err)
+	}
+	return &b, nil
+}
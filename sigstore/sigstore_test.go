@@ -0,0 +1,213 @@
+package sigstore_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lkingland/binr"
+	"github.com/lkingland/binr/sigstore"
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/testing/ca"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+const (
+	testIdentity = "releases@example.com"
+	testIssuer   = "https://issuer.example.com"
+)
+
+// TestBundle ensures a download verified by its bundle is installed, with
+// the bundle requested per the options of the Get, and that a download
+// which does not match its bundle is rejected.
+func TestBundle(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	ctx := context.Background()
+
+	trustedRoot, err := ca.NewVirtualSigstore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifact := []byte("mytool\n")
+	signed := signedBundle(t, trustedRoot, artifact)
+
+	served := artifact
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.0.0/mytool":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(served)
+		case "/v1.0.0/mytool.sigstore.json":
+			_, _ = w.Write(signed)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	source := func(version, os, arch string) (string, string, error) {
+		return server.URL + "/" + version + "/mytool", "", nil
+	}
+
+	identity, err := verify.NewShortCertificateIdentity(testIssuer, "", testIdentity, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := sigstore.Bundle(func(version, os, arch string) string {
+		return server.URL + "/" + version + "/mytool.sigstore.json"
+	}, trustedRoot, identity, verify.WithTransparencyLog(1), verify.WithObserverTimestamps(1))
+
+	// Valid
+	if _, err = binr.Get(ctx, "valid", "mytool", "v1.0.0", source, binr.WithVerifier(verifier)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Requested with the options of the Get
+	local := binr.WithVerifier(sigstore.Bundle(func(version, os, arch string) string {
+		return strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/" + version + "/mytool.sigstore.json"
+	}, trustedRoot, identity, verify.WithTransparencyLog(1), verify.WithObserverTimestamps(1)))
+	if _, err = binr.Get(ctx, "allowed", "mytool", "v1.0.0", source,
+		local, binr.WithAllowedHosts([]string{"127.0.0.1"})); !errors.Is(err, binr.ErrHostNotAllowed) {
+		t.Fatalf("expected the bundle requested with the allowed hosts of the Get, got %v", err)
+	}
+
+	// Tampered
+	served = []byte("tampered\n")
+	if _, err = binr.Get(ctx, "tampered", "mytool", "v1.0.0", source, binr.WithVerifier(verifier)); err == nil {
+		t.Fatal("expected a download which does not match its bundle to be rejected")
+	}
+	path, _ := binr.Path("tampered", "mytool", "v1.0.0")
+	if _, err = os.Lstat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the tampered download not to be installed. %v", err)
+	}
+
+	// Another identity
+	other, err := verify.NewShortCertificateIdentity(testIssuer, "", "someone@example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served = artifact
+	verifier = sigstore.Bundle(func(version, os, arch string) string {
+		return server.URL + "/" + version + "/mytool.sigstore.json"
+	}, trustedRoot, other, verify.WithTransparencyLog(1), verify.WithObserverTimestamps(1))
+	if _, err = binr.Get(ctx, "other", "mytool", "v1.0.0", source, binr.WithVerifier(verifier)); err == nil {
+		t.Fatal("expected a bundle issued to another identity to be rejected")
+	}
+}
+
+// TestBundle_Invalid ensures downloads are rejected when their bundle is
+// missing or can not be parsed.
+func TestBundle_Invalid(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.0.0/mytool.sigstore.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"mediaType": "not a bundle"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "mytool")
+	if err := os.WriteFile(path, []byte("mytool\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := sigstore.Bundle(func(version, os, arch string) string {
+		return server.URL + "/" + version + "/mytool.sigstore.json"
+	}, nil, verify.CertificateIdentity{})
+
+	// Missing
+	err := verifier(context.Background(), path, "v2.0.0", "linux", "amd64")
+	if !errors.Is(err, binr.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a missing bundle, got %v", err)
+	}
+
+	// Invalid
+	if err = verifier(context.Background(), path, "v1.0.0", "linux", "amd64"); err == nil {
+		t.Fatal("expected an error for an invalid bundle")
+	}
+}
+
+// signedBundle returns the JSON of a bundle of the artifact signed by the
+// virtual Sigstore, including the inclusion proof of its log entry.
+func signedBundle(t *testing.T, vs *ca.VirtualSigstore, artifact []byte) []byte {
+	t.Helper()
+	entity, err := vs.Sign(testIdentity, testIssuer, artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := entity.VerificationContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := entity.SignatureContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamps, err := entity.Timestamps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := entity.TlogEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := entries[0].TransparencyLogEntry()
+	proof, err := vs.GetInclusionProof(entry.CanonicalizedBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootHash, err := hex.DecodeString(*proof.RootHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.KindVersion = &protorekor.KindVersion{Kind: "hashedrekord", Version: "0.0.1"}
+	entry.InclusionProof = &protorekor.InclusionProof{
+		LogIndex:   *proof.LogIndex,
+		RootHash:   rootHash,
+		TreeSize:   *proof.TreeSize,
+		Checkpoint: &protorekor.Checkpoint{Envelope: *proof.Checkpoint},
+	}
+
+	message := signature.(*bundle.MessageSignature)
+	var rfc3161 []*protocommon.RFC3161SignedTimestamp
+	for _, timestamp := range timestamps {
+		rfc3161 = append(rfc3161, &protocommon.RFC3161SignedTimestamp{SignedTimestamp: timestamp})
+	}
+	b, err := bundle.NewBundle(&protobundle.Bundle{
+		MediaType: "application/vnd.dev.sigstore.bundle.v0.3+json",
+		VerificationMaterial: &protobundle.VerificationMaterial{
+			Content: &protobundle.VerificationMaterial_Certificate{
+				Certificate: &protocommon.X509Certificate{RawBytes: content.(*bundle.Certificate).Certificate().Raw},
+			},
+			TlogEntries:               []*protorekor.TransparencyLogEntry{entry},
+			TimestampVerificationData: &protobundle.TimestampVerificationData{Rfc3161Timestamps: rfc3161},
+		},
+		Content: &protobundle.Bundle_MessageSignature{
+			MessageSignature: &protocommon.MessageSignature{
+				MessageDigest: &protocommon.HashOutput{
+					Algorithm: protocommon.HashAlgorithm(protocommon.HashAlgorithm_value[message.DigestAlgorithm()]),
+					Digest:    message.Digest(),
+				},
+				Signature: message.Signature(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb, err := b.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bb
+}
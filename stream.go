@@ -0,0 +1,158 @@
+package binr
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Stream downloads the content at url into w, verifying it against the
+// checksum at sumURL, and returns its checksum.  As with a Source, sumURL
+// may be the checksum itself, and if empty the content is not verified and
+// its checksum is that of the config's Hasher (see WithHasher).  Nothing is
+// cached or linked, such that w may be storage managed by the caller, such
+// as a block store.
+//
+// Content is written to w in order from offset zero.  A failed download is
+// retried if WithRetries is provided, resuming with an HTTP Range request
+// from the offset reached, or from zero if the server does not support
+// ranges.  Only on a nil error has the content been verified: the caller
+// should discard what was written to w otherwise.  Options which apply to
+// files, such as WithVerifier, are not applicable.
+func Stream(ctx context.Context, url, sumURL string, w io.WriterAt, options ...option) (checksum string, err error) {
+	cfg := newConfig(options...)
+	traceID := cfg.trace(ctx)
+	defer func() { err = traceError(err, traceID) }()
+
+	if w == nil {
+		return "", errors.New("binr Stream requires a writer")
+	}
+	source := func(_, _, _ string) (string, string, error) { return url, sumURL, nil }
+	if _, _, err = resolve(cfg, source, "", "", ""); err != nil {
+		return
+	}
+	expected, err := getChecksum(ctx, cfg, sumURL, url)
+	if err != nil {
+		return
+	}
+	algorithm := cfg.hasher.Algorithm()
+	if expected != "" {
+		algorithm, _ = splitChecksum(expected)
+	}
+	newHash, err := cfg.newHash(algorithm)
+	if err != nil {
+		return
+	}
+
+	s := &streamWriter{w: w, hash: newHash()}
+	for attempt := 1; ; attempt++ {
+		err = streamAttempt(ctx, cfg, url, s)
+		if err == nil || attempt > cfg.retries || !retryable(ctx, err) {
+			break
+		}
+		cfg.log.Debug().Err(err).Int("attempt", attempt).Int64("offset", s.offset).Msg("binr retrying stream")
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("binr stopped retrying stream. %w", ctx.Err())
+		case <-time.After(retryInterval * time.Duration(attempt)):
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	if checksum = hex.EncodeToString(s.hash.Sum(nil)); algorithm != "sha256" {
+		checksum = algorithm + "-" + checksum
+	}
+	if expected != "" && checksum != expected {
+		cfg.log.Debug().
+			Str("url", redact(url)).
+			Str("expected", expected).
+			Str("calculated", checksum).
+			Msg("checksum mismatch")
+		return "", fmt.Errorf("binr detected a checksum mismatch streaming %q", redact(url))
+	}
+	return checksum, nil
+}
+
+// streamWriter writes to a WriterAt in order, hashing what is written.
+type streamWriter struct {
+	w      io.WriterAt
+	offset int64
+	hash   hash.Hash
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	n, err := s.w.WriteAt(p, s.offset)
+	s.hash.Write(p[:n])
+	s.offset += int64(n)
+	return n, err
+}
+
+// reset to write from offset zero.
+func (s *streamWriter) reset() {
+	s.offset = 0
+	s.hash.Reset()
+}
+
+// streamAttempt makes one attempt to download the content at url into the
+// stream, resuming from its offset if not zero.
+func streamAttempt(ctx context.Context, cfg config, url string, s *streamWriter) error {
+	var header http.Header
+	if s.offset > 0 {
+		header = http.Header{"Range": {fmt.Sprintf("bytes=%d-", s.offset)}}
+	}
+	res, err := request(ctx, cfg, http.MethodGet, url, header)
+	if err != nil {
+		return fmt.Errorf("binr received an http error streaming the command. %w", err)
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("binr received an HTTP 404 from source URL %q. %w", url, ErrNotFound)
+	case s.offset > 0 && res.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		if size, known := rangeSize(res.Header.Get("Content-Range")); known && size == s.offset {
+			return nil // already complete, which verification confirms
+		}
+		// The content is not that of which the offset was reached.
+		cfg.log.Debug().Int64("offset", s.offset).Str("range", res.Header.Get("Content-Range")).Msg("binr restarting stream")
+		res.Body.Close()
+		s.reset()
+		return streamAttempt(ctx, cfg, url, s)
+	case s.offset > 0 && res.StatusCode == http.StatusPartialContent:
+		if !strings.HasPrefix(res.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", s.offset)) {
+			return fmt.Errorf("binr received an unexpected content range %q resuming from source URL %q", res.Header.Get("Content-Range"), url)
+		}
+		cfg.log.Debug().Int64("offset", s.offset).Msg("binr resuming stream")
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("binr received an HTTP %v from source URL %q", res.StatusCode, url)
+	default:
+		s.reset() // the server ignored the range
+	}
+	if cfg.responseValidator != nil {
+		if err = cfg.responseValidator(res); err != nil {
+			return fmt.Errorf("binr rejected the response from source URL %q. %w", url, err)
+		}
+	}
+	if contentType := res.Header.Get("Content-Type"); contentType != "application/octet-stream" {
+		return fmt.Errorf("binr unable to stream the command.  Source URL reported a content type of %q when \"application/octet-stream\" was expected", contentType)
+	}
+	var body io.Reader = contextReader{ctx, res.Body}
+	if cfg.maxBandwidth > 0 {
+		body = newThrottledReader(ctx, body, cfg.maxBandwidth)
+	}
+	var buf []byte
+	if cfg.downloadBufferSize > 0 {
+		buf = make([]byte, cfg.downloadBufferSize)
+	}
+	if _, err = io.CopyBuffer(s, body, buf); err != nil {
+		return fmt.Errorf("binr encountered an error copying remote data. %w", err)
+	}
+	return nil
+}
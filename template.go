@@ -0,0 +1,44 @@
+package binr
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// TemplateSource returns a Source whose URLs are expanded from the given
+// text/template strings, such that sources can be declared in configuration
+// rather than code.  The templates are provided the Version, OS and Arch
+// requested, and the function trimPrefix.  For example:
+//
+//	https://example.com/{{.Version}}/mytool_{{trimPrefix .Version "v"}}_{{.OS}}_{{.Arch}}
+//
+// The checksum template is optional, and may expand to either a checksum
+// URL or a checksum (see Source).  An error is returned if either template
+// can not be parsed.
+func TemplateSource(urlTmpl, sumTmpl string) (Source, error) {
+	funcs := template.FuncMap{"trimPrefix": strings.TrimPrefix}
+	url, err := template.New("url").Funcs(funcs).Parse(urlTmpl)
+	if err != nil {
+		return nil, fmt.Errorf("binr unable to parse source URL template. %w", err)
+	}
+	var sum *template.Template
+	if sumTmpl != "" {
+		if sum, err = template.New("sum").Funcs(funcs).Parse(sumTmpl); err != nil {
+			return nil, fmt.Errorf("binr unable to parse checksum URL template. %w", err)
+		}
+	}
+	return func(version, os, arch string) (string, string, error) {
+		data := struct{ Version, OS, Arch string }{version, os, arch}
+		var u, s strings.Builder
+		if err := url.Execute(&u, data); err != nil {
+			return "", "", fmt.Errorf("binr unable to expand source URL template. %w", err)
+		}
+		if sum != nil {
+			if err := sum.Execute(&s, data); err != nil {
+				return "", "", fmt.Errorf("binr unable to expand checksum URL template. %w", err)
+			}
+		}
+		return u.String(), s.String(), nil
+	}, nil
+}
@@ -0,0 +1,230 @@
+package binr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// isFloating returns true if the version is resolved to a release rather
+// than being exact.
+func isFloating(version string) bool {
+	kind, _ := ClassifyVersion(version)
+	return kind == Latest || kind == MajorConstraint || kind == MinorConstraint
+}
+
+// resolveVersion returns the newest release listed by the config's Lister
+// which satisfies the floating version.
+func resolveVersion(ctx context.Context, cfg config, floating string) (string, error) {
+	releases, err := listReleases(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	return newest(releases, floating)
+}
+
+// listReleases returns the versions listed by the config's Lister.
+func listReleases(ctx context.Context, cfg config) ([]string, error) {
+	if cfg.lister == nil {
+		return nil, errors.New("binr requires a Lister to resolve floating versions (vX, vX.Y or latest). See WithLister")
+	}
+	releases, err := cfg.lister.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("binr unable to list the releases available. %w", err)
+	}
+	return releases, nil
+}
+
+// newest returns the newest of the releases which satisfies the floating
+// version: any release for "latest", those of the same major for vX, and
+// those of the same major and minor for vX.Y.  Pre-releases are not
+// considered.
+func newest(releases []string, floating string) (string, error) {
+	var (
+		constraint *semver.Version
+		parts      = strings.Count(floating, ".") + 1
+		highest    *semver.Version
+	)
+	if floating != latestVersion {
+		var err error
+		if constraint, err = semver.NewVersion(floating); err != nil {
+			return "", fmt.Errorf("binr received an invalid floating version %q", floating)
+		}
+	}
+	for _, release := range releases {
+		if !exactVersion.MatchString(release) {
+			continue
+		}
+		v, err := semver.NewVersion(release)
+		if err != nil || v.Prerelease() != "" {
+			continue
+		}
+		if constraint != nil && (v.Major() != constraint.Major() || (parts > 1 && v.Minor() != constraint.Minor())) {
+			continue
+		}
+		if highest == nil || v.GreaterThan(highest) {
+			highest = v
+		}
+	}
+	if highest == nil {
+		return "", fmt.Errorf("binr found no release satisfying %q. %w", floating, ErrNotFound)
+	}
+	return highest.Original(), nil
+}
+
+// linkFloating replaces the floating link of the command (named as linked,
+// see config.linkName) to the object with the given checksum, returning its
+// path.  The floating link of "latest" is the unversioned link.
+func linkFloating(cfg config, namespace, name, floating, sum string) (path string, err error) {
+	version := floating
+	if floating == latestVersion {
+		version = ""
+	}
+	if path, err = cfg.linkPath(namespace, name, version); err != nil {
+		return
+	}
+	target := linkTarget(cfg.cachePath(), path, cfg.objectName(sum))
+	cfg.log.Debug().
+		Str("target", target).
+		Str("path", path).
+		Msg("binr linking floating version")
+	if err = cfg.linked.replace(cfg, target, path); err != nil {
+		return "", fmt.Errorf("binr unable to link floating version %v. %w", floating, err)
+	}
+	return
+}
+
+// update each floating link of the command in the namespace, including its
+// unversioned link (latest), to the newest release which satisfies it,
+// installing the release if necessary.  Links to exact versions are left
+// unchanged.  See WithUpdate and WithSwapOnUpdate.
+func update(ctx context.Context, cfg config, namespace, command string, source Source) error {
+	releases, err := listReleases(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(cfg.rootPath(), namespace)
+	entries, err := cfg.fs.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("binr unable to read namespace %v. %w", namespace, err)
+	}
+	name := cfg.linkName(command)
+	updates := map[string]string{} // floating version to release
+	var floatings []string
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		floating, ok := linkVersion(entry.Name(), name)
+		if !ok || exactVersion.MatchString(floating) {
+			continue
+		}
+		if floating == "" {
+			floating = latestVersion
+		}
+		if updates[floating], err = newest(releases, floating); err != nil {
+			return err
+		}
+		floatings = append(floatings, floating)
+	}
+	if cfg.swapOnUpdate {
+		return swap(ctx, cfg, namespace, command, floatings, updates, source)
+	}
+	for _, floating := range floatings {
+		version := updates[floating]
+		res, err := install(ctx, cfg, namespace, command, version, source)
+		if err != nil {
+			return err
+		}
+		if _, err = linkFloating(cfg, namespace, name, floating, res.Checksum); err != nil {
+			return err
+		}
+		cfg.log.Debug().
+			Str("floating", floating).
+			Str("version", version).
+			Msg("binr updated floating link")
+	}
+	return nil
+}
+
+// swap the floating links of the command to their updated releases only
+// once every release has been fetched and verified, such that a release
+// which fails leaves all links unchanged.  See WithSwapOnUpdate.
+func swap(ctx context.Context, cfg config, namespace, command string, floatings []string, updates map[string]string, source Source) error {
+	name := cfg.linkName(command)
+	sums := map[string]string{}    // release to checksum
+	installed := map[string]bool{} // releases already linked
+	for _, floating := range floatings {
+		version := updates[floating]
+		if _, ok := sums[version]; ok {
+			continue
+		}
+		res, done, err := stage(ctx, cfg, namespace, name, command, version, source)
+		if done != nil {
+			defer done()
+		}
+		if err != nil {
+			return fmt.Errorf("binr unable to update %v to %v. Links unchanged. %w", floating, version, err)
+		}
+		sums[version], installed[version] = res.Checksum, res.Cached
+	}
+	for _, floating := range floatings {
+		version := updates[floating]
+		if !installed[version] {
+			if err := link(cfg, namespace, name, version, sums[version]); err != nil {
+				return err
+			}
+			installed[version] = true
+		}
+		if _, err := linkFloating(cfg, namespace, name, floating, sums[version]); err != nil {
+			return err
+		}
+		cfg.log.Debug().
+			Str("floating", floating).
+			Str("version", version).
+			Msg("binr swapped floating link")
+	}
+	return nil
+}
+
+// stage the release of the command for linking, fetching it into the cache
+// if it is not already installed, and verifying the object in the cache
+// against its checksum.  The Result is Cached if the release is already
+// linked in the namespace.  done, if not nil, must be invoked once the
+// release is linked.
+func stage(ctx context.Context, cfg config, namespace, name, command, version string, source Source) (res Result, done func(), err error) {
+	path, err := cfg.linkPath(namespace, name, version)
+	if err != nil {
+		return
+	}
+	if got(cfg, path) {
+		target, err := cfg.fs.Readlink(path)
+		if err != nil {
+			return res, done, fmt.Errorf("binr unable to read link %v. %w", path, err)
+		}
+		res.Checksum, _ = objectChecksum(filepath.Base(target))
+		res.Cached = true
+	} else {
+		if res, done, err = fetchForSystem(ctx, cfg, command, version, source); err != nil {
+			return
+		}
+		res.Cached = false
+		if cfg.assertVersion != nil {
+			if err = assertVersion(ctx, cfg, cfg.objectPath(res.Checksum), version); err != nil {
+				return
+			}
+		}
+		if cfg.beforeLink != nil {
+			if err = beforeLink(cfg, res); err != nil {
+				return
+			}
+		}
+	}
+	err = verify(ctx, cfg, cfg.objectPath(res.Checksum), res.Checksum)
+	return
+}
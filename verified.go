@@ -0,0 +1,63 @@
+package binr
+
+import (
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// verifiedSuffix is that of the sidecar file recording when an object in the
+// cache was last verified.  See WithVerifyWindow.
+const verifiedSuffix = ".verified"
+
+// verifyObject verifies the object in the cache at path against its
+// checksum, unless it was verified within the config's verify window and
+// has not been modified since.  A successful verification is recorded in a
+// sidecar file alongside the object, whose modification time is that of the
+// verification and whose content is the object's modification time.
+func verifyObject(ctx context.Context, cfg config, path, checksum string) error {
+	if cfg.verifyWindow <= 0 {
+		return verify(ctx, cfg, path, checksum)
+	}
+	info, err := cfg.fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	modified := strconv.FormatInt(info.ModTime().UnixNano(), 10)
+	if recentlyVerified(cfg, path, modified) {
+		cfg.log.Debug().Str("path", path).Msg("binr skipping verification of recently verified command")
+		return nil
+	}
+	if err = verify(ctx, cfg, path, checksum); err != nil {
+		return err
+	}
+	f, err := cfg.fs.OpenFile(path+verifiedSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err == nil {
+		_, err = io.WriteString(f, modified)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil { // the object is verified regardless
+		cfg.warn(err, path, "binr unable to record verification")
+	}
+	return nil
+}
+
+// recentlyVerified returns true if the sidecar of the object at path records
+// a verification within the verify window of the object as last modified.
+func recentlyVerified(cfg config, path, modified string) bool {
+	info, err := cfg.fs.Stat(path + verifiedSuffix)
+	if err != nil || cfg.clock().Sub(info.ModTime()) > cfg.verifyWindow {
+		return false
+	}
+	f, err := cfg.fs.Open(path + verifiedSuffix)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	recorded, err := io.ReadAll(io.LimitReader(f, 64))
+	return err == nil && strings.TrimSpace(string(recorded)) == modified
+}
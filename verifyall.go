@@ -0,0 +1,71 @@
+package binr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// VerifyResult of verifying an installed command.
+type VerifyResult struct {
+	Installed
+
+	// Verified is true if the link's Status is OK and the object it targets
+	// matches its checksum.
+	Verified bool
+
+	// Problem describes why the command was not verified, if it was not.
+	Problem string
+}
+
+// VerifyAll verifies every command installed in every namespace, keyed by
+// namespace, such as for a periodic audit of the integrity of the store.
+// Each link's Status is reported (see List), and the object targeted by
+// each link which is OK is checked against its checksum.  Each object is
+// hashed once regardless of the number of links targeting it.  An error is
+// returned only if the verification itself could not be completed.
+func VerifyAll(options ...option) (map[string][]VerifyResult, error) {
+	cfg := newConfig(options...)
+	root := cfg.rootPath()
+	entries, err := cfg.fs.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string][]VerifyResult{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("binr unable to read namespaces. %w", err)
+	}
+
+	var (
+		results  = map[string][]VerifyResult{}
+		verified = map[string]error{} // by object path
+	)
+	for _, entry := range entries {
+		dir, _ := filepath.Abs(filepath.Join(root, entry.Name()))
+		if !entry.IsDir() || dir == cfg.cachePath() || entry.Name() == ".cache" {
+			continue
+		}
+		list, err := List(entry.Name(), options...)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range list {
+			r := VerifyResult{Installed: i}
+			if i.Status != StatusOK {
+				r.Problem = fmt.Sprintf("link is %v", i.Status)
+				results[entry.Name()] = append(results[entry.Name()], r)
+				continue
+			}
+			verr, ok := verified[i.Target]
+			if !ok {
+				verr = verify(context.Background(), cfg, i.Target, i.Checksum)
+				verified[i.Target] = verr
+			}
+			if r.Verified = verr == nil; !r.Verified {
+				r.Problem = verr.Error()
+			}
+			results[entry.Name()] = append(results[entry.Name()], r)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,105 @@
+package binr
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// VersionKind is the kind of version string, which determines how Get
+// resolves it.  See ClassifyVersion.
+type VersionKind string
+
+const (
+	// Exact versions (vX.Y.Z, with optional pre-release and build
+	// metadata) are installed as-is.
+	Exact VersionKind = "exact"
+	// MajorConstraint versions (vX) resolve to the newest vX.Y.Z.
+	MajorConstraint VersionKind = "major"
+	// MinorConstraint versions (vX.Y) resolve to the newest vX.Y.Z.
+	MinorConstraint VersionKind = "minor"
+	// Latest resolves to the newest release.
+	Latest VersionKind = "latest"
+	// Constraint versions are semver ranges such as ">=1.2, <2" or "~1.2".
+	// These are not yet supported by Get.
+	Constraint VersionKind = "constraint"
+	// Channel versions name a release channel such as "stable" or "beta".
+	// These are not yet supported by Get.
+	Channel VersionKind = "channel"
+	// Digest versions identify a build by the digest of its content
+	// (sha256:<hex>) or by the git commit from which it was built
+	// (sha:<gitsha>), such as for unreleased builds.  They are provided to
+	// the Source as-is, and linked by their abbreviated digest.
+	Digest VersionKind = "digest"
+)
+
+var (
+	// exactVersion matches a complete semver (vX.Y.Z with optional
+	// prerelease and build metadata) as opposed to a floating major or minor
+	// (vX, vX.Y).
+	exactVersion = regexp.MustCompile(`^v?\d+\.\d+\.\d+([-+].*)?$`)
+
+	// floatingVersion matches a floating major or minor version (vX, vX.Y).
+	floatingVersion = regexp.MustCompile(`^v?\d+(\.\d+)?$`)
+
+	// majorVersion matches a floating major version (vX).
+	majorVersion = regexp.MustCompile(`^v?\d+$`)
+
+	// digestVersion matches a content digest or git commit version.
+	digestVersion = regexp.MustCompile(`^(sha256:[0-9a-fA-F]{64}|sha:[0-9a-fA-F]{7,40})$`)
+
+	// channelName matches the name of a release channel.
+	channelName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9._-]*$`)
+)
+
+// latestVersion is the floating version which resolves to the newest
+// release, and is linked as the command's unversioned link.
+const latestVersion = "latest"
+
+// ClassifyVersion returns the kind of the version string s, such that user
+// input can be validated before calling Get.  Get accepts Exact,
+// MajorConstraint, MinorConstraint, Latest and Digest versions.  An error is
+// returned if s is not a version of any kind.
+func ClassifyVersion(s string) (VersionKind, error) {
+	switch {
+	case s == "":
+		return "", errors.New("binr ClassifyVersion requires a version")
+	case s == latestVersion:
+		return Latest, nil
+	case exactVersion.MatchString(s):
+		if _, err := semver.NewVersion(s); err != nil {
+			return "", fmt.Errorf("binr received an invalid version %q. %w", s, err)
+		}
+		return Exact, nil
+	case digestVersion.MatchString(s):
+		return Digest, nil
+	case majorVersion.MatchString(s):
+		return MajorConstraint, nil
+	case floatingVersion.MatchString(s):
+		return MinorConstraint, nil
+	}
+	if _, err := semver.NewConstraint(s); err == nil {
+		return Constraint, nil
+	}
+	if channelName.MatchString(s) {
+		return Channel, nil
+	}
+	return "", fmt.Errorf("binr received %q, which is neither a version, a constraint nor a channel", s)
+}
+
+// shortDigestLength is the number of characters of a Digest version by
+// which it is linked.
+const shortDigestLength = 12
+
+// shortDigest returns the abbreviated digest of a Digest version by which
+// it is linked.
+func shortDigest(version string) string {
+	_, digest, _ := strings.Cut(version, ":")
+	if len(digest) > shortDigestLength {
+		digest = digest[:shortDigestLength]
+	}
+	return strings.ToLower(digest)
+}
@@ -0,0 +1,15 @@
+//go:build binr_xz
+
+package binr
+
+import (
+	"io"
+
+	"github.com/ulikunitz/xz"
+)
+
+// Support for .tar.xz archives requires an additional dependency, and is
+// therefore only included when built with the binr_xz tag.
+func init() {
+	decompressors["xz"] = func(r io.Reader) (io.Reader, error) { return xz.NewReader(r) }
+}
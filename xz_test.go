@@ -0,0 +1,12 @@
+//go:build binr_xz
+
+package binr_test
+
+import "testing"
+
+// TestGet_ArchiveXz ensures that a command is extracted from a .tar.xz
+// archive when built with xz support.
+func TestGet_ArchiveXz(t *testing.T) {
+	testGetArchive(t, "mytool.tar.xz", "/mytool.tar.xz")
+	testGetArchive(t, "mytool.tar.xz", "/download")
+}